@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"encoding/json"
 	"os"
+	"path/filepath"
 
 	"github.com/golang/glog"
 )
@@ -37,6 +38,28 @@ func SaveToFile(filePath string, object interface{}) error {
 	return file.Close()
 }
 
+// Saves data to the gob file atomically: data is written to a temporary file which replaces the
+// file, so the readers (also in the other processes) never see a partially written file.
+func SaveToFileAtomic(filePath string, object interface{}) error {
+	glog.V(1).Infof("Opening temporary file for %q.", filePath)
+	file, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	err = gob.NewEncoder(file).Encode(object)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filePath)
+}
+
 // Loads data from the JSON file.
 func LoadFromJson(filePath string, object interface{}) error {
 	glog.V(1).Infof("Opening file %q.", filePath)
@@ -66,12 +89,24 @@ func SaveConfigToFile(appName string, object interface{}) error {
 	return SaveToFile(getPath(appName, "gob"), object)
 }
 
+// Saves data to the gob file in the config directory atomically (see SaveToFileAtomic):
+// $HOME/.config/{appName}.gob
+func SaveConfigToFileAtomic(appName string, object interface{}) error {
+	return SaveToFileAtomic(getPath(appName, "gob"), object)
+}
+
 // Loads data from the JSON file in the config directory:
 // $HOME/.config/{appName}.json
 func LoadConfigFromJson(appName string, object interface{}) error {
 	return LoadFromJson(getPath(appName, "json"), object)
 }
 
+// Returns the path of the gob file in the config directory:
+// $HOME/.config/{appName}.gob
+func ConfigFilePath(appName string) string {
+	return getPath(appName, "gob")
+}
+
 func getPath(appName string, extension string) string {
 	return os.Getenv("HOME") + "/.config/" + appName + "." + extension
 }
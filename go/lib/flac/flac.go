@@ -0,0 +1,139 @@
+// Package flac contains minimal parser of the FLAC metadata (stream info and Vorbis comments).
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	blockStreamInfo    = 0
+	blockVorbisComment = 4
+)
+
+var marker = []byte("fLaC")
+
+type Tag struct {
+	// Vorbis comments by the upper case field name, only the first value of the field is stored.
+	Comments map[string]string
+	// Length computed from the stream info, 0 if unknown.
+	LengthMs int64
+}
+
+// Parses the metadata blocks at the beginning of the FLAC stream, audio frames are not read.
+func Parse(r io.Reader) (*Tag, error) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header, marker) {
+		return nil, fmt.Errorf("FLAC marker not found")
+	}
+
+	t := &Tag{
+		Comments: make(map[string]string),
+	}
+	for last := false; !last; {
+		_, err = io.ReadFull(r, header)
+		if err != nil {
+			return nil, err
+		}
+		last = header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		if blockType != blockStreamInfo && blockType != blockVorbisComment {
+			_, err = io.CopyN(io.Discard, r, int64(size))
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		block := make([]byte, size)
+		_, err = io.ReadFull(r, block)
+		if err != nil {
+			return nil, err
+		}
+		if blockType == blockStreamInfo {
+			t.LengthMs = streamLengthMs(block)
+		} else {
+			err = t.parseComments(block)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+func (t *Tag) Artist() string {
+	return t.Comments["ARTIST"]
+}
+
+func (t *Tag) Title() string {
+	return t.Comments["TITLE"]
+}
+
+func (t *Tag) Genre() string {
+	return t.Comments["GENRE"]
+}
+
+// Stream info contains 20 bits sample rate and 36 bits number of samples starting at the byte 10.
+func streamLengthMs(b []byte) int64 {
+	if len(b) < 18 {
+		return 0
+	}
+	sampleRate := int64(b[10])<<12 | int64(b[11])<<4 | int64(b[12])>>4
+	samples := int64(b[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(b[14:18]))
+	if sampleRate == 0 {
+		return 0
+	}
+	return samples * 1000 / sampleRate
+}
+
+// Vorbis comments use little endian lengths: vendor string, number of comments and "NAME=value" comments.
+func (t *Tag) parseComments(b []byte) error {
+	next := func() ([]byte, error) {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("vorbis comment truncated")
+		}
+		size := int(binary.LittleEndian.Uint32(b))
+		if size < 0 || 4+size > len(b) {
+			return nil, fmt.Errorf("invalid vorbis comment size: %d", size)
+		}
+		result := b[4 : 4+size]
+		b = b[4+size:]
+		return result, nil
+	}
+
+	// Vendor
+	_, err := next()
+	if err != nil {
+		return err
+	}
+	if len(b) < 4 {
+		return fmt.Errorf("vorbis comment truncated")
+	}
+	count := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	for i := 0; i < count; i++ {
+		comment, err := next()
+		if err != nil {
+			return err
+		}
+		nameValue := strings.SplitN(string(comment), "=", 2)
+		if len(nameValue) != 2 {
+			continue
+		}
+		name := strings.ToUpper(nameValue[0])
+		if _, ok := t.Comments[name]; !ok {
+			t.Comments[name] = nameValue[1]
+		}
+	}
+	return nil
+}
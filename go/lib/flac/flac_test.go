@@ -0,0 +1,70 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func makeBlock(blockType byte, last bool, content []byte) []byte {
+	if last {
+		blockType |= 0x80
+	}
+	size := len(content)
+	return append([]byte{blockType, byte(size >> 16), byte(size >> 8), byte(size)}, content...)
+}
+
+func makeStreamInfo(sampleRate int, samples int64) []byte {
+	b := make([]byte, 34)
+	b[10] = byte(sampleRate >> 12)
+	b[11] = byte(sampleRate >> 4)
+	b[12] = byte(sampleRate<<4) | 0x02
+	b[13] = 0xf0 | byte(samples>>32)
+	binary.BigEndian.PutUint32(b[14:18], uint32(samples))
+	return b
+}
+
+func makeComments(comments ...string) []byte {
+	le := func(n int) []byte {
+		return binary.LittleEndian.AppendUint32(nil, uint32(n))
+	}
+	b := append(le(6), "vendor"...)
+	b = append(b, le(len(comments))...)
+	for _, c := range comments {
+		b = append(b, le(len(c))...)
+		b = append(b, c...)
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	var data []byte
+	data = append(data, "fLaC"...)
+	data = append(data, makeBlock(blockStreamInfo, false, makeStreamInfo(44100, 44100*185+22050))...)
+	// Padding is skipped
+	data = append(data, makeBlock(1, false, make([]byte, 100))...)
+	data = append(data, makeBlock(blockVorbisComment, true, makeComments("artist=Artist", "TITLE=Title", "ARTIST=Second", "Genre=Rock", "invalid"))...)
+	data = append(data, "audio frames"...)
+
+	tag, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if tag.Artist() != "Artist" || tag.Title() != "Title" || tag.Genre() != "Rock" || tag.LengthMs != 185500 {
+		t.Errorf("Parse() got: %+v, want Artist, Title, Rock, 185500", tag)
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("ID3\x03"),
+		[]byte("fLa"),
+		append([]byte("fLaC"), makeBlock(blockStreamInfo, false, makeStreamInfo(44100, 1))...),
+		append([]byte("fLaC"), makeBlock(blockVorbisComment, true, []byte{1, 0, 0, 0})...),
+		append([]byte("fLaC"), makeBlock(blockVorbisComment, true, append(makeComments(), 0xff))[:10]...),
+	} {
+		if tag, err := Parse(bytes.NewReader(data)); err == nil {
+			t.Errorf("Parse(%q) got: %+v, want error", data, tag)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package httpcache contains on-disk cache of the HTTP GET responses.
+package httpcache
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/lib/ratelimit"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Returned (wrapped) by Cached when the response is not in the cache.
+var ErrNotCached = errors.New("not cached")
+
+// Cache of the GET responses, other requests are passed to the client. Successful responses are stored
+// in the cache directory and revalidated using ETag and Last-Modified headers. Cache implements
+// ratelimit.AnyClient, when wrapping the rate limited client the cached responses are not throttled.
+type Cache struct {
+	client ratelimit.AnyClient
+	dir    string
+}
+
+// Response stored on disk.
+type entry struct {
+	Url        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Stored     time.Time
+}
+
+// Creates the cache storing the responses in the dir directory.
+func New(client ratelimit.AnyClient, dir string) (*Cache, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{
+		client: client,
+		dir:    dir,
+	}, nil
+}
+
+// Creates the cache in the user cache directory: $HOME/.cache/{appName}/http.
+func NewInCacheDir(client ratelimit.AnyClient, appName string) (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return New(client, filepath.Join(dir, appName, "http"))
+}
+
+// Returns the response, the cached one is used when the server confirms it was not modified.
+func (c *Cache) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := c.load(url)
+	if cached != nil {
+		if etag := cached.Header.Get("ETag"); len(etag) > 0 {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); len(lastModified) > 0 {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		glog.V(2).Infof("Not modified: %q", url)
+		return cached.response(req), nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{
+		Url:        url,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Stored:     time.Now(),
+	}
+	if resp.StatusCode == http.StatusOK {
+		err = c.save(e)
+		if err != nil {
+			glog.Warningf("Could not cache %q: %v", url, err)
+		}
+	}
+	return e.response(req), nil
+}
+
+// Returns the cached response without contacting the server, should be used only for the urls
+// which content never changes (e.g. charts from the past). Not cached response is downloaded.
+func (c *Cache) GetImmutable(url string) (*http.Response, error) {
+	cached := c.load(url)
+	if cached != nil {
+		glog.V(2).Infof("Immutable cache hit: %q", url)
+		return cached.response(nil), nil
+	}
+	return c.Get(url)
+}
+
+// Returns the cached response or ErrNotCached, the server is never contacted.
+func (c *Cache) Cached(url string) (*http.Response, error) {
+	cached := c.load(url)
+	if cached == nil {
+		return nil, fmt.Errorf("%w: %q", ErrNotCached, url)
+	}
+	return cached.response(nil), nil
+}
+
+func (c *Cache) Do(req *http.Request) (*http.Response, error) {
+	return c.client.Do(req)
+}
+
+func (c *Cache) Head(url string) (*http.Response, error) {
+	return c.client.Head(url)
+}
+
+func (c *Cache) Post(url string, contentType string, body io.Reader) (*http.Response, error) {
+	return c.client.Post(url, contentType, body)
+}
+
+func (c *Cache) PostForm(url string, data url.Values) (*http.Response, error) {
+	return c.client.PostForm(url, data)
+}
+
+func (c *Cache) path(url string) string {
+	hash := sha1.Sum([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(hash[:])+".gob")
+}
+
+// Returns the cached entry or nil.
+func (c *Cache) load(url string) *entry {
+	e := &entry{}
+	err := conf.LoadFromFile(c.path(url), e)
+	if err != nil || e.Url != url {
+		return nil
+	}
+	return e
+}
+
+func (c *Cache) save(e *entry) error {
+	return conf.SaveToFile(c.path(e.Url), e)
+}
+
+func (e *entry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,148 @@
+package httpcache
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Server counting the requests and full responses.
+type fakeServer struct {
+	*httptest.Server
+	requests int
+	full     int
+	lock     sync.Mutex
+}
+
+func newFakeServer() *fakeServer {
+	s := &fakeServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.requests++
+
+		switch r.URL.Path {
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		case "/modified":
+			if r.Header.Get("If-Modified-Since") == "Fri, 01 Jan 2021 00:00:00 GMT" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Last-Modified", "Fri, 01 Jan 2021 00:00:00 GMT")
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+		s.full++
+		fmt.Fprintf(w, "body %v", r.URL.Path)
+	}))
+	return s
+}
+
+func (s *fakeServer) counts() (int, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests, s.full
+}
+
+func (s *fakeServer) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests, s.full = 0, 0
+}
+
+func readBody(t *testing.T, resp *http.Response, err error) string {
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+	return string(body)
+}
+
+func TestGet(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	for _, test := range []struct {
+		path         string
+		wantRequests int
+		wantFull     int
+	}{
+		// Revalidated, body downloaded only once.
+		{"/etag", 3, 1},
+		{"/modified", 3, 1},
+		// No validators, downloaded every time.
+		{"/plain", 3, 3},
+	} {
+		server.reset()
+		c, err := New(&http.Client{}, t.TempDir())
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			resp, err := c.Get(server.URL + test.path)
+			got := readBody(t, resp, err)
+			if got != "body "+test.path || resp.StatusCode != 200 {
+				t.Errorf("%v got: %v %q, want: 200 %q", test.path, resp.StatusCode, got, "body "+test.path)
+			}
+		}
+		requests, full := server.counts()
+		if requests != test.wantRequests || full != test.wantFull {
+			t.Errorf("%v requests got: %v/%v, want: %v/%v", test.path, requests, full, test.wantRequests, test.wantFull)
+		}
+	}
+}
+
+func TestGetImmutableAndCached(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	c, err := New(&http.Client{}, t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	_, err = c.Cached(server.URL + "/plain")
+	if !errors.Is(err, ErrNotCached) {
+		t.Errorf("Cached got: %v, want: %v", err, ErrNotCached)
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.GetImmutable(server.URL + "/plain")
+		got := readBody(t, resp, err)
+		if got != "body /plain" {
+			t.Errorf("GetImmutable got: %q, want: %q", got, "body /plain")
+		}
+	}
+	if requests, _ := server.counts(); requests != 1 {
+		t.Errorf("requests got: %v, want: 1", requests)
+	}
+
+	resp, err := c.Cached(server.URL + "/plain")
+	got := readBody(t, resp, err)
+	if got != "body /plain" {
+		t.Errorf("Cached got: %q, want: %q", got, "body /plain")
+	}
+
+	// Errors are not cached.
+	for i := 0; i < 2; i++ {
+		resp, err := c.GetImmutable(server.URL + "/missing")
+		if err != nil || resp.StatusCode != 404 {
+			t.Errorf("GetImmutable got: %v %v, want: 404", resp, err)
+		}
+	}
+	if requests, _ := server.counts(); requests != 3 {
+		t.Errorf("requests got: %v, want: 3", requests)
+	}
+}
@@ -0,0 +1,200 @@
+package icy
+
+import (
+	"birnenlabs.com/go/lib/id3"
+	"bufio"
+	"fmt"
+	"github.com/golang/glog"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum size of the HLS segment that is read.
+const maxSegmentSize = 10 * 1024 * 1024
+
+type hlsPlaylist struct {
+	// Urls of the media playlists if this is a master playlist.
+	variants       []string
+	segments       []hlsSegment
+	targetDuration time.Duration
+	// True if playlist contains EXT-X-ENDLIST tag.
+	end bool
+}
+
+type hlsSegment struct {
+	sequence int
+	url      string
+}
+
+func isHls(streamUrl string, contentType string) bool {
+	u, err := url.Parse(streamUrl)
+	if err == nil && strings.HasSuffix(strings.ToLower(u.Path), ".m3u8") {
+		return true
+	}
+	return strings.Contains(strings.ToLower(contentType), "mpegurl")
+}
+
+// Reads HLS stream: refreshes media playlist and searches for ID3 timed metadata in the new segments.
+func readHls(client *http.Client, resp *http.Response, tracker *titleTracker) error {
+	playlist, err := parseHlsPlaylist(resp.Body, resp.Request.URL)
+	if err != nil {
+		return err
+	}
+
+	playlistUrl := resp.Request.URL.String()
+	if len(playlist.variants) > 0 {
+		// Master playlist, all the variants should contain the same metadata.
+		playlistUrl = playlist.variants[0]
+		playlist, err = getHlsPlaylist(client, playlistUrl)
+		if err != nil {
+			return err
+		}
+	}
+
+	lastSequence := -1
+	for {
+		for _, s := range playlist.segments {
+			if s.sequence <= lastSequence {
+				continue
+			}
+			lastSequence = s.sequence
+
+			title, err := getSegmentTitle(client, s.url)
+			if err != nil {
+				return err
+			}
+			tracker.found(title)
+		}
+
+		err = tracker.check()
+		if err != nil {
+			return err
+		}
+		if playlist.end {
+			return fmt.Errorf("end of HLS playlist")
+		}
+
+		time.Sleep(playlist.targetDuration)
+		playlist, err = getHlsPlaylist(client, playlistUrl)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func getHlsPlaylist(client *http.Client, playlistUrl string) (*hlsPlaylist, error) {
+	glog.V(3).Infof("Reading HLS playlist %q.", playlistUrl)
+	resp, err := client.Get(playlistUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+	return parseHlsPlaylist(resp.Body, resp.Request.URL)
+}
+
+func parseHlsPlaylist(body io.Reader, base *url.URL) (*hlsPlaylist, error) {
+	result := &hlsPlaylist{
+		targetDuration: 10 * time.Second,
+	}
+
+	scanner := bufio.NewScanner(body)
+	sequence := 0
+	variant := false
+	header := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "#EXTM3U":
+			header = true
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			d, err := strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"))
+			if err == nil && d > 0 {
+				result.targetDuration = time.Duration(d) * time.Second
+			}
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			s, err := strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"))
+			if err == nil {
+				sequence = s
+			}
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+			variant = true
+		case line == "#EXT-X-ENDLIST":
+			result.end = true
+		case len(line) == 0 || strings.HasPrefix(line, "#"):
+			// Other tags are ignored.
+		default:
+			u, err := base.Parse(line)
+			if err != nil {
+				return nil, err
+			}
+			if variant {
+				result.variants = append(result.variants, u.String())
+				variant = false
+			} else {
+				result.segments = append(result.segments, hlsSegment{sequence: sequence, url: u.String()})
+				sequence++
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, fmt.Errorf("invalid HLS playlist: #EXTM3U not found")
+	}
+	return result, nil
+}
+
+// Returns the title from the ID3 timed metadata of the segment or empty string if the segment does not contain it.
+func getSegmentTitle(client *http.Client, segmentUrl string) (string, error) {
+	glog.V(3).Infof("Reading HLS segment %q.", segmentUrl)
+	resp, err := client.Get(segmentUrl)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	// Packed audio segments start with the ID3 tag, in the MPEG-TS segments the tag is stored in the
+	// metadata stream, let's just search for it in the whole segment.
+	segment, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSegmentSize))
+	if err != nil {
+		return "", err
+	}
+
+	tag := id3.Find(segment)
+	if tag == nil {
+		return "", nil
+	}
+	return titleFromTag(tag), nil
+}
+
+func titleFromTag(tag *id3.Tag) string {
+	if t := tag.Text("TXXX:StreamTitle"); len(t) > 0 {
+		return t
+	}
+
+	title := tag.Title()
+	if t := findStreamTitle([]byte(title)); t != nil {
+		// Some streams are sending "StreamTitle='Artist - Title';" in the title frame.
+		return *t
+	}
+
+	artist := tag.Artist()
+	if len(artist) > 0 && len(title) > 0 {
+		return artist + " - " + title
+	}
+	return title
+}
@@ -3,17 +3,58 @@ package icy
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/golang/glog"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Method used to find the song titles.
+type Method int
+
+const (
+	// Stream was not opened.
+	MethodNone Method = iota
+	// Metadata blocks at the offsets defined by the icy-metaint header.
+	MethodMetaInt
+	// Searching for StreamTitle in the stream without icy-metaint header.
+	MethodScan
+	// ID3 timed metadata in the HLS segments.
+	MethodHls
+	// Icecast status-json.xsl page.
+	MethodIcecastStatus
+	// Shoutcast 7.html page.
+	MethodShoutcastStatus
+)
+
+var (
+	// Returned (wrapped) when no new title was found for 30 minutes.
+	ErrTitleTimeout = errors.New("title timeout")
+	// Returned (wrapped) when the stream was listened for the requested time.
+	ErrJobTimeout = errors.New("job timeout")
+)
+
 var (
-	streamTitle  = []byte("StreamTitle='")
-	titleTimeout = 30 * time.Minute
+	streamTitle    = []byte("StreamTitle='")
+	streamTitleEnd = []byte("';")
+	titleTimeout   = 30 * time.Minute
 )
 
+// Keeps track of the found titles and timeouts.
+type titleTracker struct {
+	titleChannel  chan<- string
+	timeout       time.Duration
+	lastTitle     string
+	lastTitleTime time.Time
+	startTime     time.Time
+}
+
 // Opens icy stream and searches for the song title. Song and title will be pushed to the titleChannel.
 func Open(urlString string, titleChannel chan<- string) error {
 	return OpenWithTimeout(urlString, titleChannel, time.Hour*876000)
@@ -21,66 +62,176 @@ func Open(urlString string, titleChannel chan<- string) error {
 
 // Opens icy stream and searches for the song title. Song and title will be pushed to the titleChannel.
 func OpenWithTimeout(urlString string, titleChannel chan<- string, timeout time.Duration) error {
+	_, err := Listen(urlString, titleChannel, timeout)
+	return err
+}
+
+// Opens the stream and searches for the song title using the best available method:
+// - HLS playlist: ID3 timed metadata in the segments,
+// - stream with icy-metaint header: metadata blocks,
+// - otherwise Icecast or Shoutcast status page if available, or searching for StreamTitle in the stream.
+// Song and title will be pushed to the titleChannel. Returns the method that was used and the error that stopped listening,
+// ErrTitleTimeout and ErrJobTimeout are wrapped in the returned error when the timeouts were reached.
+func Listen(urlString string, titleChannel chan<- string, timeout time.Duration) (Method, error) {
 	glog.V(1).Infof("Starting stream %q...", urlString)
+	tracker := newTitleTracker(titleChannel, timeout)
 
 	client := &http.Client{}
 	req, err := http.NewRequest("GET", urlString, nil)
 	if err != nil {
-		return err
+		return MethodNone, err
 	}
 	req.Header.Add("Icy-MetaData", "1")
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return MethodNone, err
 	}
 	defer resp.Body.Close()
 
-	reader := bufio.NewReader(resp.Body)
-	lastTitle := ""
-	lastTitleTime := time.Now()
-	startTime := time.Now()
+	if isHls(urlString, resp.Header.Get("Content-Type")) {
+		glog.V(1).Infof("Reading HLS stream %q.", urlString)
+		return MethodHls, readHls(client, resp, tracker)
+	}
+
+	metaInt, err := strconv.Atoi(resp.Header.Get("icy-metaint"))
+	if err == nil && metaInt > 0 {
+		glog.V(1).Infof("Reading %q with icy-metaint %d.", urlString, metaInt)
+		return MethodMetaInt, readMetaInt(resp.Body, metaInt, tracker)
+	}
+
+	method, poll := findStatusPage(urlString)
+	if poll != nil {
+		// Status page is used, stream is not needed anymore.
+		resp.Body.Close()
+		glog.V(1).Infof("Reading %q using %v.", urlString, method)
+		return method, pollStatusPage(poll, tracker)
+	}
+
+	glog.V(1).Infof("Searching for StreamTitle in %q.", urlString)
+	return MethodScan, readScan(resp.Body, tracker)
+}
+
+func (m Method) String() string {
+	switch m {
+	case MethodNone:
+		return "none"
+	case MethodMetaInt:
+		return "icy-metaint"
+	case MethodScan:
+		return "stream scan"
+	case MethodHls:
+		return "HLS ID3"
+	case MethodIcecastStatus:
+		return "Icecast status page"
+	case MethodShoutcastStatus:
+		return "Shoutcast status page"
+	default:
+		return "unknown"
+	}
+}
+
+// Reads metadata blocks: every metaInt bytes of audio there is one byte with the length
+// of the metadata block divided by 16 followed by the metadata.
+func readMetaInt(body io.Reader, metaInt int, tracker *titleTracker) error {
+	reader := bufio.NewReader(body)
+	lengthByte := make([]byte, 1)
+	for {
+		_, err := io.CopyN(ioutil.Discard, reader, int64(metaInt))
+		if err != nil {
+			return err
+		}
+
+		_, err = io.ReadFull(reader, lengthByte)
+		if err != nil {
+			return err
+		}
+
+		if lengthByte[0] > 0 {
+			metadata := make([]byte, int(lengthByte[0])*16)
+			_, err = io.ReadFull(reader, metadata)
+			if err != nil {
+				return err
+			}
+			glog.V(10).Infof("%q", metadata)
+			t := findStreamTitle(metadata)
+			if t != nil {
+				tracker.found(*t)
+			}
+		}
+
+		err = tracker.check()
+		if err != nil {
+			return err
+		}
+	}
+}
 
+// Searches for the StreamTitle in the stream without icy-metaint header.
+func readScan(body io.Reader, tracker *titleTracker) error {
+	reader := bufio.NewReader(body)
+	var err error
 	for err == nil {
 		var b []byte
 		b, err = reader.ReadBytes(';')
 		glog.V(10).Infof("%v", b)
 		t := findStreamTitle(b)
-		if t != nil && *t != "" && *t != lastTitle {
-			glog.V(1).Infof("New title found: %q.", *t)
-			titleChannel <- *t
-			lastTitle = *t
-			lastTitleTime = time.Now()
+		if t != nil {
+			tracker.found(*t)
 		}
-		if lastTitleTime.Add(titleTimeout).Before(time.Now()) {
-			err = fmt.Errorf("title timeout, last title found: %v", lastTitleTime.Format("2006-01-02 15:04:05"))
-		}
-		if startTime.Add(timeout).Before(time.Now()) {
-			err = fmt.Errorf("job timeout, last title found: %v", lastTitleTime.Format("2006-01-02 15:04:05"))
+		if err == nil {
+			err = tracker.check()
 		}
 	}
 	return err
 }
 
+// Finds StreamTitle='Artist - Title'; in the data. Title can contain ' and ; characters, so the
+// title ends at the first "';" sequence or at the end of the data.
 func findStreamTitle(b []byte) *string {
-	for i := 0; i < len(b)-len(streamTitle); i++ {
-		if streamTitle[0] == b[i] {
-			if isStreamTitle(b, i) {
-				// StreamTitle='Artist - Title';
-				// Remove "StreamTitle='" and
-				// last two characters (';)
-				res := string(b[i+len(streamTitle) : len(b)-2])
-				return &res
-			}
-		}
+	i := bytes.Index(b, streamTitle)
+	if i == -1 {
+		return nil
 	}
-	return nil
+
+	title := b[i+len(streamTitle):]
+	end := bytes.Index(title, streamTitleEnd)
+	if end != -1 {
+		title = title[:end]
+	} else {
+		// Metadata blocks are padded with zeros
+		title = bytes.TrimRight(title, "\x00")
+		title = bytes.TrimSuffix(title, []byte("'"))
+	}
+	res := strings.TrimSpace(string(title))
+	return &res
 }
 
-func isStreamTitle(b []byte, pos int) bool {
-	for i := 0; i < len(streamTitle); i++ {
-		if streamTitle[i] != b[pos+i] {
-			return false
-		}
+func newTitleTracker(titleChannel chan<- string, timeout time.Duration) *titleTracker {
+	return &titleTracker{
+		titleChannel:  titleChannel,
+		timeout:       timeout,
+		lastTitleTime: time.Now(),
+		startTime:     time.Now(),
+	}
+}
+
+// Sends the title to the channel if it is different than the previous one.
+func (t *titleTracker) found(title string) {
+	if title != "" && title != t.lastTitle {
+		glog.V(1).Infof("New title found: %q.", title)
+		t.titleChannel <- title
+		t.lastTitle = title
+		t.lastTitleTime = time.Now()
+	}
+}
+
+// Returns error when title or job timeout was reached.
+func (t *titleTracker) check() error {
+	if t.lastTitleTime.Add(titleTimeout).Before(time.Now()) {
+		return fmt.Errorf("%w, last title found: %v", ErrTitleTimeout, t.lastTitleTime.Format("2006-01-02 15:04:05"))
+	}
+	if t.startTime.Add(t.timeout).Before(time.Now()) {
+		return fmt.Errorf("%w, last title found: %v", ErrJobTimeout, t.lastTitleTime.Format("2006-01-02 15:04:05"))
 	}
-	return true
+	return nil
 }
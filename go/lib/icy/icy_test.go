@@ -1,7 +1,12 @@
 package icy
 
 import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 type streamTitleTest struct {
@@ -30,3 +35,222 @@ func TestFindTitle(t *testing.T) {
 		}
 	}
 }
+
+func TestFindTitle_specialCharacters(t *testing.T) {
+	for _, s := range []streamTitleTest{
+		{
+			in:   []byte("StreamTitle='Guns N' Roses - Live;Die';StreamUrl='';"),
+			want: "Guns N' Roses - Live;Die",
+		},
+		{
+			in:   []byte("StreamTitle='A - B'\x00\x00\x00"),
+			want: "A - B",
+		},
+	} {
+		got := findStreamTitle(s.in)
+		if *got != s.want {
+			t.Errorf("got: %q, want: %q", *got, s.want)
+		}
+	}
+}
+
+func TestFindTitle_notFound(t *testing.T) {
+	got := findStreamTitle([]byte("StreamUrl='';"))
+	if got != nil {
+		t.Errorf("got: %q, want: nil", *got)
+	}
+}
+
+// Creates icy stream with metadata every 8 bytes.
+func makeMetaIntStream(titles ...string) []byte {
+	var result []byte
+	for _, title := range titles {
+		result = append(result, []byte("audio...")...)
+		if len(title) == 0 {
+			result = append(result, 0)
+			continue
+		}
+		metadata := []byte("StreamTitle='" + title + "';")
+		blocks := (len(metadata) + 15) / 16
+		result = append(result, byte(blocks))
+		result = append(result, metadata...)
+		result = append(result, make([]byte, blocks*16-len(metadata))...)
+	}
+	return result
+}
+
+func readTitles(ch <-chan string) []string {
+	result := make([]string, 0)
+	for t := range ch {
+		result = append(result, t)
+	}
+	return result
+}
+
+func checkTitles(t *testing.T, got []string, want ...string) {
+	if len(got) != len(want) {
+		t.Fatalf("got: %q, want: %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got: %q, want: %q", got, want)
+		}
+	}
+}
+
+func TestListen_metaInt(t *testing.T) {
+	stream := makeMetaIntStream("A - B", "", "A - B", "Guns N' Roses - Live;Die", "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Icy-MetaData") != "1" {
+			t.Errorf("Icy-MetaData header not set")
+		}
+		w.Header().Set("icy-metaint", "8")
+		w.Write(stream)
+	}))
+	defer server.Close()
+
+	ch := make(chan string, 10)
+	method, err := Listen(server.URL, ch, time.Hour)
+	close(ch)
+	if method != MethodMetaInt || err != io.EOF {
+		t.Errorf("got: %v %v, want: %v %v", method, err, MethodMetaInt, io.EOF)
+	}
+	checkTitles(t, readTitles(ch), "A - B", "Guns N' Roses - Live;Die")
+}
+
+func TestListen_scan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stream" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write([]byte("abc;StreamTitle='A - B';def;StreamTitle='C - D';"))
+	}))
+	defer server.Close()
+
+	ch := make(chan string, 10)
+	method, err := Listen(server.URL+"/stream", ch, time.Hour)
+	close(ch)
+	if method != MethodScan || err != io.EOF {
+		t.Errorf("got: %v %v, want: %v %v", method, err, MethodScan, io.EOF)
+	}
+	checkTitles(t, readTitles(ch), "A - B", "C - D")
+}
+
+func TestListen_statusPages(t *testing.T) {
+	for _, test := range []struct {
+		page   string
+		body   string
+		method Method
+		want   string
+	}{
+		{
+			page:   "/status-json.xsl",
+			body:   `{"icestats":{"source":[{"listenurl":"http://x/other","title":"Other"},{"listenurl":"http://x/stream","artist":"A","title":"B"}]}}`,
+			method: MethodIcecastStatus,
+			want:   "A - B",
+		},
+		{
+			page:   "/status-json.xsl",
+			body:   `{"icestats":{"source":{"listenurl":"http://x/other","title":"C - D"}}}`,
+			method: MethodIcecastStatus,
+			want:   "C - D",
+		},
+		{
+			page:   "/7.html",
+			body:   `<html><body>1,1,5,100,2,128,Artist, with comma - T&amp;tle</body></html>`,
+			method: MethodShoutcastStatus,
+			want:   "Artist, with comma - T&tle",
+		},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == test.page {
+				w.Write([]byte(test.body))
+			} else if r.URL.Path != "/stream" {
+				w.WriteHeader(404)
+			}
+		}))
+
+		ch := make(chan string, 10)
+		// Job timeout stops listening after the first poll.
+		method, err := Listen(server.URL+"/stream", ch, time.Nanosecond)
+		close(ch)
+		server.Close()
+		if method != test.method || !errors.Is(err, ErrJobTimeout) {
+			t.Errorf("got: %v %v, want: %v %v", method, err, test.method, ErrJobTimeout)
+		}
+		checkTitles(t, readTitles(ch), test.want)
+	}
+}
+
+func TestParseIcecastStatus(t *testing.T) {
+	for _, test := range []struct {
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			body: `{"icestats":{"source":[{"listenurl":"http://x/other","title":"Other"},{"listenurl":"http://x/stream","artist":"A","title":"B"}]}}`,
+			want: "A - B",
+		},
+		{
+			// Only source is used even if the mount is different.
+			body: `{"icestats":{"source":[{"listenurl":"http://x/other","title":"C - D"}]}}`,
+			want: "C - D",
+		},
+		{
+			// Title of the other station is not returned.
+			body:    `{"icestats":{"source":[{"listenurl":"http://x/other","title":"Other"},{"listenurl":"http://x/third","title":"Third"}]}}`,
+			wantErr: true,
+		},
+		{
+			body:    `{"icestats":{"source":[]}}`,
+			wantErr: true,
+		},
+	} {
+		got, err := parseIcecastStatus([]byte(test.body), "/stream")
+		if got != test.want || (err != nil) != test.wantErr {
+			t.Errorf("parseIcecastStatus(%v) got: %q %v, want: %q (error: %v)", test.body, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+// Creates ID3v2.3 tag with the title and artist.
+func makeId3(artist string, title string) []byte {
+	var body []byte
+	for id, value := range map[string]string{"TPE1": artist, "TIT2": title} {
+		size := len(value) + 1
+		body = append(body, []byte(id)...)
+		body = append(body, 0, 0, 0, byte(size), 0, 0, 3)
+		body = append(body, []byte(value)...)
+	}
+	return append([]byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, byte(len(body))}, body...)
+}
+
+func TestListen_hls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=128000\nmedia/playlist.m3u8\n"))
+		case "/media/playlist.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:7\n#EXTINF:1,\nseg1.aac\n#EXTINF:1,\nseg2.aac\n#EXTINF:1,\n/seg3.ts\n#EXT-X-ENDLIST\n"))
+		case "/media/seg1.aac":
+			w.Write(append(makeId3("A", "B"), []byte("audio")...))
+		case "/media/seg2.aac":
+			w.Write([]byte("audio without tag"))
+		case "/seg3.ts":
+			w.Write(append([]byte("ts packets"), makeId3("C", "D")...))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	ch := make(chan string, 10)
+	method, err := Listen(server.URL+"/master.m3u8", ch, time.Hour)
+	close(ch)
+	if method != MethodHls || err == nil {
+		t.Errorf("got: %v %v, want: %v and end of playlist error", method, err, MethodHls)
+	}
+	checkTitles(t, readTitles(ch), "A - B", "C - D")
+}
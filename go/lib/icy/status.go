@@ -0,0 +1,177 @@
+package icy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	statusClient       = &http.Client{Timeout: 10 * time.Second}
+	statusPollInterval = 15 * time.Second
+	// Listening is stopped after that many consecutive status page errors.
+	maxStatusErrors = 5
+)
+
+type icecastStatus struct {
+	Icestats icecastStats `json:"icestats"`
+}
+
+type icecastStats struct {
+	// Single source object or array of sources
+	Source json.RawMessage `json:"source"`
+}
+
+type icecastSource struct {
+	ListenUrl string `json:"listenurl"`
+	Artist    string `json:"artist"`
+	Title     string `json:"title"`
+}
+
+// Returns the status page method and the function returning current title, or nil function if no status page is available.
+func findStatusPage(streamUrl string) (Method, func() (string, error)) {
+	u, err := url.Parse(streamUrl)
+	if err != nil {
+		return MethodNone, nil
+	}
+
+	icecastUrl := u.Scheme + "://" + u.Host + "/status-json.xsl"
+	icecast := func() (string, error) {
+		body, err := getStatusPage(icecastUrl)
+		if err != nil {
+			return "", err
+		}
+		return parseIcecastStatus(body, u.Path)
+	}
+	_, err = icecast()
+	if err == nil {
+		return MethodIcecastStatus, icecast
+	}
+	glog.V(2).Infof("Icecast status page not available: %v", err)
+
+	shoutcastUrl := u.Scheme + "://" + u.Host + "/7.html"
+	shoutcast := func() (string, error) {
+		body, err := getStatusPage(shoutcastUrl)
+		if err != nil {
+			return "", err
+		}
+		return parseShoutcastStatus(body)
+	}
+	_, err = shoutcast()
+	if err == nil {
+		return MethodShoutcastStatus, shoutcast
+	}
+	glog.V(2).Infof("Shoutcast status page not available: %v", err)
+
+	return MethodNone, nil
+}
+
+func pollStatusPage(poll func() (string, error), tracker *titleTracker) error {
+	errors := 0
+	for {
+		title, err := poll()
+		if err != nil {
+			errors++
+			glog.V(1).Infof("Status page error %d: %v", errors, err)
+			if errors >= maxStatusErrors {
+				return err
+			}
+		} else {
+			errors = 0
+			tracker.found(title)
+		}
+
+		err = tracker.check()
+		if err != nil {
+			return err
+		}
+		time.Sleep(statusPollInterval)
+	}
+}
+
+func getStatusPage(statusUrl string) ([]byte, error) {
+	req, err := http.NewRequest("GET", statusUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Shoutcast returns the status page only for browsers.
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+
+	resp, err := statusClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%v response code: %v", statusUrl, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Returns the title of the source with the given mount point. When the server has only one source its title
+// is returned, otherwise it is an error if the mount is not found, titles of the other stations are not used.
+func parseIcecastStatus(body []byte, mount string) (string, error) {
+	var status icecastStatus
+	err := json.Unmarshal(body, &status)
+	if err != nil {
+		return "", err
+	}
+
+	var sources []icecastSource
+	err = json.Unmarshal(status.Icestats.Source, &sources)
+	if err != nil {
+		var source icecastSource
+		err = json.Unmarshal(status.Icestats.Source, &source)
+		if err != nil {
+			return "", fmt.Errorf("could not parse icecast sources: %v", err)
+		}
+		sources = []icecastSource{source}
+	}
+	if len(sources) == 0 {
+		return "", fmt.Errorf("no icecast sources")
+	}
+
+	var result *icecastSource
+	if len(sources) == 1 {
+		result = &sources[0]
+	}
+	for i, s := range sources {
+		u, err := url.Parse(s.ListenUrl)
+		if err == nil && len(mount) > 0 && u.Path == mount {
+			result = &sources[i]
+			break
+		}
+	}
+	if result == nil {
+		return "", fmt.Errorf("mount %q not found in %d icecast sources", mount, len(sources))
+	}
+
+	if len(result.Artist) > 0 {
+		return result.Artist + " - " + result.Title, nil
+	}
+	return result.Title, nil
+}
+
+// Parses Shoutcast v1 7.html page:
+// <html><body>current listeners,status,peak,max,unique,bitrate,title</body></html>
+func parseShoutcastStatus(body []byte) (string, error) {
+	s := string(body)
+	start := strings.Index(s, "<body>")
+	end := strings.Index(s, "</body>")
+	if start == -1 || end == -1 || end < start {
+		return "", fmt.Errorf("invalid shoutcast status page")
+	}
+
+	fields := strings.SplitN(s[start+len("<body>"):end], ",", 7)
+	if len(fields) != 7 {
+		return "", fmt.Errorf("invalid shoutcast status: %q", s[start:end])
+	}
+	return html.UnescapeString(strings.TrimSpace(fields[6])), nil
+}
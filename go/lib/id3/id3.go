@@ -0,0 +1,221 @@
+// Package id3 contains minimal ID3v2 tag parser.
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+const headerSize = 10
+
+var header = []byte("ID3")
+
+type Tag struct {
+	// Major version of the tag (2, 3 or 4).
+	Version byte
+	// Size of the tag including header.
+	Size int
+	// Raw frame content by the frame id, only the first frame with the given id is stored
+	// except of TXXX frames that are stored by the description (e.g. "TXXX:StreamTitle").
+	Frames map[string][]byte
+}
+
+// Returns true if the data starts with ID3v2 header.
+func HasHeader(b []byte) bool {
+	return len(b) >= headerSize && bytes.Equal(b[:3], header) && b[3] >= 2 && b[3] <= 4
+}
+
+// Returns size of the tag (including header) starting at the beginning of the data or 0 if data does not start with tag.
+func TagSize(b []byte) int {
+	if !HasHeader(b) {
+		return 0
+	}
+	return headerSize + syncsafe(b[6:10])
+}
+
+// Finds and parses the first ID3v2 tag in the data. Returns nil if tag was not found.
+func Find(b []byte) *Tag {
+	offset := 0
+	for {
+		i := bytes.Index(b[offset:], header)
+		if i == -1 {
+			return nil
+		}
+		t, err := Parse(b[offset+i:])
+		if err == nil {
+			return t
+		}
+		offset = offset + i + 1
+	}
+}
+
+// Parses ID3v2 tag, data should start with the tag header.
+func Parse(b []byte) (*Tag, error) {
+	if !HasHeader(b) {
+		return nil, fmt.Errorf("ID3 header not found")
+	}
+
+	size := TagSize(b)
+	if size > len(b) {
+		return nil, fmt.Errorf("ID3 tag truncated: %d bytes, want %d", len(b), size)
+	}
+
+	t := &Tag{
+		Version: b[3],
+		Size:    size,
+		Frames:  make(map[string][]byte),
+	}
+	flags := b[5]
+	data := b[headerSize:size]
+
+	// Extended header
+	if flags&0x40 != 0 && len(data) >= 4 {
+		extSize := int(binary.BigEndian.Uint32(data[:4])) + 4
+		if t.Version == 4 {
+			extSize = syncsafe(data[:4])
+		}
+		if extSize > len(data) {
+			return nil, fmt.Errorf("invalid extended header size: %d", extSize)
+		}
+		data = data[extSize:]
+	}
+
+	idSize, frameHeaderSize := 4, 10
+	if t.Version == 2 {
+		idSize, frameHeaderSize = 3, 6
+	}
+
+	for len(data) >= frameHeaderSize && data[0] != 0 {
+		id := string(data[:idSize])
+		var frameSize int
+		switch t.Version {
+		case 2:
+			frameSize = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(data[4:8]))
+		default:
+			frameSize = syncsafe(data[4:8])
+		}
+		if frameSize < 0 || frameHeaderSize+frameSize > len(data) {
+			return nil, fmt.Errorf("invalid size of %q frame: %d", id, frameSize)
+		}
+
+		content := data[frameHeaderSize : frameHeaderSize+frameSize]
+		if id == "TXXX" || id == "TXX" {
+			description, value := splitUserText(content)
+			id = id + ":" + description
+			content = value
+		}
+		if _, ok := t.Frames[id]; !ok {
+			t.Frames[id] = content
+		}
+		data = data[frameHeaderSize+frameSize:]
+	}
+	return t, nil
+}
+
+// Returns the decoded text of the text frame (e.g. "TIT2" or "TXXX:description").
+func (t *Tag) Text(id string) string {
+	content, ok := t.Frames[id]
+	if !ok || len(content) == 0 {
+		return ""
+	}
+	return decodeText(content[0], content[1:])
+}
+
+func (t *Tag) Artist() string {
+	if t.Version == 2 {
+		return t.Text("TP1")
+	}
+	return t.Text("TPE1")
+}
+
+func (t *Tag) Title() string {
+	if t.Version == 2 {
+		return t.Text("TT2")
+	}
+	return t.Text("TIT2")
+}
+
+// Returns length in milliseconds stored in the tag or 0 if it is not set.
+func (t *Tag) LengthMs() int64 {
+	id := "TLEN"
+	if t.Version == 2 {
+		id = "TLE"
+	}
+	var result int64
+	_, err := fmt.Sscanf(t.Text(id), "%d", &result)
+	if err != nil {
+		return 0
+	}
+	return result
+}
+
+// Splits TXXX frame content into description and the value (the value keeps the encoding byte).
+func splitUserText(content []byte) (string, []byte) {
+	if len(content) == 0 {
+		return "", content
+	}
+	encoding := content[0]
+	terminator := []byte{0}
+	if encoding == 1 || encoding == 2 {
+		terminator = []byte{0, 0}
+	}
+	for i := 1; i+len(terminator) <= len(content); i += len(terminator) {
+		if bytes.Equal(content[i:i+len(terminator)], terminator) {
+			description := decodeText(encoding, content[1:i])
+			value := append([]byte{encoding}, content[i+len(terminator):]...)
+			return description, value
+		}
+	}
+	return decodeText(encoding, content[1:]), []byte{encoding}
+}
+
+func decodeText(encoding byte, b []byte) string {
+	var result string
+	switch encoding {
+	case 0:
+		// ISO-8859-1
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		result = string(runes)
+	case 1, 2:
+		result = decodeUtf16(encoding, b)
+	default:
+		result = string(b)
+	}
+	// Multiple values are separated by the null character.
+	return strings.Join(strings.FieldsFunc(result, func(r rune) bool { return r == 0 }), ", ")
+}
+
+func decodeUtf16(encoding byte, b []byte) string {
+	bigEndian := encoding == 2
+	if len(b) >= 2 {
+		if b[0] == 0xff && b[1] == 0xfe {
+			bigEndian = false
+			b = b[2:]
+		} else if b[0] == 0xfe && b[1] == 0xff {
+			bigEndian = true
+			b = b[2:]
+		}
+	}
+
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = binary.BigEndian.Uint16(b[2*i:])
+		} else {
+			u[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+	}
+	return string(utf16.Decode(u))
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
@@ -0,0 +1,100 @@
+package id3
+
+import (
+	"testing"
+)
+
+// Creates ID3v2.3 or ID3v2.4 tag with the given frames.
+func makeTag(version byte, frames ...[]byte) []byte {
+	var body []byte
+	for _, f := range frames {
+		body = append(body, f...)
+	}
+	// some padding
+	body = append(body, 0, 0, 0, 0)
+	size := len(body)
+	result := []byte{'I', 'D', '3', version, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(result, body...)
+}
+
+func makeFrame(id string, content []byte) []byte {
+	size := len(content)
+	result := append([]byte(id), byte(size>>24), byte(size>>16), byte(size>>8), byte(size), 0, 0)
+	return append(result, content...)
+}
+
+func TestParse(t *testing.T) {
+	tag := makeTag(3,
+		makeFrame("TPE1", append([]byte{0}, []byte("Artist \xe9")...)),
+		// UTF-16 with BOM
+		makeFrame("TIT2", []byte{1, 0xff, 0xfe, 'T', 0, 'i', 0, 't', 0, 'l', 0, 'e', 0}),
+		makeFrame("TLEN", []byte{3, '1', '2', '3', '4', '5'}),
+		makeFrame("TXXX", append([]byte{3}, []byte("StreamTitle\x00A - B")...)),
+	)
+
+	got, err := Parse(tag)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Artist() != "Artist é" {
+		t.Errorf("Artist got: %q, want: %q", got.Artist(), "Artist é")
+	}
+	if got.Title() != "Title" {
+		t.Errorf("Title got: %q, want: %q", got.Title(), "Title")
+	}
+	if got.LengthMs() != 12345 {
+		t.Errorf("LengthMs got: %v, want: 12345", got.LengthMs())
+	}
+	if got.Text("TXXX:StreamTitle") != "A - B" {
+		t.Errorf("TXXX got: %q, want: %q", got.Text("TXXX:StreamTitle"), "A - B")
+	}
+	if got.Size != len(tag) || TagSize(tag) != len(tag) {
+		t.Errorf("Size got: %v, want: %v", got.Size, len(tag))
+	}
+}
+
+func TestParse_version4(t *testing.T) {
+	tag := makeTag(4,
+		// Multiple values separated by null
+		makeFrame("TPE1", append([]byte{3}, []byte("A1\x00A2")...)),
+		makeFrame("TIT2", append([]byte{3}, []byte("Title")...)),
+	)
+
+	got, err := Parse(tag)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Artist() != "A1, A2" || got.Title() != "Title" || got.LengthMs() != 0 {
+		t.Errorf("got: %q - %q (%v)", got.Artist(), got.Title(), got.LengthMs())
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tag := makeTag(3, makeFrame("TIT2", append([]byte{3}, []byte("Title")...)))
+	for _, b := range [][]byte{
+		nil,
+		[]byte("ID3"),
+		[]byte("abcdefghijklmn"),
+		tag[:len(tag)-1],
+	} {
+		_, err := Parse(b)
+		if err == nil {
+			t.Errorf("Parse(%q) got: nil, want: error", b)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	tag := makeTag(3, makeFrame("TIT2", append([]byte{3}, []byte("Title")...)))
+	data := append([]byte("some ID3 garbage before"), tag...)
+	data = append(data, []byte("and after")...)
+
+	got := Find(data)
+	if got == nil || got.Title() != "Title" {
+		t.Errorf("Find got: %v, want: Title", got)
+	}
+
+	if Find([]byte("no tag")) != nil {
+		t.Errorf("Find got: tag, want: nil")
+	}
+}
@@ -81,6 +81,7 @@ type Email struct {
 	To         string `json:"To"`
 	Subject    string `json:"Subject"`
 	Text       string `json:"body-plain"`
+	Html       string `json:"body-html"`
 	References string `json:"References"`
 	MessageId  string `json:"Message-Id"`
 	InReplyTo  string `json:"In-Reply-To"`
@@ -188,6 +188,9 @@ func createPayload(email Email) url.Values {
 	payload.Add("from", email.From)
 	payload.Add("subject", email.Subject)
 	payload.Add("text", email.Text)
+	if len(email.Html) > 0 {
+		payload.Add("html", email.Html)
+	}
 	if len(email.To) > 0 {
 		payload.Add("to", email.To)
 	}
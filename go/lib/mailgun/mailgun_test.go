@@ -0,0 +1,20 @@
+package mailgun
+
+import (
+	"testing"
+)
+
+func TestCreatePayload(t *testing.T) {
+	payload := createPayload(Email{From: "a@b.c", Subject: "s", Text: "text"})
+	if _, ok := payload["html"]; ok {
+		t.Errorf("html got: %q, want: no html", payload.Get("html"))
+	}
+
+	payload = createPayload(Email{From: "a@b.c", Subject: "s", Text: "text", Html: "<p>text</p>"})
+	if got := payload.Get("html"); got != "<p>text</p>" {
+		t.Errorf("html got: %q, want: %q", got, "<p>text</p>")
+	}
+	if got := payload.Get("text"); got != "text" {
+		t.Errorf("text got: %q, want: %q", got, "text")
+	}
+}
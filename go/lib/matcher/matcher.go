@@ -0,0 +1,192 @@
+// Package matcher calculates how well the track from the streaming service matches the song from radio.
+package matcher
+
+import (
+	"github.com/golang/glog"
+	"regexp"
+	"strings"
+)
+
+// Track of any streaming service.
+type Track interface {
+	Artist() string
+	Title() string
+}
+
+type simpleTrack struct {
+	artist string
+	title  string
+}
+
+func (t simpleTrack) Artist() string {
+	return t.artist
+}
+
+func (t simpleTrack) Title() string {
+	return t.title
+}
+
+// Creates the track for the services without their own track type.
+func NewTrack(artist string, title string) Track {
+	return simpleTrack{artist, title}
+}
+
+var wordMatcher = regexp.MustCompile("[\\p{L}\\d]+")
+
+var TerribleSongNames = []string{
+	"acapella",
+	"acappella",
+	"as made famous",
+	"in the style of",
+	"in style of",
+	"karaoke",
+	"made famous by",
+	"originally performed by",
+	"reprise",
+	"tribute",
+}
+
+var penaltyWords = []string{
+	"acoustic",
+	"instrumental",
+	"live",
+	"unplugged",
+	"remix",
+}
+
+var awardWords = []string{
+	"radio",
+	"remastered",
+	"single",
+}
+
+// This should contain the same things as "awardWords". These expressions will be removed from radio title to avoid matches:
+// "artist - song1 [radio edit]" == "artist - song2 [radio edit]"
+var awardExpressions = []string{
+	"radio edit",
+	"remastered",
+	"single edit",
+}
+
+var artistJoiners = []string{
+	"feat",
+	"vs",
+}
+
+// Calculates match ratio between song name stored in a string from radio (e.g. "Artist - Some song")
+// and a given track. Returns match ratio from 0 to 100, anything below 75 is bad quality,
+// while 50 and less is probably worthless.
+func MatchRatio(radio string, track Track) int {
+	radioArtistTitle := strings.SplitN(radio, " - ", 2)
+	if len(radioArtistTitle) != 2 {
+		glog.Warningf("Could not split artist+title: %q.", radio)
+		return 0
+	}
+
+	trackTitle := strings.ToLower(track.Title())
+	trackArtist := strings.ToLower(track.Artist())
+	if IsTerrible(trackArtist + " - " + trackTitle) {
+		glog.V(3).Infof("Terrible name: %v - %v", track.Artist(), track.Title())
+		return 0
+	}
+
+	radioTitle := strings.ToLower(radioArtistTitle[1])
+	radioArtist := strings.ToLower(radioArtistTitle[0])
+	for _, awardExpression := range awardExpressions {
+		radioTitle = strings.Replace(radioTitle, awardExpression, "", -1)
+	}
+
+	radioArtistArray := wordMatcher.FindAllString(radioArtist, -1)
+	radioTitleArray := wordMatcher.FindAllString(radioTitle, -1)
+	trackArtistArray := wordMatcher.FindAllString(trackArtist, -1)
+	trackTitleArray := wordMatcher.FindAllString(trackTitle, -1)
+
+	artistMatch := calculateMatchRatioArray(radioArtistArray, trackArtistArray)
+	titleMatch := calculateMatchRatioArray(radioTitleArray, trackTitleArray)
+	result := (artistMatch + titleMatch) / 2
+
+	if result < 100 {
+		// Trying to match all the words but in reverse - if everything from track is in
+		// radio array, it means that we are still good.
+		combinedMatch := calculateMatchRatioArray(
+			append(trackArtistArray, trackTitleArray...),
+			append(radioArtistArray, radioTitleArray...))
+		glog.V(3).Infof("Result less than 100, trying combined match %v.", combinedMatch)
+		if combinedMatch == 100 {
+			// 99 so we would not override the proper artist/title match.
+			result = 99
+		}
+	}
+	glog.V(3).Infof("CalculateMatchRatio result: %v.", result)
+	return result
+}
+
+// Returns true if the "Artist - Title" contains one of the TerribleSongNames.
+func IsTerrible(artistTitle string) bool {
+	artistTitle = strings.ToLower(artistTitle)
+	for _, terribleName := range TerribleSongNames {
+		if strings.Contains(artistTitle, terribleName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns match ratio of string arrays.
+func calculateMatchRatioArray(radio []string, track []string) int {
+	if len(radio) == 0 || len(track) == 0 {
+		glog.V(3).Infof("radio: %v, track: %v, result: 0", radio, track)
+		return 0
+	}
+
+	result := 0
+	for _, r := range radio {
+		if contains(r, track) || contains(r, artistJoiners) {
+			result = result + 100
+		}
+	}
+	result = result / len(radio)
+	// penalty for size difference
+	result = max(0, result-max(0, 5*(len(track)-len(radio))))
+	glog.V(3).Infof("radio: %v, track: %v, initial result: %v", radio, track, result)
+
+	for _, penalty := range penaltyWords {
+		if !contains(penalty, radio) && contains(penalty, track) {
+			result = max(0, result-10)
+			glog.V(3).Infof("radio: %v, track: %v, penalty for: %q", radio, track, penalty)
+		}
+	}
+
+	// Award is cancelling size difference penalty
+	for _, award := range awardWords {
+		if !contains(award, radio) && contains(award, track) {
+			result = min(100, result+5)
+			glog.V(3).Infof("radio: %v, track: %v, award for: %q", radio, track, award)
+		}
+	}
+	glog.V(3).Infof("radio: %v, track: %v, result: %v", radio, track, result)
+	return result
+}
+
+func contains(s string, list []string) bool {
+	for _, w := range list {
+		if w == s {
+			return true
+		}
+	}
+	return false
+}
+
+func min(i1 int, i2 int) int {
+	if i1 > i2 {
+		return i2
+	}
+	return i1
+}
+
+func max(i1 int, i2 int) int {
+	if i1 > i2 {
+		return i1
+	}
+	return i2
+}
@@ -0,0 +1,31 @@
+package matcher
+
+import (
+	"testing"
+)
+
+func TestMatchRatio(t *testing.T) {
+	for _, test := range []struct {
+		radio string
+		track Track
+		want  int
+	}{
+		{"Artist - Song", NewTrack("Artist", "Song"), 100},
+		{"Artist - Song", NewTrack("artist", "song"), 100},
+		{"Artist - Song", NewTrack("Artist", "Song KARAOKE"), 0},
+		{"Song", NewTrack("Artist", "Song"), 0},
+		{"Artist - Song", NewTrack("Other", "Thing"), 0},
+		// Swapped artist and title.
+		{"Song - Artist", NewTrack("Artist", "Song"), 99},
+	} {
+		if got := MatchRatio(test.radio, test.track); got != test.want {
+			t.Errorf("MatchRatio(%q, %v) got: %v, want: %v", test.radio, test.track, got, test.want)
+		}
+	}
+}
+
+func TestIsTerrible(t *testing.T) {
+	if !IsTerrible("Singers - Blank Space (In The Style Of Taylor Swift)") || IsTerrible("Taylor Swift - Blank Space") {
+		t.Errorf("IsTerrible() got wrong result")
+	}
+}
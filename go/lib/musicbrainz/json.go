@@ -0,0 +1,68 @@
+package musicbrainz
+
+// Structures used to parse JSON returned by MusicBrainz API
+
+import (
+	"strings"
+)
+
+type SearchResponse struct {
+	Recordings []RecordingJson `json:"recordings"`
+}
+
+type RecordingJson struct {
+	Id           string         `json:"id"`
+	Score        int            `json:"score"`
+	Title        string         `json:"title"`
+	ArtistCredit []ArtistCredit `json:"artist-credit"`
+	Isrcs        []string       `json:"isrcs"`
+}
+
+type ArtistCredit struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+}
+
+// Recording found in MusicBrainz. Exported fields are stored in the local cache.
+type Recording struct {
+	Id     string
+	Artist string
+	Title  string
+	Isrcs  []string
+	// Search score 0-100
+	Score int
+}
+
+func (r RecordingJson) artist() string {
+	var buf strings.Builder
+	for _, a := range r.ArtistCredit {
+		buf.WriteString(a.Name)
+		buf.WriteString(a.JoinPhrase)
+	}
+	return buf.String()
+}
+
+func (r RecordingJson) recording() *Recording {
+	return &Recording{
+		Id:     r.Id,
+		Artist: r.artist(),
+		Title:  r.Title,
+		Isrcs:  r.Isrcs,
+		Score:  r.Score,
+	}
+}
+
+func (r *Recording) String() string {
+	if r == nil || len(r.Artist)+len(r.Title) == 0 {
+		return ""
+	}
+	return r.Artist + " - " + r.Title
+}
+
+// Returns the first ISRC of the recording or empty string.
+func (r *Recording) Isrc() string {
+	if r == nil || len(r.Isrcs) == 0 {
+		return ""
+	}
+	return r.Isrcs[0]
+}
@@ -0,0 +1,144 @@
+// Package musicbrainz resolves "Artist - Title" strings to MusicBrainz recordings.
+package musicbrainz
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/lib/ratelimit"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiUrl    = "https://musicbrainz.org/ws/2/recording"
+	userAgent = "birnenlabs-go/1.0 ( https://birnenlabs.com )"
+	cacheName = "musicbrainz-cache"
+)
+
+type MusicBrainz struct {
+	// Client with 1 qps limit as required by MusicBrainz
+	httpClient ratelimit.AnyClient
+	apiUrl     string
+	// Name of the cache file in the config directory, empty if cache should not be persisted.
+	cacheName string
+	// Search results, recording with empty Id is stored when nothing was found.
+	cache     map[string]Recording
+	cacheLock sync.RWMutex
+	// Serializes saving the cache, jobs finishing together save it concurrently.
+	saveLock sync.Mutex
+}
+
+// Creates MusicBrainz client with the cache loaded from $HOME/.config/musicbrainz-cache.gob.
+func New() *MusicBrainz {
+	m := &MusicBrainz{
+		httpClient: ratelimit.New(&http.Client{}, time.Second),
+		apiUrl:     apiUrl,
+		cacheName:  cacheName,
+		cache:      make(map[string]Recording),
+	}
+	err := conf.LoadConfigFromFile(m.cacheName, &m.cache)
+	if err != nil {
+		glog.Warningf("Could not load musicbrainz cache (%v), starting with empty one.", err)
+		m.cache = make(map[string]Recording)
+	}
+	return m
+}
+
+// Finds the best recording for the "Artist - Title" string. Returns nil if nothing was found.
+func (m *MusicBrainz) FindRecording(artistTitle string) (*Recording, error) {
+	key := strings.ToLower(artistTitle)
+
+	m.cacheLock.RLock()
+	cached, ok := m.cache[key]
+	m.cacheLock.RUnlock()
+	if ok {
+		glog.V(2).Infof("Found cached recording for %q: %q", artistTitle, cached.Id)
+		if len(cached.Id) == 0 {
+			return nil, nil
+		}
+		return &cached, nil
+	}
+
+	artistTitleArray := strings.SplitN(artistTitle, " - ", 2)
+	if len(artistTitleArray) != 2 {
+		return nil, fmt.Errorf("Could not split artist+title: %q", artistTitle)
+	}
+
+	recordings, err := m.search(artistTitleArray[0], artistTitleArray[1])
+	if err != nil {
+		return nil, err
+	}
+
+	result := Recording{}
+	if len(recordings) > 0 {
+		result = *recordings[0].recording()
+	}
+
+	m.cacheLock.Lock()
+	m.cache[key] = result
+	m.cacheLock.Unlock()
+
+	if len(result.Id) == 0 {
+		return nil, nil
+	}
+	return &result, nil
+}
+
+// Saves the cache to the config directory, the file is replaced atomically.
+func (m *MusicBrainz) SaveCache() error {
+	if len(m.cacheName) == 0 {
+		return nil
+	}
+
+	m.saveLock.Lock()
+	defer m.saveLock.Unlock()
+	m.cacheLock.RLock()
+	defer m.cacheLock.RUnlock()
+	return conf.SaveConfigToFileAtomic(m.cacheName, m.cache)
+}
+
+func (m *MusicBrainz) search(artist string, title string) ([]RecordingJson, error) {
+	query := fmt.Sprintf("artist:\"%s\" AND recording:\"%s\"", escape(artist), escape(title))
+	uri := fmt.Sprintf("%s?fmt=json&limit=5&query=%s", m.apiUrl, url.QueryEscape(query))
+
+	glog.V(1).Infof("MusicBrainz search url: %q.", uri)
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r = new(SearchResponse)
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(1).Infof("Found %v recordings for %q - %q.", len(r.Recordings), artist, title)
+	return r.Recordings, nil
+}
+
+// Escapes characters that have special meaning in the lucene query phrase.
+func escape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(s)
+}
@@ -0,0 +1,115 @@
+package musicbrainz
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const searchResponse = `{"recordings":[
+	{"id":"mbid-1","score":100,"title":"Blank Space","isrcs":["USCJY1431349"],
+	 "artist-credit":[{"name":"Taylor Swift","joinphrase":" feat. "},{"name":"Someone"}]},
+	{"id":"mbid-2","score":80,"title":"Blank Space (live)","artist-credit":[{"name":"Taylor Swift"}]}
+]}`
+
+func newTestMusicBrainz(requests *int) (*MusicBrainz, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.Header.Get("User-Agent") == "" {
+			w.WriteHeader(403)
+			return
+		}
+		if strings.Contains(r.URL.Query().Get("query"), "Blank Space") {
+			fmt.Fprint(w, searchResponse)
+		} else {
+			fmt.Fprint(w, `{"recordings":[]}`)
+		}
+	}))
+
+	return &MusicBrainz{
+		httpClient: &http.Client{},
+		apiUrl:     server.URL,
+		cache:      make(map[string]Recording),
+	}, server
+}
+
+func TestFindRecording(t *testing.T) {
+	requests := 0
+	m, server := newTestMusicBrainz(&requests)
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		r, err := m.FindRecording("Taylor Swift - Blank Space")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if r.Id != "mbid-1" || r.Isrc() != "USCJY1431349" || r.String() != "Taylor Swift feat. Someone - Blank Space" || r.Score != 100 {
+			t.Errorf("got: %+v, want: mbid-1", r)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requests got: %v, want: 1 (second result should be cached)", requests)
+	}
+}
+
+func TestFindRecording_notFound(t *testing.T) {
+	requests := 0
+	m, server := newTestMusicBrainz(&requests)
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		r, err := m.FindRecording("Unknown - Song")
+		if err != nil || r != nil {
+			t.Errorf("got: %v %v, want: nil nil", r, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requests got: %v, want: 1 (not found should be cached)", requests)
+	}
+}
+
+func TestFindRecording_invalid(t *testing.T) {
+	requests := 0
+	m, server := newTestMusicBrainz(&requests)
+	defer server.Close()
+
+	_, err := m.FindRecording("Song without artist")
+	if err == nil {
+		t.Errorf("got: nil, want: error")
+	}
+}
+
+func TestSaveCache_concurrent(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	requests := 0
+	m, server := newTestMusicBrainz(&requests)
+	defer server.Close()
+	m.cacheName = "musicbrainz-test-cache"
+	m.FindRecording("Taylor Swift - Blank Space")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.SaveCache(); err != nil {
+				t.Errorf("SaveCache() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	cache := make(map[string]Recording)
+	err := conf.LoadConfigFromFile(m.cacheName, &cache)
+	if err != nil || cache["taylor swift - blank space"].Id != "mbid-1" {
+		t.Errorf("Saved cache got: %v %v, want: mbid-1", cache, err)
+	}
+}
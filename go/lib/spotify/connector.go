@@ -68,6 +68,73 @@ func (s *connector) addToPlaylist(ctx context.Context, playlistId string, trackI
 	return nil
 }
 
+// Creates the private playlist of the current user, returns its id.
+func (s *connector) createPlaylist(ctx context.Context, name string) (string, error) {
+	url := "https://api.spotify.com/v1/me/playlists"
+	body, err := json.Marshal(map[string]interface{}{"name": name, "public": false})
+	if err != nil {
+		return "", err
+	}
+
+	glog.V(1).Infof("Create playlist url: %q.", url)
+	resp, err := s.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// 201 == created
+	if resp.StatusCode != 201 {
+		return "", fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var r struct {
+		Id string
+	}
+	err = json.Unmarshal(respBody, &r)
+	if err != nil {
+		return "", err
+	}
+	if len(r.Id) == 0 {
+		return "", fmt.Errorf("created playlist has no id")
+	}
+	return r.Id, nil
+}
+
+// Replaces the tracks of the playlist (PUT) or appends them (POST), at most 100 tracks can be sent at once.
+func (s *connector) sendPlaylistTracks(ctx context.Context, method string, playlistId string, trackIds []string) error {
+	url := fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks", playlistId)
+	uris := make([]string, len(trackIds))
+	for i, id := range trackIds {
+		uris[i] = "spotify:track:" + id
+	}
+	body, err := json.Marshal(map[string][]string{"uris": uris})
+	if err != nil {
+		return err
+	}
+
+	glog.V(1).Infof("%v %d playlist tracks url: %q.", method, len(trackIds), url)
+	r, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	resp, err := s.httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		return fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+	return nil
+}
+
 func (s *connector) removeFromPlaylist(ctx context.Context, playlistId string, trackId string) error {
 	url := fmt.Sprintf(
 		"https://api.spotify.com/v1/playlists/%s/tracks?uris=spotify:track:%s",
@@ -127,6 +194,7 @@ func (s *connector) listPlaylistUrl(ctx context.Context, nextUrl string) ([]Spot
 		}
 		nextUrl = r.Next
 		for _, playlistItem := range r.Items {
+			playlistItem.Track.AddedAt = playlistItem.AddedAt
 			result = append(result, playlistItem.Track)
 		}
 	}
@@ -166,6 +234,95 @@ func (s *connector) findTracks(ctx context.Context, query string) ([]SpotifyTrac
 	return r.Tracks.Items, nil
 }
 
+func (s *connector) getTrack(ctx context.Context, trackId string) (*SpotifyTrack, error) {
+	url := fmt.Sprintf(
+		"https://api.spotify.com/v1/tracks/%s?market=%s",
+		url.PathEscape(trackId), s.market)
+
+	glog.V(1).Infof("Get track url: %q.", url)
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r = new(SpotifyTrack)
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Returns artists with genres, at most 50 ids can be requested at once.
+func (s *connector) getArtists(ctx context.Context, artistIds []string) ([]SpotifyArtist, error) {
+	url := fmt.Sprintf(
+		"https://api.spotify.com/v1/artists?ids=%s",
+		strings.Join(artistIds, ","))
+
+	glog.V(1).Infof("Get artists url: %q.", url)
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r = new(ArtistsResponse)
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r.Artists, nil
+}
+
+// Returns audio features of the tracks, at most 100 ids can be requested at once.
+func (s *connector) getAudioFeatures(ctx context.Context, trackIds []string) ([]*AudioFeatures, error) {
+	url := fmt.Sprintf(
+		"https://api.spotify.com/v1/audio-features?ids=%s",
+		strings.Join(trackIds, ","))
+
+	glog.V(1).Infof("Get audio features url: %q.", url)
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r = new(AudioFeaturesResponse)
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r.AudioFeatures, nil
+}
+
 func updateQueryString(query string) string {
 	for _, token := range []string{"&", "feat.", "feat", "vs.", "vs"} {
 		query = strings.Replace(query, token, "", -1)
@@ -0,0 +1,142 @@
+package spotify
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+const (
+	// Name of the gob file in the config directory with the audio features of the tracks.
+	featureCacheName = "spotify-audio-features"
+	// Name of the gob file in the config directory with the tracks without audio features.
+	missingFeatureCacheName = "spotify-audio-features-missing"
+	// Maximum number of tracks requested at once.
+	maxFeaturesPerRequest = 100
+	// Tracks without audio features are requested again after that time.
+	missingFeatureRetry = 30 * 24 * time.Hour
+)
+
+// Audio features by track id, they never change so the cache never expires. The cache is kept in memory
+// and saved by Spotify.SaveCaches.
+type featureCache struct {
+	features map[string]AudioFeatures
+	// Time of the request that returned no features by track id.
+	missing map[string]time.Time
+	// True when the cache changed since it was saved.
+	dirty bool
+	// Error of loading the existing files, they are not overwritten then.
+	loadErr error
+	lock    sync.Mutex
+	once    sync.Once
+}
+
+func (f *featureCache) load() {
+	f.once.Do(func() {
+		f.features = make(map[string]AudioFeatures)
+		f.missing = make(map[string]time.Time)
+		f.loadErr = loadCache(featureCacheName, &f.features)
+		if f.loadErr == nil {
+			f.loadErr = loadCache(missingFeatureCacheName, &f.missing)
+		}
+		if f.loadErr != nil {
+			glog.Errorf("%v, starting with empty audio feature cache which will not be saved.", f.loadErr)
+			f.features = make(map[string]AudioFeatures)
+			f.missing = make(map[string]time.Time)
+		}
+	})
+}
+
+// Saves the cache if it changed.
+func (f *featureCache) save() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.dirty {
+		return nil
+	}
+	if f.loadErr != nil {
+		return f.loadErr
+	}
+	err := conf.SaveConfigToFileAtomic(featureCacheName, f.features)
+	if err != nil {
+		return err
+	}
+	err = conf.SaveConfigToFileAtomic(missingFeatureCacheName, f.missing)
+	if err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
+}
+
+// Loads the cache from the config directory, missing file is not an error.
+func loadCache(name string, cache interface{}) error {
+	err := conf.LoadConfigFromFile(name, cache)
+	if errors.Is(err, fs.ErrNotExist) {
+		glog.V(1).Infof("Cache %v not found, starting with empty one.", name)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Could not load cache %v: %v", name, err)
+	}
+	return nil
+}
+
+// Returns the audio features by track id, tracks not in the local cache are requested from spotify in batches.
+// Tracks without audio features are not in the result, they are not requested again for missingFeatureRetry.
+func (s *Spotify) AudioFeatures(ctx context.Context, trackIds []string) (map[string]AudioFeatures, error) {
+	s.features.load()
+	s.features.lock.Lock()
+	now := time.Now()
+	result := make(map[string]AudioFeatures)
+	missing := make([]string, 0)
+	for _, id := range trackIds {
+		features, ok := s.features.features[id]
+		if ok {
+			result[id] = features
+		} else if len(id) > 0 && now.Sub(s.features.missing[id]) >= missingFeatureRetry {
+			missing = append(missing, id)
+		}
+	}
+	s.features.lock.Unlock()
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	// Features are requested without the lock so the other savers are not blocked.
+	fetched := make([]*AudioFeatures, 0, len(missing))
+	for start := 0; start < len(missing); start += maxFeaturesPerRequest {
+		end := start + maxFeaturesPerRequest
+		if end > len(missing) {
+			end = len(missing)
+		}
+		features, err := s.connector.getAudioFeatures(ctx, missing[start:end])
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, features...)
+	}
+
+	s.features.lock.Lock()
+	defer s.features.lock.Unlock()
+	for _, f := range fetched {
+		if f != nil {
+			s.features.features[f.Id] = *f
+			result[f.Id] = *f
+		}
+	}
+	for _, id := range missing {
+		if _, ok := result[id]; ok {
+			delete(s.features.missing, id)
+		} else {
+			s.features.missing[id] = now
+		}
+	}
+	s.features.dirty = true
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package spotify
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// Sends all the requests to the test server.
+type redirectTransport struct {
+	server *url.URL
+}
+
+func (r *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = r.server.Scheme
+	req.URL.Host = r.server.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newTestSpotify(t *testing.T, handler http.HandlerFunc) *Spotify {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return &Spotify{
+		connector: &connector{httpClient: &http.Client{Transport: &redirectTransport{u}}},
+		genres:    &genreCache{},
+		features:  &featureCache{},
+	}
+}
+
+func TestAudioFeatures_missingCached(t *testing.T) {
+	requests := 0
+	s := newTestSpotify(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"audio_features":[{"id":"a","tempo":120,"duration_ms":1000},null]}`))
+	})
+
+	for i := 0; i < 2; i++ {
+		got, err := s.AudioFeatures(context.Background(), []string{"a", "b"})
+		if err != nil || len(got) != 1 || got["a"].Tempo != 120 {
+			t.Errorf("AudioFeatures got: %v %v, want only a", got, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requests got: %v, want: 1", requests)
+	}
+}
+
+func TestAudioFeatures_error(t *testing.T) {
+	s := newTestSpotify(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(403)
+	})
+
+	_, err := s.AudioFeatures(context.Background(), []string{"a"})
+	if err == nil {
+		t.Errorf("AudioFeatures got no error, want: error")
+	}
+	if len(s.features.missing) != 0 {
+		t.Errorf("missing got: %v, want: failed requests not cached", s.features.missing)
+	}
+}
+
+func TestSaveCaches(t *testing.T) {
+	s := newTestSpotify(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"audio_features":[{"id":"a","tempo":120,"duration_ms":1000}]}`))
+	})
+
+	s.AudioFeatures(context.Background(), []string{"a"})
+	if _, err := os.Stat(conf.ConfigFilePath(featureCacheName)); err == nil {
+		t.Errorf("cache saved before SaveCaches()")
+	}
+	if err := s.SaveCaches(); err != nil {
+		t.Fatalf("SaveCaches() error: %v", err)
+	}
+
+	loaded := &featureCache{}
+	loaded.load()
+	if loaded.loadErr != nil || loaded.features["a"].Tempo != 120 {
+		t.Errorf("loaded cache got: %v %v, want: a", loaded.features, loaded.loadErr)
+	}
+}
+
+func TestSaveCaches_corrupted(t *testing.T) {
+	s := newTestSpotify(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"audio_features":[{"id":"a","tempo":120,"duration_ms":1000}]}`))
+	})
+	path := conf.ConfigFilePath(featureCacheName)
+	os.WriteFile(path, []byte("corrupted"), 0600)
+
+	got, err := s.AudioFeatures(context.Background(), []string{"a"})
+	if err != nil || got["a"].Tempo != 120 {
+		t.Errorf("AudioFeatures got: %v %v, want: a", got, err)
+	}
+	if err := s.SaveCaches(); err == nil {
+		t.Errorf("SaveCaches() got no error, want: corrupted cache error")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "corrupted" {
+		t.Errorf("corrupted cache was overwritten: %q", b)
+	}
+}
@@ -0,0 +1,105 @@
+package spotify
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"context"
+	"github.com/golang/glog"
+	"sync"
+)
+
+const (
+	// Name of the gob file in the config directory with the genres of the artists.
+	genreCacheName = "spotify-genres"
+	// Maximum number of artists requested at once.
+	maxArtistsPerRequest = 50
+)
+
+// Genres of the artists by artist id, artists change genres rarely so the cache never expires. The cache
+// is kept in memory and saved by Spotify.SaveCaches.
+type genreCache struct {
+	genres map[string][]string
+	// True when the cache changed since it was saved.
+	dirty bool
+	// Error of loading the existing file, it is not overwritten then.
+	loadErr error
+	lock    sync.Mutex
+	once    sync.Once
+}
+
+func (g *genreCache) load() {
+	g.once.Do(func() {
+		g.genres = make(map[string][]string)
+		g.loadErr = loadCache(genreCacheName, &g.genres)
+		if g.loadErr != nil {
+			glog.Errorf("%v, starting with empty genre cache which will not be saved.", g.loadErr)
+			g.genres = make(map[string][]string)
+		}
+	})
+}
+
+// Saves the cache if it changed.
+func (g *genreCache) save() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.dirty {
+		return nil
+	}
+	if g.loadErr != nil {
+		return g.loadErr
+	}
+	err := conf.SaveConfigToFileAtomic(genreCacheName, g.genres)
+	if err != nil {
+		return err
+	}
+	g.dirty = false
+	return nil
+}
+
+// Returns the genres of the artists by artist id, artists not in the local cache are requested from spotify.
+func (s *Spotify) ArtistGenres(ctx context.Context, artistIds []string) (map[string][]string, error) {
+	s.genres.load()
+	s.genres.lock.Lock()
+	result := make(map[string][]string)
+	missing := make([]string, 0)
+	for _, id := range artistIds {
+		genres, ok := s.genres.genres[id]
+		if ok {
+			result[id] = genres
+		} else if len(id) > 0 {
+			missing = append(missing, id)
+		}
+	}
+	s.genres.lock.Unlock()
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	// Artists are requested without the lock so the other savers are not blocked.
+	fetched := make([]SpotifyArtist, 0, len(missing))
+	for start := 0; start < len(missing); start += maxArtistsPerRequest {
+		end := start + maxArtistsPerRequest
+		if end > len(missing) {
+			end = len(missing)
+		}
+		artists, err := s.connector.getArtists(ctx, missing[start:end])
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, artists...)
+	}
+
+	s.genres.lock.Lock()
+	defer s.genres.lock.Unlock()
+	for _, a := range fetched {
+		genres := a.Genres
+		if genres == nil {
+			// Cache artists without genres too.
+			genres = []string{}
+		}
+		s.genres.genres[a.Id] = genres
+		result[a.Id] = genres
+	}
+	s.genres.dirty = true
+	return result, nil
+}
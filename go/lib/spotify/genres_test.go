@@ -0,0 +1,59 @@
+package spotify
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"context"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestArtistGenres(t *testing.T) {
+	requests := 0
+	s := newTestSpotify(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"artists":[{"id":"a","genres":["rock"]},{"id":"b"}]}`))
+	})
+
+	for i := 0; i < 2; i++ {
+		got, err := s.ArtistGenres(context.Background(), []string{"a", "b"})
+		if want := map[string][]string{"a": {"rock"}, "b": {}}; err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ArtistGenres got: %v %v, want: %v", got, err, want)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requests got: %v, want: 1", requests)
+	}
+	if _, err := os.Stat(conf.ConfigFilePath(genreCacheName)); err == nil {
+		t.Errorf("cache saved before SaveCaches()")
+	}
+	if err := s.SaveCaches(); err != nil {
+		t.Fatalf("SaveCaches() error: %v", err)
+	}
+
+	loaded := &genreCache{}
+	loaded.load()
+	if loaded.loadErr != nil || !reflect.DeepEqual(loaded.genres["a"], []string{"rock"}) {
+		t.Errorf("loaded cache got: %v %v, want: a", loaded.genres, loaded.loadErr)
+	}
+}
+
+func TestArtistGenres_corrupted(t *testing.T) {
+	s := newTestSpotify(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"artists":[{"id":"a","genres":["rock"]}]}`))
+	})
+	path := conf.ConfigFilePath(genreCacheName)
+	os.WriteFile(path, []byte("corrupted"), 0600)
+
+	got, err := s.ArtistGenres(context.Background(), []string{"a"})
+	if err != nil || len(got["a"]) != 1 {
+		t.Errorf("ArtistGenres got: %v %v, want: a", got, err)
+	}
+	if err := s.SaveCaches(); err == nil {
+		t.Errorf("SaveCaches() got no error, want: corrupted cache error")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "corrupted" {
+		t.Errorf("corrupted cache was overwritten: %q", b)
+	}
+}
@@ -4,11 +4,14 @@ package spotify
 
 import (
 	"strings"
+	"time"
 )
 
 type SpotifyArtist struct {
 	Id   string
 	Name string
+	// Set only by the artist endpoint.
+	Genres []string
 }
 
 type SpotifyAlbum struct {
@@ -25,10 +28,13 @@ type SpotifyTrack struct {
 	Artists          []SpotifyArtist
 	Album            SpotifyAlbum
 	AvailableMarkets []string `json:"available_markets"`
+	// Set only for the playlist tracks.
+	AddedAt time.Time `json:"-"`
 }
 
 type PlaylistItem struct {
-	Track SpotifyTrack
+	Track   SpotifyTrack
+	AddedAt time.Time `json:"added_at"`
 }
 
 type PlaylistResponse struct {
@@ -45,10 +51,38 @@ type SearchResponse struct {
 	Tracks SearchResponseBody
 }
 
+type ArtistsResponse struct {
+	Artists []SpotifyArtist
+}
+
+type AudioFeatures struct {
+	Id string
+	// Beats per minute.
+	Tempo float64
+	// Values from 0 to 1.
+	Energy       float64
+	Danceability float64
+	Valence      float64
+	DurationMs   int64 `json:"duration_ms"`
+}
+
+type AudioFeaturesResponse struct {
+	// Contains null for the tracks without audio features.
+	AudioFeatures []*AudioFeatures `json:"audio_features"`
+}
+
 type ImmutableSpotifyTrack struct {
-	artist string
-	title  string
-	id     string
+	artist     string
+	title      string
+	id         string
+	artists    []string
+	artistIds  []string
+	album      string
+	durationMs int64
+	// Used to resolve the similar tracks.
+	popularity int
+	addedAt    time.Time
+	markets    []string
 }
 
 func (t SpotifyTrack) String() string {
@@ -68,11 +102,35 @@ func (t SpotifyTrack) ArtistAsString() string {
 	return strings.Join(artists, ", ")
 }
 
-func (t *SpotifyTrack) immutable() *ImmutableSpotifyTrack {
+// Converts the parsed track to the immutable one used by the playlist cache and matcher.
+func (t *SpotifyTrack) Immutable() *ImmutableSpotifyTrack {
+	artists := make([]string, len(t.Artists))
+	artistIds := make([]string, len(t.Artists))
+	for i, a := range t.Artists {
+		artists[i] = a.Name
+		artistIds[i] = a.Id
+	}
 	return &ImmutableSpotifyTrack{
-		artist: t.ArtistAsString(),
-		title:  t.Name,
-		id:     t.Id,
+		artist:     t.ArtistAsString(),
+		title:      t.Name,
+		id:         t.Id,
+		artists:    artists,
+		artistIds:  artistIds,
+		album:      t.Album.Name,
+		durationMs: t.DurationMs,
+		popularity: t.Popularity,
+		addedAt:    t.AddedAt,
+		markets:    t.AvailableMarkets,
+	}
+}
+
+// Creates the track from the previously stored id, artist and title.
+func NewImmutableSpotifyTrack(id string, artist string, title string) *ImmutableSpotifyTrack {
+	return &ImmutableSpotifyTrack{
+		artist:  artist,
+		title:   title,
+		id:      id,
+		artists: []string{artist},
 	}
 }
 
@@ -80,6 +138,11 @@ func (t *ImmutableSpotifyTrack) Id() string {
 	return t.id
 }
 
+// Link opening the track in the web player.
+func (t *ImmutableSpotifyTrack) Url() string {
+	return "https://open.spotify.com/track/" + t.id
+}
+
 func (t *ImmutableSpotifyTrack) Title() string {
 	return t.title
 }
@@ -88,6 +151,43 @@ func (t *ImmutableSpotifyTrack) Artist() string {
 	return t.artist
 }
 
+// Names of all the track artists.
+func (t *ImmutableSpotifyTrack) Artists() []string {
+	return t.artists
+}
+
+// Ids of all the track artists.
+func (t *ImmutableSpotifyTrack) ArtistIds() []string {
+	return t.artistIds
+}
+
+func (t *ImmutableSpotifyTrack) Album() string {
+	return t.album
+}
+
+func (t *ImmutableSpotifyTrack) DurationMs() int64 {
+	return t.durationMs
+}
+
+// Popularity 0-100.
+func (t *ImmutableSpotifyTrack) Popularity() int {
+	return t.popularity
+}
+
+// Time when the track was added to the playlist, zero if it is not a playlist track.
+func (t *ImmutableSpotifyTrack) AddedAt() time.Time {
+	return t.addedAt
+}
+
+func (t *ImmutableSpotifyTrack) AvailableIn(market string) bool {
+	for _, m := range t.markets {
+		if m == market {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *ImmutableSpotifyTrack) String() string {
 	if t == nil || len(t.Artist())+len(t.Title()) == 0 {
 		return ""
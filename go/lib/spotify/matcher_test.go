@@ -116,7 +116,7 @@ var tests = []matchTest{
 
 func TestMatchRatio(t *testing.T) {
 	for _, matchTest := range tests {
-		got := CalculateMatchRatio(matchTest.title, matchTest.track.immutable())
+		got := CalculateMatchRatio(matchTest.title, matchTest.track.Immutable())
 		if got != matchTest.want {
 			t.Errorf("radio: %q, spotify: %q, got: %v, want: %v", matchTest.title, matchTest.track, got, matchTest.want)
 		}
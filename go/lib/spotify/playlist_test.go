@@ -0,0 +1,58 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReplacePlaylist(t *testing.T) {
+	var requests []string
+	s := newTestSpotify(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name string
+			Uris []string
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path == "/v1/me/playlists" {
+			requests = append(requests, fmt.Sprintf("%v create %v", r.Method, body.Name))
+			w.WriteHeader(201)
+			w.Write([]byte(`{"id":"new"}`))
+			return
+		}
+		requests = append(requests, fmt.Sprintf("%v %v %d %v", r.Method, r.URL.Path, len(body.Uris), body.Uris[0]))
+		w.WriteHeader(201)
+	})
+	s.cache = newCache()
+	s.cache.ReplaceAll("new", []*ImmutableSpotifyTrack{NewImmutableSpotifyTrack("old", "a", "b")})
+
+	ctx := context.Background()
+	id, err := s.CreatePlaylist(ctx, "restored")
+	if err != nil || id != "new" {
+		t.Fatalf("CreatePlaylist() got: %q %v, want: new", id, err)
+	}
+	tracks := make([]*ImmutableSpotifyTrack, 150)
+	for i := range tracks {
+		tracks[i] = NewImmutableSpotifyTrack(fmt.Sprint(i), "Artist", "Title")
+	}
+	err = s.ReplacePlaylist(ctx, id, tracks)
+	if err != nil {
+		t.Fatalf("ReplacePlaylist() error: %v", err)
+	}
+
+	// Tracks are replaced by the first request and appended by the next ones, in order.
+	want := []string{
+		"POST create restored",
+		"PUT /v1/playlists/new/tracks 100 spotify:track:0",
+		"POST /v1/playlists/new/tracks 50 spotify:track:100",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests got: %q, want: %q", requests, want)
+	}
+	if s.cache.IsCached(id) {
+		t.Errorf("playlist cache should be cleared after replace")
+	}
+}
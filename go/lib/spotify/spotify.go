@@ -3,11 +3,17 @@ package spotify
 import (
 	"context"
 	"github.com/golang/glog"
+	"net/http"
 )
 
+// Maximum number of tracks sent in one playlist request.
+const maxTracksPerRequest = 100
+
 type Spotify struct {
 	connector *connector
 	cache     Cache
+	genres    *genreCache
+	features  *featureCache
 }
 
 func New(ctx context.Context, market string) (*Spotify, error) {
@@ -18,6 +24,8 @@ func New(ctx context.Context, market string) (*Spotify, error) {
 	return &Spotify{
 		connector: c,
 		cache:     newCache(),
+		genres:    &genreCache{},
+		features:  &featureCache{},
 	}, nil
 }
 
@@ -43,6 +51,44 @@ func (s *Spotify) AddToPlaylist(ctx context.Context, playlistId string, track *I
 	return nil
 }
 
+// Saves the genre and audio feature caches if they changed, they are kept in memory until then.
+func (s *Spotify) SaveCaches() error {
+	err := s.genres.save()
+	if err2 := s.features.save(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Creates the private playlist, returns its id.
+func (s *Spotify) CreatePlaylist(ctx context.Context, name string) (string, error) {
+	return s.connector.createPlaylist(ctx, name)
+}
+
+// Replaces all the tracks of the playlist with the tracks, in their order.
+func (s *Spotify) ReplacePlaylist(ctx context.Context, playlistId string, tracks []*ImmutableSpotifyTrack) error {
+	// Cache is refreshed on the next list, it would not match the playlist if any request failed.
+	s.cache.ReplaceAll(playlistId, nil)
+
+	ids := make([]string, len(tracks))
+	for i, t := range tracks {
+		ids[i] = t.Id()
+	}
+	method := http.MethodPut
+	for start := 0; start == 0 || start < len(ids); start += maxTracksPerRequest {
+		end := start + maxTracksPerRequest
+		if end > len(ids) {
+			end = len(ids)
+		}
+		err := s.connector.sendPlaylistTracks(ctx, method, playlistId, ids[start:end])
+		if err != nil {
+			return err
+		}
+		method = http.MethodPost
+	}
+	return nil
+}
+
 func (s *Spotify) RemoveFromPlaylist(ctx context.Context, playlistId string, track *ImmutableSpotifyTrack) error {
 	err := s.cache.Remove(playlistId, track)
 	if err != nil {
@@ -71,7 +117,7 @@ func (s *Spotify) ListLiked(ctx context.Context) ([]*ImmutableSpotifyTrack, erro
 	// Not using cache for liked songs for now.
 	result := make([]*ImmutableSpotifyTrack, len(tracks))
 	for i := range tracks {
-		result[i] = tracks[i].immutable()
+		result[i] = tracks[i].Immutable()
 	}
 
 	glog.V(2).Infof("ListLiked: %v", tracks)
@@ -100,7 +146,7 @@ func (s *Spotify) ListPlaylistWithFilter(ctx context.Context, playlistId string,
 	cached := make([]*ImmutableSpotifyTrack, len(tracks))
 	result := make([]*ImmutableSpotifyTrack, 0)
 	for i := range tracks {
-		imm := tracks[i].immutable()
+		imm := tracks[i].Immutable()
 		cached[i] = imm
 		if filter(tracks[i]) {
 			result = append(result, imm)
@@ -124,7 +170,20 @@ func (s *Spotify) FindTracks(ctx context.Context, query string) ([]*ImmutableSpo
 
 	result := make([]*ImmutableSpotifyTrack, len(tracks))
 	for i := range tracks {
-		result[i] = tracks[i].immutable()
+		result[i] = tracks[i].Immutable()
 	}
 	return result, nil
 }
+
+// Returns the tracks with the International Standard Recording Code.
+func (s *Spotify) FindTracksByIsrc(ctx context.Context, isrc string) ([]*ImmutableSpotifyTrack, error) {
+	return s.FindTracks(ctx, "isrc:"+isrc)
+}
+
+func (s *Spotify) GetTrack(ctx context.Context, trackId string) (*ImmutableSpotifyTrack, error) {
+	track, err := s.connector.getTrack(ctx, trackId)
+	if err != nil {
+		return nil, err
+	}
+	return track.Immutable(), nil
+}
@@ -0,0 +1,184 @@
+// Package subsonic is a client of the Subsonic API, implemented also by Navidrome, Airsonic etc.
+package subsonic
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/lib/ratelimit"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiVersion = "1.16.1"
+	clientName = "birnenlabs"
+)
+
+type Config struct {
+	// Server url, e.g. "https://music.example.com".
+	Url      string
+	User     string
+	Password string
+}
+
+type Subsonic struct {
+	config Config
+	client ratelimit.AnyClient
+}
+
+type Song struct {
+	Id     string
+	Title  string
+	Artist string
+	Album  string
+	Genre  string
+	// Duration in seconds.
+	Duration int
+}
+
+type subsonicError struct {
+	Code    int
+	Message string
+}
+
+type responseBody struct {
+	Status        string
+	Error         *subsonicError
+	SearchResult3 struct {
+		Song []Song
+	}
+	Playlist struct {
+		Id    string
+		Name  string
+		Entry []Song
+	}
+}
+
+type response struct {
+	Response responseBody `json:"subsonic-response"`
+}
+
+// Creates the client using the "subsonic" json config.
+func New() (*Subsonic, error) {
+	var config Config
+	err := conf.LoadConfigFromJson("subsonic", &config)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithConfig(ratelimit.New(&http.Client{}, 100*time.Millisecond), config), nil
+}
+
+func NewWithConfig(client ratelimit.AnyClient, config Config) *Subsonic {
+	return &Subsonic{
+		config: config,
+		client: client,
+	}
+}
+
+func (s Song) String() string {
+	return s.Artist + " - " + s.Title
+}
+
+func (s *Subsonic) Search(ctx context.Context, query string) ([]Song, error) {
+	r, err := s.get("search3", url.Values{
+		"query":       {query},
+		"songCount":   {"50"},
+		"artistCount": {"0"},
+		"albumCount":  {"0"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	glog.V(1).Infof("Found %v songs for query %q.", len(r.SearchResult3.Song), query)
+	return r.SearchResult3.Song, nil
+}
+
+// Returns the playlist songs in the playlist order.
+func (s *Subsonic) Playlist(ctx context.Context, playlistId string) ([]Song, error) {
+	r, err := s.get("getPlaylist", url.Values{"id": {playlistId}})
+	if err != nil {
+		return nil, err
+	}
+	return r.Playlist.Entry, nil
+}
+
+func (s *Subsonic) AddToPlaylist(ctx context.Context, playlistId string, songId string) error {
+	_, err := s.get("updatePlaylist", url.Values{
+		"playlistId":  {playlistId},
+		"songIdToAdd": {songId},
+	})
+	return err
+}
+
+// Removes the songs at the indexes of the playlist returned by Playlist.
+func (s *Subsonic) RemoveFromPlaylist(ctx context.Context, playlistId string, indexes []int) error {
+	params := url.Values{"playlistId": {playlistId}}
+	for _, i := range indexes {
+		params.Add("songIndexToRemove", strconv.Itoa(i))
+	}
+	_, err := s.get("updatePlaylist", params)
+	return err
+}
+
+func (s *Subsonic) get(method string, params url.Values) (*responseBody, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	token := md5.Sum([]byte(s.config.Password + salt))
+	params.Set("u", s.config.User)
+	params.Set("t", hex.EncodeToString(token[:]))
+	params.Set("s", salt)
+	params.Set("v", apiVersion)
+	params.Set("c", clientName)
+	params.Set("f", "json")
+
+	uri := strings.TrimSuffix(s.config.Url, "/") + "/rest/" + method + "?" + params.Encode()
+	glog.V(2).Infof("Subsonic %v: %v", method, params.Get("query"))
+	resp, err := s.client.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r = new(response)
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Response.Status != "ok" {
+		if r.Response.Error != nil {
+			return nil, fmt.Errorf("subsonic error %d: %v", r.Response.Error.Code, r.Response.Error.Message)
+		}
+		return nil, fmt.Errorf("subsonic status: %q", r.Response.Status)
+	}
+	return &r.Response, nil
+}
+
+func newSalt() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
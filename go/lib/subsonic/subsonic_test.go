@@ -0,0 +1,63 @@
+package subsonic_test
+
+import (
+	"birnenlabs.com/go/lib/subsonic"
+	"birnenlabs.com/go/lib/subsonic/subsonictest"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+var library = []subsonic.Song{
+	{Id: "1", Artist: "Artist", Title: "Song"},
+	{Id: "2", Artist: "Band", Title: "Other"},
+	{Id: "3", Artist: "Artist", Title: "Third"},
+}
+
+func TestSubsonic(t *testing.T) {
+	ctx := context.Background()
+	server := subsonictest.NewServer(library)
+	defer server.Close()
+	server.SetPlaylist("p", library[1:2])
+	s := subsonic.NewWithConfig(&http.Client{}, server.Config())
+
+	songs, err := s.Search(ctx, "Artist - Song")
+	if err != nil || !reflect.DeepEqual(songs, []subsonic.Song{library[0], library[2]}) {
+		t.Errorf("Search() got: %v %v, want songs 1 and 3", songs, err)
+	}
+
+	err = s.AddToPlaylist(ctx, "p", "1")
+	if err != nil {
+		t.Fatalf("AddToPlaylist() error: %v", err)
+	}
+	err = s.AddToPlaylist(ctx, "p", "3")
+	if err != nil {
+		t.Fatalf("AddToPlaylist() error: %v", err)
+	}
+	err = s.RemoveFromPlaylist(ctx, "p", []int{0, 2})
+	if err != nil {
+		t.Fatalf("RemoveFromPlaylist() error: %v", err)
+	}
+
+	songs, err = s.Playlist(ctx, "p")
+	if err != nil || !reflect.DeepEqual(songs, library[0:1]) {
+		t.Errorf("Playlist() got: %v %v, want song 1", songs, err)
+	}
+}
+
+func TestSubsonicErrors(t *testing.T) {
+	ctx := context.Background()
+	server := subsonictest.NewServer(library)
+	defer server.Close()
+
+	if _, err := subsonic.NewWithConfig(&http.Client{}, server.Config()).Playlist(ctx, "missing"); err == nil {
+		t.Errorf("Playlist() of missing playlist should fail")
+	}
+
+	config := server.Config()
+	config.Password = "wrong"
+	if _, err := subsonic.NewWithConfig(&http.Client{}, config).Search(ctx, "Artist"); err == nil {
+		t.Errorf("Search() with wrong password should fail")
+	}
+}
@@ -0,0 +1,145 @@
+// Package subsonictest provides a local stand-in of the Subsonic server for tests.
+package subsonictest
+
+import (
+	"birnenlabs.com/go/lib/subsonic"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	User     = "user"
+	Password = "password"
+)
+
+// In memory Subsonic server. Search returns the library songs with any of the query words
+// in the artist or title.
+type Server struct {
+	*httptest.Server
+	lock      sync.Mutex
+	library   []subsonic.Song
+	playlists map[string][]subsonic.Song
+	// Number of requests by method.
+	requests map[string]int
+}
+
+func NewServer(library []subsonic.Song) *Server {
+	s := &Server{
+		library:   library,
+		playlists: make(map[string][]subsonic.Song),
+		requests:  make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *Server) Config() subsonic.Config {
+	return subsonic.Config{
+		Url:      s.URL,
+		User:     User,
+		Password: Password,
+	}
+}
+
+func (s *Server) SetPlaylist(id string, songs []subsonic.Song) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.playlists[id] = append([]subsonic.Song{}, songs...)
+}
+
+func (s *Server) Playlist(id string) []subsonic.Song {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]subsonic.Song{}, s.playlists[id]...)
+}
+
+func (s *Server) Requests(method string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests[method]
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	method := strings.TrimPrefix(r.URL.Path, "/rest/")
+	s.requests[method]++
+	q := r.URL.Query()
+	token := md5.Sum([]byte(Password + q.Get("s")))
+	if q.Get("u") != User || q.Get("t") != hex.EncodeToString(token[:]) {
+		writeResponse(w, map[string]interface{}{"status": "failed", "error": map[string]interface{}{"code": 40, "message": "Wrong username or password"}})
+		return
+	}
+
+	switch method {
+	case "search3":
+		songs := make([]subsonic.Song, 0)
+		for _, song := range s.library {
+			if matchesQuery(song, q.Get("query")) {
+				songs = append(songs, song)
+			}
+		}
+		writeResponse(w, map[string]interface{}{"status": "ok", "searchResult3": map[string]interface{}{"song": songs}})
+	case "getPlaylist":
+		songs, ok := s.playlists[q.Get("id")]
+		if !ok {
+			writeResponse(w, map[string]interface{}{"status": "failed", "error": map[string]interface{}{"code": 70, "message": "Playlist not found"}})
+			return
+		}
+		writeResponse(w, map[string]interface{}{"status": "ok", "playlist": map[string]interface{}{"id": q.Get("id"), "entry": songs}})
+	case "updatePlaylist":
+		id := q.Get("playlistId")
+		s.playlists[id] = s.update(s.playlists[id], q["songIndexToRemove"], q["songIdToAdd"])
+		writeResponse(w, map[string]interface{}{"status": "ok"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) update(songs []subsonic.Song, remove []string, add []string) []subsonic.Song {
+	indexes := make([]int, 0)
+	for _, r := range remove {
+		i, err := strconv.Atoi(r)
+		if err == nil && i >= 0 && i < len(songs) {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	for k, i := range indexes {
+		if k == 0 || indexes[k-1] != i {
+			songs = append(songs[:i], songs[i+1:]...)
+		}
+	}
+
+	for _, id := range add {
+		for _, song := range s.library {
+			if song.Id == id {
+				songs = append(songs, song)
+			}
+		}
+	}
+	return songs
+}
+
+func matchesQuery(song subsonic.Song, query string) bool {
+	text := strings.ToLower(song.Artist + " " + song.Title)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if word != "-" && strings.Contains(text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeResponse(w http.ResponseWriter, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"subsonic-response": body})
+}
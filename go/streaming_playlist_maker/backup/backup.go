@@ -0,0 +1,149 @@
+// Package backup contains playlist snapshots which can be written as JSON, CSV or M3U and restored from JSON.
+package backup
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatJson = "json"
+	FormatCsv  = "csv"
+	FormatM3u  = "m3u"
+)
+
+// Playlist id used for the liked songs.
+const Liked = "liked"
+
+type Playlist struct {
+	Id       string
+	Exported time.Time
+	Tracks   []Track
+}
+
+type Track struct {
+	Id         string
+	Uri        string
+	Artist     string
+	Title      string
+	Album      string
+	Artists    []string
+	ArtistIds  []string
+	DurationMs int64
+	Popularity int
+	// Zero for the tracks from search.
+	AddedAt time.Time
+}
+
+func New(id string, tracks []*spotify.ImmutableSpotifyTrack, exported time.Time) *Playlist {
+	result := &Playlist{
+		Id:       id,
+		Exported: exported,
+		Tracks:   make([]Track, len(tracks)),
+	}
+	for i, t := range tracks {
+		result.Tracks[i] = Track{
+			Id:         t.Id(),
+			Uri:        "spotify:track:" + t.Id(),
+			Artist:     t.Artist(),
+			Title:      t.Title(),
+			Album:      t.Album(),
+			Artists:    t.Artists(),
+			ArtistIds:  t.ArtistIds(),
+			DurationMs: t.DurationMs(),
+			Popularity: t.Popularity(),
+			AddedAt:    t.AddedAt(),
+		}
+	}
+	return result
+}
+
+// Returns the file name of the backup, e.g. "liked-20240131-120000.json".
+func (p *Playlist) FileName(format string) string {
+	return p.Id + "-" + p.Exported.Format("20060102-150405") + "." + format
+}
+
+func (p *Playlist) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJson:
+		return p.writeJson(w)
+	case FormatCsv:
+		return p.writeCsv(w)
+	case FormatM3u:
+		return p.writeM3u(w)
+	}
+	return fmt.Errorf("unknown format: %q", format)
+}
+
+func (p *Playlist) writeJson(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(p)
+}
+
+func (p *Playlist) writeCsv(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"id", "uri", "artist", "title", "album", "artists", "artist_ids", "duration_ms", "popularity", "added_at"})
+	for _, t := range p.Tracks {
+		addedAt := ""
+		if !t.AddedAt.IsZero() {
+			addedAt = t.AddedAt.Format(time.RFC3339)
+		}
+		c.Write([]string{
+			t.Id,
+			t.Uri,
+			t.Artist,
+			t.Title,
+			t.Album,
+			strings.Join(t.Artists, ";"),
+			strings.Join(t.ArtistIds, ";"),
+			strconv.FormatInt(t.DurationMs, 10),
+			strconv.Itoa(t.Popularity),
+			addedAt,
+		})
+	}
+	c.Flush()
+	return c.Error()
+}
+
+func (p *Playlist) writeM3u(w io.Writer) error {
+	_, err := fmt.Fprintln(w, "#EXTM3U")
+	if err != nil {
+		return err
+	}
+	for _, t := range p.Tracks {
+		_, err = fmt.Fprintf(w, "#EXTINF:%d,%v - %v\n%v\n", t.DurationMs/1000, t.Artist, t.Title, t.Uri)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ReadJson(r io.Reader) (*Playlist, error) {
+	var p = new(Playlist)
+	err := json.NewDecoder(r).Decode(&p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Returns the tracks of the backup in the backup order, without duplicates and tracks without ids.
+func (p *Playlist) SpotifyTracks() []*spotify.ImmutableSpotifyTrack {
+	ids := make(map[string]bool)
+	result := make([]*spotify.ImmutableSpotifyTrack, 0)
+	for _, t := range p.Tracks {
+		if len(t.Id) > 0 && !ids[t.Id] {
+			ids[t.Id] = true
+			result = append(result, spotify.NewImmutableSpotifyTrack(t.Id, t.Artist, t.Title))
+		}
+	}
+	return result
+}
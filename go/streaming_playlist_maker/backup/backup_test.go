@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPlaylist() *Playlist {
+	t1 := &spotify.SpotifyTrack{
+		Id:         "1",
+		Name:       "Song, Part 1",
+		DurationMs: 185500,
+		Popularity: 40,
+		Artists:    []spotify.SpotifyArtist{{Id: "a1", Name: "Artist"}, {Id: "a2", Name: "Guest"}},
+		Album:      spotify.SpotifyAlbum{Name: "Album"},
+		AddedAt:    time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+	}
+	t2 := &spotify.SpotifyTrack{
+		Id:      "2",
+		Name:    "Other",
+		Artists: []spotify.SpotifyArtist{{Id: "a3", Name: "Band"}},
+	}
+	return New("playlist", []*spotify.ImmutableSpotifyTrack{t1.Immutable(), t2.Immutable()}, time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC))
+}
+
+func TestWrite(t *testing.T) {
+	p := testPlaylist()
+	for _, test := range []struct {
+		format string
+		want   string
+	}{
+		{FormatCsv, "id,uri,artist,title,album,artists,artist_ids,duration_ms,popularity,added_at\n" +
+			"1,spotify:track:1,\"Artist, Guest\",\"Song, Part 1\",Album,Artist;Guest,a1;a2,185500,40,2024-01-31T12:00:00Z\n" +
+			"2,spotify:track:2,Band,Other,,Band,a3,0,0,\n"},
+		{FormatM3u, "#EXTM3U\n#EXTINF:185,Artist, Guest - Song, Part 1\nspotify:track:1\n#EXTINF:0,Band - Other\nspotify:track:2\n"},
+	} {
+		var buf bytes.Buffer
+		err := p.Write(&buf, test.format)
+		if err != nil || buf.String() != test.want {
+			t.Errorf("Write(%v) got: %q %v, want: %q", test.format, buf.String(), err, test.want)
+		}
+	}
+
+	if err := p.Write(&bytes.Buffer{}, "xml"); err == nil {
+		t.Errorf("Write() with unknown format should fail")
+	}
+	if got, want := p.FileName(FormatM3u), "playlist-20240201-083000.m3u"; got != want {
+		t.Errorf("FileName() got: %q, want: %q", got, want)
+	}
+}
+
+func TestJsonRoundTrip(t *testing.T) {
+	p := testPlaylist()
+	var buf bytes.Buffer
+	err := p.Write(&buf, FormatJson)
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	got, err := ReadJson(&buf)
+	if err != nil || !reflect.DeepEqual(got, p) {
+		t.Errorf("ReadJson() got: %+v %v, want: %+v", got, err, p)
+	}
+
+	if _, err = ReadJson(strings.NewReader("{")); err == nil {
+		t.Errorf("ReadJson() of invalid json should fail")
+	}
+}
+
+func TestSpotifyTracks(t *testing.T) {
+	p := testPlaylist()
+	p.Tracks = append(p.Tracks, p.Tracks[1], Track{Artist: "Local", Title: "File"})
+
+	got := p.SpotifyTracks()
+	if len(got) != 2 || got[0].Id() != "1" || got[1].Id() != "2" || got[1].String() != "Band - Other" {
+		t.Errorf("SpotifyTracks() got: %v, want tracks 1 and 2", got)
+	}
+}
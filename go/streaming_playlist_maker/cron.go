@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron schedule: minute, hour, day of month, month and day of week. Every field is a bit set of the allowed values.
+type cronSchedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// True when the day of month or day of week field is "*", used to decide how the days are matched.
+	domAny bool
+	dowAny bool
+}
+
+// Range of the values allowed in each field.
+var cronFields = []struct {
+	name string
+	min  int
+	max  int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// 7 is also Sunday.
+	{"day of week", 0, 7},
+}
+
+// Parses standard 5 field cron expression, e.g. "*/15 6-22 * * 1-5". Every field can be "*",
+// a number, a range "a-b" or a list of them separated by commas, optionally followed by the step "/n".
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: %d fields, want %d", expr, len(fields), len(cronFields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		var err error
+		bits[i], err = parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v in cron expression %q: %v", cronFields[i].name, expr, err)
+		}
+	}
+
+	dow := bits[4]
+	if dow&(1<<7) != 0 {
+		dow |= 1
+	}
+	return &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    dow,
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart := part
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			rangePart = part[:i]
+		}
+
+		start, end := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				// "a/n" means from a to max every n.
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Returns the first time after t matching the schedule or zero time if there is none in the next 5 years.
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Like in cron: when both day of month and day of week are restricted, the day matching any of them is accepted.
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
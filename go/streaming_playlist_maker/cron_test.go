@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// Friday
+	now := time.Date(2021, 1, 1, 10, 30, 15, 0, time.UTC)
+	for _, test := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2021, 1, 1, 10, 31, 0, 0, time.UTC)},
+		{"30 * * * *", time.Date(2021, 1, 1, 11, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 1, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 6,18 * * *", time.Date(2021, 1, 1, 18, 0, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 8-9 * * 1-5", time.Date(2021, 1, 4, 8, 0, 0, 0, time.UTC)},
+		{"0 12 * * 0", time.Date(2021, 1, 3, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2021, 1, 3, 12, 0, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.Date(2021, 1, 1, 10, 45, 0, 0, time.UTC)},
+		// Day of month or day of week (Monday).
+		{"0 0 15 * 1", time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	} {
+		c, err := parseCron(test.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", test.expr, err)
+			continue
+		}
+		got := c.Next(now)
+		if !got.Equal(test.want) {
+			t.Errorf("%q got: %v, want: %v", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+	} {
+		_, err := parseCron(expr)
+		if err == nil {
+			t.Errorf("parseCron(%q) got: nil, want: error", expr)
+		}
+	}
+}
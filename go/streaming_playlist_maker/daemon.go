@@ -0,0 +1,249 @@
+package main
+
+import (
+	glog "birnenlabs.com/go/lib/alog"
+	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/streaming_playlist_maker/pipeline"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// How often the daemon checks if any job should be started.
+const daemonTick = time.Minute
+
+// Keeps the process running and starts the jobs according to their schedules.
+type daemon struct {
+	env  *environment
+	jobs map[string]*scheduledJob
+	// Statistics of the currently running jobs.
+	running map[string]*statistics
+	lock    sync.Mutex
+}
+
+type scheduledJob struct {
+	conf Job
+	// Set if the job is scheduled by the cron expression.
+	cron *cronSchedule
+	// Next start time, zero if the job should not be started anymore.
+	next time.Time
+}
+
+// Runs the jobs forever. The config is reloaded on SIGHUP, running jobs are not stopped.
+func runDaemon(ctx context.Context, env *environment, jobs []Job) {
+	d := &daemon{
+		env:     env,
+		running: make(map[string]*statistics),
+	}
+	err := d.setJobs(jobs, time.Now())
+	if err != nil {
+		glog.Exit("Could not schedule jobs: ", err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	handleCtrlC(d)
+	go printStatsSometimes(d)
+
+	ticker := time.NewTicker(daemonTick)
+	defer ticker.Stop()
+
+	glog.Infof("Daemon started")
+	d.startDueJobs(ctx, time.Now())
+	for {
+		select {
+		case <-hup:
+			d.reload(ctx)
+			d.startDueJobs(ctx, time.Now())
+		case now := <-ticker.C:
+			d.startDueJobs(ctx, now)
+		}
+	}
+}
+
+func newScheduledJob(conf Job, now time.Time) (*scheduledJob, error) {
+	j := &scheduledJob{
+		conf: conf,
+		next: now,
+	}
+	if len(conf.Schedule) > 0 {
+		if conf.IntervalMin > 0 {
+			return nil, fmt.Errorf("%v: both Schedule and IntervalMin are set", conf.Name)
+		}
+		cron, err := parseCron(conf.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", conf.Name, err)
+		}
+		j.cron = cron
+		j.next = cron.Next(now)
+	}
+	return j, nil
+}
+
+// Sets the next start time after the job was started.
+func (j *scheduledJob) started(start time.Time) {
+	switch {
+	case j.cron != nil:
+		j.next = j.cron.Next(start)
+	case j.conf.IntervalMin > 0:
+		j.next = start.Add(time.Duration(j.conf.IntervalMin) * time.Minute)
+	default:
+		// Job without schedule is started only once.
+		j.next = time.Time{}
+	}
+}
+
+// Replaces the scheduled jobs, jobs with unchanged schedule keep their next start time.
+func (d *daemon) setJobs(jobs []Job, now time.Time) error {
+	scheduled := make(map[string]*scheduledJob)
+	for _, conf := range jobs {
+		if !conf.Active {
+			continue
+		}
+		if _, ok := scheduled[conf.Name]; ok {
+			return fmt.Errorf("%v: duplicated job name", conf.Name)
+		}
+		j, err := newScheduledJob(conf, now)
+		if err != nil {
+			return err
+		}
+
+		d.lock.Lock()
+		old, ok := d.jobs[conf.Name]
+		d.lock.Unlock()
+		if ok && old.conf.Schedule == conf.Schedule && old.conf.IntervalMin == conf.IntervalMin {
+			j.next = old.next
+		}
+		scheduled[conf.Name] = j
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.jobs = scheduled
+	return nil
+}
+
+// Reloads the config, the old one is kept if the new one is not valid.
+func (d *daemon) reload(ctx context.Context) {
+	glog.Infof("Reloading config")
+	var jobs []Job
+	err := conf.LoadConfigFromJson(*config, &jobs)
+	if err != nil {
+		glog.Errorf("Could not reload config: %v", err)
+		return
+	}
+	glog.V(3).Infof("Loaded configuration: %+v", jobs)
+
+	err = d.env.update(ctx, jobs)
+	if err != nil {
+		glog.Errorf("Could not create sources and savers: %v", err)
+		return
+	}
+	err = d.setJobs(jobs, time.Now())
+	if err != nil {
+		glog.Errorf("Could not schedule jobs: %v", err)
+		return
+	}
+	glog.Infof("Config reloaded")
+}
+
+// Starts the jobs which should be started, job which is still running is not started again.
+func (d *daemon) startDueJobs(ctx context.Context, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for name, j := range d.jobs {
+		if j.next.IsZero() || now.Before(j.next) {
+			continue
+		}
+		j.started(now)
+		if _, ok := d.running[name]; ok {
+			glog.Warningf("[%15.15s] Still running, skipping, next start: %v", name, j.next)
+			continue
+		}
+
+		stats := &statistics{}
+		err := initStats(stats, j.conf)
+		if err != nil {
+			glog.Errorf("[%15.15s] Could not initialize stats: %v", name, err)
+			continue
+		}
+		d.running[name] = stats
+		go d.run(ctx, j.conf, stats)
+	}
+}
+
+// Runs the job once: cleans the saver, streams the source and reports the statistics.
+func (d *daemon) run(ctx context.Context, conf Job, stats *statistics) {
+	defer func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		delete(d.running, conf.Name)
+	}()
+
+	start := time.Now()
+	if !*skipCleaning {
+		err := cleanSavers(ctx, d.env, []Job{conf}, stats)
+		if err != nil {
+			glog.Errorf("[%15.15s] Could not clean saver: %v", conf.Name, err)
+			return
+		}
+	}
+
+	filter, err := d.env.newFilter(conf)
+	if err != nil {
+		glog.Errorf("[%15.15s] Could not create filter: %v", conf.Name, err)
+		return
+	}
+
+	p, err := pipeline.New(conf.pipelineJob())
+	if err != nil {
+		glog.Errorf("[%15.15s] Could not create pipeline: %v", conf.Name, err)
+		return
+	}
+
+	startJob(ctx, conf, d.env.source(conf), d.env.targets(conf), p, filter, d.env.getEnricher(), stats)
+	d.env.save()
+	saveJobStates(stats, []Job{conf}, time.Now())
+
+	issues := stats.FindIssues()
+	glog.Infof("[%15.15s] Completed after %v, statistics:\n%v%v", conf.Name, time.Since(start).Round(time.Second), issues, stats)
+	glog.InfoSend("\n" + stats.String())
+	if len(issues) == 0 {
+		return
+	}
+	glog.Error(issues)
+	// Jobs can run every few minutes, so only the runs with issues are emailed.
+	err = emailReport(stats, issues)
+	if err != nil {
+		glog.Errorf("[%15.15s] Could not email the report: %v", conf.Name, err)
+	}
+}
+
+// Statistics of the running jobs.
+func (d *daemon) String() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	names := make([]string, 0, len(d.running))
+	for name := range d.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.WriteString(d.running[name].String())
+	}
+	if len(names) == 0 {
+		buf.WriteString("No jobs running.\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledJobStarted(t *testing.T) {
+	now := time.Date(2021, 1, 1, 10, 30, 0, 0, time.UTC)
+	for _, test := range []struct {
+		conf      Job
+		wantFirst time.Time
+		wantNext  time.Time
+	}{
+		{
+			conf:      Job{Name: "once"},
+			wantFirst: now,
+			wantNext:  time.Time{},
+		},
+		{
+			conf:      Job{Name: "interval", IntervalMin: 90},
+			wantFirst: now,
+			wantNext:  now.Add(90 * time.Minute),
+		},
+		{
+			conf:      Job{Name: "cron", Schedule: "0 * * * *"},
+			wantFirst: time.Date(2021, 1, 1, 11, 0, 0, 0, time.UTC),
+			wantNext:  time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC),
+		},
+	} {
+		j, err := newScheduledJob(test.conf, now)
+		if err != nil {
+			t.Fatalf("%v: %v", test.conf.Name, err)
+		}
+		if !j.next.Equal(test.wantFirst) {
+			t.Errorf("%v first got: %v, want: %v", test.conf.Name, j.next, test.wantFirst)
+		}
+		j.started(j.next)
+		if !j.next.Equal(test.wantNext) {
+			t.Errorf("%v next got: %v, want: %v", test.conf.Name, j.next, test.wantNext)
+		}
+	}
+}
+
+func TestSetJobsKeepsSchedule(t *testing.T) {
+	now := time.Date(2021, 1, 1, 10, 30, 0, 0, time.UTC)
+	d := &daemon{}
+	err := d.setJobs([]Job{
+		{Name: "a", Active: true, IntervalMin: 60},
+		{Name: "b", Active: true, IntervalMin: 60},
+		{Name: "inactive", IntervalMin: 60},
+	}, now)
+	if err != nil {
+		t.Fatalf("setJobs: %v", err)
+	}
+	if len(d.jobs) != 2 {
+		t.Errorf("jobs got: %v, want: 2", len(d.jobs))
+	}
+	d.jobs["a"].started(now)
+	d.jobs["b"].started(now)
+
+	// Reload: a unchanged, b changed, c added.
+	later := now.Add(10 * time.Minute)
+	err = d.setJobs([]Job{
+		{Name: "a", Active: true, IntervalMin: 60},
+		{Name: "b", Active: true, IntervalMin: 30},
+		{Name: "c", Active: true},
+	}, later)
+	if err != nil {
+		t.Fatalf("setJobs: %v", err)
+	}
+	for name, want := range map[string]time.Time{
+		"a": now.Add(time.Hour),
+		"b": later,
+		"c": later,
+	} {
+		if got := d.jobs[name].next; !got.Equal(want) {
+			t.Errorf("%v got: %v, want: %v", name, got, want)
+		}
+	}
+
+	err = d.setJobs([]Job{{Name: "a", Active: true}, {Name: "a", Active: true}}, later)
+	if err == nil {
+		t.Errorf("setJobs with duplicated names got: nil, want: error")
+	}
+	err = d.setJobs([]Job{{Name: "a", Active: true, Schedule: "* * *"}}, later)
+	if err == nil {
+		t.Errorf("setJobs with invalid schedule got: nil, want: error")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	glog "birnenlabs.com/go/lib/alog"
+	"birnenlabs.com/go/lib/musicbrainz"
+	"birnenlabs.com/go/streaming_playlist_maker/sources"
+)
+
+// Minimal MusicBrainz search score required to replace the song with the canonical artist and title.
+const minEnrichScore = 90
+
+// Resolves songs to the MusicBrainz recordings, so savers are matching canonical metadata instead of the radio titles.
+type enricher struct {
+	musicBrainz *musicbrainz.MusicBrainz
+}
+
+func newEnricher() *enricher {
+	return &enricher{
+		musicBrainz: musicbrainz.New(),
+	}
+}
+
+// Returns the song with canonical artist, title, recording id and ISRC if the recording was found.
+func (e *enricher) Enrich(jobName string, song sources.Song) sources.Song {
+	if len(song.RecordingId) > 0 {
+		// Already resolved by the source.
+		return song
+	}
+
+	recording, err := e.musicBrainz.FindRecording(song.ArtistTitle)
+	if err != nil {
+		glog.Warningf("[%15.15s] Could not enrich %q: %v", jobName, song.ArtistTitle, err)
+		return song
+	}
+	if recording == nil || recording.Score < minEnrichScore {
+		glog.V(1).Infof("[%15.15s] Recording not found for %q: %+v", jobName, song.ArtistTitle, recording)
+		return song
+	}
+
+	glog.V(1).Infof("[%15.15s] Enriched %q -> %q (%v, %v)", jobName, song.ArtistTitle, recording, recording.Id, recording.Isrc())
+	return sources.Song{
+		ArtistTitle: recording.String(),
+		Artist:      recording.Artist,
+		Title:       recording.Title,
+		RecordingId: recording.Id,
+		Isrc:        recording.Isrc(),
+	}
+}
+
+func (e *enricher) SaveCache() {
+	err := e.musicBrainz.SaveCache()
+	if err != nil {
+		glog.Errorf("Could not save musicbrainz cache: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	glog "birnenlabs.com/go/lib/alog"
+	"birnenlabs.com/go/lib/spotify"
+	"birnenlabs.com/go/streaming_playlist_maker/backup"
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const exportUsage = `usage:
+  export [-dir directory] [-format json,csv,m3u] <playlist id or "liked">...
+  restore [-new] <backup json file> [playlist id]`
+
+// Exports the playlists to the files in all the formats.
+func runExport(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(out)
+	dir := fs.String("dir", ".", "Directory of the backup files")
+	formats := fs.String("format", backup.FormatJson, "Comma separated formats: json, csv, m3u")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New(exportUsage)
+	}
+
+	s, err := savers.NewSpotify(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, id := range fs.Args() {
+		var tracks []*spotify.ImmutableSpotifyTrack
+		if id == backup.Liked {
+			tracks, err = s.ListLiked(ctx)
+		} else {
+			tracks, err = s.ListPlaylist(ctx, id)
+		}
+		if err != nil {
+			return fmt.Errorf("could not list %v: %v", id, err)
+		}
+
+		err = writeBackup(backup.New(id, tracks, now), *dir, strings.Split(*formats, ","), out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeBackup(p *backup.Playlist, dir string, formats []string, out io.Writer) error {
+	for _, format := range formats {
+		name := filepath.Join(dir, p.FileName(format))
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		err = p.Write(f, format)
+		f.Close()
+		if err != nil {
+			os.Remove(name)
+			return err
+		}
+		fmt.Fprintf(out, "Exported %d tracks to %v\n", len(p.Tracks), name)
+	}
+	return nil
+}
+
+// Recreates the playlist from the JSON backup: all the tracks of the playlist are replaced with the backup
+// tracks in the backup order. By default the playlist the backup was exported from is replaced, with -new
+// (and always for the liked songs) a new private playlist is created.
+func runRestore(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.SetOutput(out)
+	newPlaylist := fs.Bool("new", false, "Create a new playlist instead of replacing the exported one")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 || (*newPlaylist && fs.NArg() == 2) {
+		return errors.New(exportUsage)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	p, err := backup.ReadJson(f)
+	if err != nil {
+		return fmt.Errorf("could not read backup %v: %v", fs.Arg(0), err)
+	}
+
+	playlistId := p.Id
+	if fs.NArg() == 2 {
+		playlistId = fs.Arg(1)
+	}
+	if playlistId == backup.Liked {
+		if fs.NArg() == 2 {
+			return fmt.Errorf("liked songs cannot be replaced, use a playlist id")
+		}
+		*newPlaylist = true
+	}
+
+	s, err := savers.NewSpotify(ctx)
+	if err != nil {
+		return err
+	}
+	if *newPlaylist {
+		name := fmt.Sprintf("%v (restored from %v)", p.Id, p.Exported.Format("2006-01-02 15:04"))
+		playlistId, err = s.CreatePlaylist(ctx, name)
+		if err != nil {
+			return fmt.Errorf("could not create playlist: %v", err)
+		}
+		fmt.Fprintf(out, "Created playlist %q: %v\n", name, playlistId)
+	}
+
+	tracks := p.SpotifyTracks()
+	glog.V(1).Infof("Restoring %d tracks to %v", len(tracks), playlistId)
+	err = s.ReplacePlaylist(ctx, playlistId, tracks)
+	if err != nil {
+		return fmt.Errorf("could not restore %v: %v", playlistId, err)
+	}
+	fmt.Fprintf(out, "Restored %d tracks to %v\n", len(tracks), playlistId)
+	return nil
+}
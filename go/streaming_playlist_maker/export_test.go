@@ -0,0 +1,55 @@
+package main
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"birnenlabs.com/go/streaming_playlist_maker/backup"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteBackup(t *testing.T) {
+	dir := t.TempDir()
+	tracks := []*spotify.ImmutableSpotifyTrack{spotify.NewImmutableSpotifyTrack("1", "Artist", "Song")}
+	p := backup.New("liked", tracks, time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC))
+
+	out := &bytes.Buffer{}
+	err := writeBackup(p, dir, []string{"json", "m3u"}, out)
+	if err != nil {
+		t.Fatalf("writeBackup() error: %v", err)
+	}
+	for _, name := range []string{"liked-20240201-083000.json", "liked-20240201-083000.m3u"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Backup file %v not created: %v", name, err)
+		}
+	}
+
+	// Failed file is removed.
+	if err = writeBackup(p, dir, []string{"xml"}, out); err == nil {
+		t.Errorf("writeBackup() with unknown format should fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "liked-20240201-083000.xml")); err == nil {
+		t.Errorf("Backup file with unknown format should be removed")
+	}
+}
+
+func TestRunCommandErrors(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name string
+		args []string
+	}{
+		{"unknown", nil},
+		{"export", nil},
+		{"restore", nil},
+		{"restore", []string{"/nonexistent/backup.json"}},
+		{"restore", []string{"-new", "backup.json", "playlist"}},
+	} {
+		if err := runCommand(ctx, test.name, test.args); err == nil {
+			t.Errorf("runCommand(%v, %q) should fail", test.name, test.args)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package filters
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"fmt"
+	"github.com/golang/glog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Name of the file in the config directory that stores learned titles.
+	storeName = "streaming-playlist-maker-filters"
+
+	// Title is learned as a jingle when it was never found and it was not found that many times...
+	learnMinNotFound = 5
+	// ...or it was repeated that many times in the short interval.
+	learnMinRepeats          = 3
+	suspiciousRepeatInterval = 20 * time.Minute
+	// Every that many times the learned title is seen it is not dropped, so it can be unlearned when
+	// the saver finds it.
+	learnedRetryEvery = 10
+	// Titles not seen for that long are forgotten.
+	historyTtl = 180 * 24 * time.Hour
+
+	RuleDeny    = "deny"
+	RuleAllow   = "allow"
+	RuleLearned = "learned"
+)
+
+type FilterJob struct {
+	// Titles matching any of the regular expressions are dropped.
+	DenyTitles []string
+	// If set, only titles matching at least one of the regular expressions are kept.
+	AllowTitles []string
+	// If true, titles that repeat suspiciously often or are never found by the saver are dropped.
+	LearnFilter bool
+}
+
+type Filter struct {
+	conf    FilterJob
+	deny    []*regexp.Regexp
+	allow   []*regexp.Regexp
+	history *History
+}
+
+// Statistics of the titles seen by the job, persisted between runs.
+type History struct {
+	Titles map[string]*TitleStats
+	lock   sync.Mutex
+}
+
+type TitleStats struct {
+	Seen     int
+	Found    int
+	NotFound int
+	// Number of times the title was repeated in less than suspiciousRepeatInterval.
+	SuspiciousRepeats int
+	LastSeen          time.Time
+}
+
+// Histories of all the jobs.
+type Store struct {
+	histories map[string]*History
+	lock      sync.Mutex
+}
+
+// Loads the store from $HOME/.config/streaming-playlist-maker-filters.gob.
+func LoadStore() *Store {
+	histories := make(map[string]*History)
+	err := conf.LoadConfigFromFile(storeName, &histories)
+	if err != nil {
+		glog.Warningf("Could not load learned filters (%v), starting with empty ones.", err)
+		histories = make(map[string]*History)
+	}
+	return &Store{
+		histories: histories,
+	}
+}
+
+// Returns the history of the job, creates it if it does not exist.
+func (s *Store) History(jobName string) *History {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	h, ok := s.histories[jobName]
+	if !ok || h.Titles == nil {
+		h = &History{Titles: make(map[string]*TitleStats)}
+		s.histories[jobName] = h
+	}
+	return h
+}
+
+// Saves the histories, titles not seen for historyTtl are removed.
+func (s *Store) Save() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for _, h := range s.histories {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		h.prune(now)
+	}
+	return conf.SaveConfigToFile(storeName, s.histories)
+}
+
+// Creates the filter, history is used and updated only when conf.LearnFilter is true.
+func New(conf FilterJob, history *History) (*Filter, error) {
+	if !conf.LearnFilter {
+		history = nil
+	}
+	deny, err := compile(conf.DenyTitles)
+	if err != nil {
+		return nil, err
+	}
+	allow, err := compile(conf.AllowTitles)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{
+		conf:    conf,
+		deny:    deny,
+		allow:   allow,
+		history: history,
+	}, nil
+}
+
+// Returns the name of the rule that dropped the title or empty string if the title should be kept.
+func (f *Filter) Check(artistTitle string) string {
+	if f == nil {
+		return ""
+	}
+
+	stats := f.seen(artistTitle)
+
+	for _, r := range f.deny {
+		if r.MatchString(artistTitle) {
+			return RuleDeny + " " + r.String()
+		}
+	}
+
+	if len(f.allow) > 0 {
+		allowed := false
+		for _, r := range f.allow {
+			allowed = allowed || r.MatchString(artistTitle)
+		}
+		if !allowed {
+			return RuleAllow
+		}
+	}
+
+	if f.conf.LearnFilter && stats.isLearned() && stats.Seen%learnedRetryEvery != 0 {
+		return RuleLearned
+	}
+	return ""
+}
+
+// Records the result of saving the title: found is true if the saver found it (added or existing).
+func (f *Filter) Result(artistTitle string, found bool) {
+	if f == nil || f.history == nil {
+		return
+	}
+
+	f.history.lock.Lock()
+	defer f.history.lock.Unlock()
+
+	stats := f.history.get(artistTitle)
+	if found {
+		stats.Found++
+	} else {
+		stats.NotFound++
+	}
+}
+
+// Returns the titles that are currently learned as jingles.
+func (f *Filter) Learned() []string {
+	result := make([]string, 0)
+	if f == nil || f.history == nil {
+		return result
+	}
+
+	f.history.lock.Lock()
+	defer f.history.lock.Unlock()
+	for title, stats := range f.history.Titles {
+		if stats.isLearned() {
+			result = append(result, title)
+		}
+	}
+	return result
+}
+
+// Updates seen statistics and returns the copy of them.
+func (f *Filter) seen(artistTitle string) TitleStats {
+	if f.history == nil {
+		return TitleStats{}
+	}
+
+	f.history.lock.Lock()
+	defer f.history.lock.Unlock()
+
+	stats := f.history.get(artistTitle)
+	now := time.Now()
+	if now.Sub(stats.LastSeen) > historyTtl {
+		// Title was not seen for a long time, it gets a fresh start.
+		*stats = TitleStats{}
+	}
+	if !stats.LastSeen.IsZero() && now.Sub(stats.LastSeen) < suspiciousRepeatInterval {
+		stats.SuspiciousRepeats++
+	}
+	stats.Seen++
+	stats.LastSeen = now
+	return *stats
+}
+
+func (h *History) get(artistTitle string) *TitleStats {
+	key := strings.ToLower(strings.TrimSpace(artistTitle))
+	stats, ok := h.Titles[key]
+	if !ok {
+		stats = &TitleStats{}
+		h.Titles[key] = stats
+	}
+	return stats
+}
+
+// Removes the titles not seen for historyTtl, must be called with the lock held.
+func (h *History) prune(now time.Time) {
+	for title, stats := range h.Titles {
+		if now.Sub(stats.LastSeen) > historyTtl {
+			delete(h.Titles, title)
+		}
+	}
+}
+
+func (s TitleStats) isLearned() bool {
+	return s.Found == 0 && (s.NotFound >= learnMinNotFound || s.SuspiciousRepeats >= learnMinRepeats)
+}
+
+func compile(expressions []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, len(expressions))
+	for i, e := range expressions {
+		r, err := regexp.Compile(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", e, err)
+		}
+		result[i] = r
+	}
+	return result, nil
+}
@@ -0,0 +1,173 @@
+package filters
+
+import (
+	"testing"
+	"time"
+)
+
+func newHistory() *History {
+	return &History{Titles: make(map[string]*TitleStats)}
+}
+
+func TestCheck_regexps(t *testing.T) {
+	f, err := New(FilterJob{
+		DenyTitles:  []string{"(?i)reklama", "^RMF FM - "},
+		AllowTitles: []string{" - "},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for title, want := range map[string]string{
+		"Artist - Title":             "",
+		"REKLAMA - something":        "deny (?i)reklama",
+		"RMF FM - Najlepsza muzyka":  "deny ^RMF FM - ",
+		"Wiadomosci":                 "allow",
+		"Other artist - Other title": "",
+	} {
+		got := f.Check(title)
+		if got != want {
+			t.Errorf("Check(%q) got: %q, want: %q", title, got, want)
+		}
+	}
+}
+
+func TestCheck_invalidRegexp(t *testing.T) {
+	_, err := New(FilterJob{DenyTitles: []string{"("}}, nil)
+	if err == nil {
+		t.Errorf("got: nil, want: error")
+	}
+}
+
+func TestCheck_nil(t *testing.T) {
+	var f *Filter
+	if f.Check("a") != "" {
+		t.Errorf("nil filter should not drop titles")
+	}
+	f.Result("a", true)
+}
+
+func TestCheck_learnedNotFound(t *testing.T) {
+	h := newHistory()
+	f, _ := New(FilterJob{LearnFilter: true}, h)
+
+	for i := 0; i < learnMinNotFound; i++ {
+		if got := f.Check("Radio - Jingle"); got != "" {
+			t.Errorf("Check %d got: %q, want: empty", i, got)
+		}
+		f.Result("Radio - Jingle", false)
+		// Not repeated too often.
+		h.Titles["radio - jingle"].LastSeen = time.Now().Add(-time.Hour)
+	}
+	if got := f.Check("RADIO - JINGLE"); got != RuleLearned {
+		t.Errorf("got: %q, want: %q", got, RuleLearned)
+	}
+	if got := f.Learned(); len(got) != 1 || got[0] != "radio - jingle" {
+		t.Errorf("Learned got: %q, want: radio - jingle", got)
+	}
+
+	// Title that was found at least once is never learned.
+	f.Result("Radio - Jingle", true)
+	if got := f.Check("Radio - Jingle"); got != "" {
+		t.Errorf("got: %q, want: empty", got)
+	}
+}
+
+func TestCheck_learnedRepeats(t *testing.T) {
+	h := newHistory()
+	f, _ := New(FilterJob{LearnFilter: true}, h)
+
+	for i := 0; i < learnMinRepeats; i++ {
+		f.Check("Station - Ad")
+	}
+	if got := f.Check("Station - Ad"); got != RuleLearned {
+		t.Errorf("got: %q, want: %q", got, RuleLearned)
+	}
+
+	// Repeats after long time are not suspicious.
+	f.Check("Artist - Hit")
+	h.Titles["artist - hit"].LastSeen = time.Now().Add(-time.Hour)
+	for i := 0; i < learnMinRepeats; i++ {
+		if got := f.Check("Artist - Hit"); got != "" {
+			t.Errorf("got: %q, want: empty", got)
+		}
+		h.Titles["artist - hit"].LastSeen = time.Now().Add(-time.Hour)
+	}
+	if got := f.Check("Artist - Hit"); got != "" {
+		t.Errorf("got: %q, want: empty", got)
+	}
+}
+
+func TestCheck_learnedDisabled(t *testing.T) {
+	h := newHistory()
+	f, _ := New(FilterJob{}, h)
+	for i := 0; i < 2*learnMinNotFound; i++ {
+		f.Check("Radio - Jingle")
+		f.Result("Radio - Jingle", false)
+	}
+	if got := f.Check("Radio - Jingle"); got != "" {
+		t.Errorf("got: %q, want: empty", got)
+	}
+}
+
+func TestStoreHistory(t *testing.T) {
+	s := &Store{histories: make(map[string]*History)}
+	h1 := s.History("job")
+	h2 := s.History("job")
+	if h1 != h2 || h1.Titles == nil {
+		t.Errorf("History should be created once")
+	}
+}
+
+func TestCheck_learnedRetry(t *testing.T) {
+	h := newHistory()
+	f, _ := New(FilterJob{LearnFilter: true}, h)
+
+	passed := 0
+	for i := 0; i < 2*learnedRetryEvery; i++ {
+		if f.Check("Station - Ad") == "" {
+			passed++
+		}
+	}
+	// Not learned yet and the retry.
+	if want := learnMinRepeats + 2; passed != want {
+		t.Errorf("Passed got: %d, want: %d", passed, want)
+	}
+
+	// Retried title found by the saver is unlearned.
+	f.Result("Station - Ad", true)
+	if got := f.Check("Station - Ad"); got != "" {
+		t.Errorf("got: %q, want: empty", got)
+	}
+}
+
+func TestHistoryTtl(t *testing.T) {
+	h := newHistory()
+	f, _ := New(FilterJob{LearnFilter: true}, h)
+	for i := 0; i <= learnMinRepeats; i++ {
+		f.Check("Station - Ad")
+	}
+	f.Check("Artist - Hit")
+
+	// Learned title not seen for a long time gets a fresh start.
+	h.Titles["station - ad"].LastSeen = time.Now().Add(-historyTtl - time.Hour)
+	if got := f.Check("Station - Ad"); got != "" {
+		t.Errorf("got: %q, want: empty", got)
+	}
+
+	h.Titles["artist - hit"].LastSeen = time.Now().Add(-historyTtl - time.Hour)
+	h.prune(time.Now())
+	if _, ok := h.Titles["artist - hit"]; ok || len(h.Titles) != 1 {
+		t.Errorf("Titles after prune got: %v, want only station - ad", h.Titles)
+	}
+}
+
+func TestNew_historyWithoutLearning(t *testing.T) {
+	h := newHistory()
+	f, _ := New(FilterJob{}, h)
+	f.Check("Radio - Jingle")
+	f.Result("Radio - Jingle", false)
+	if len(h.Titles) != 0 {
+		t.Errorf("Titles got: %v, want: empty", h.Titles)
+	}
+}
@@ -1,13 +1,96 @@
 package main
 
 import (
+	"birnenlabs.com/go/streaming_playlist_maker/filters"
+	"birnenlabs.com/go/streaming_playlist_maker/pipeline"
 	"birnenlabs.com/go/streaming_playlist_maker/savers"
 	"birnenlabs.com/go/streaming_playlist_maker/sources"
+	"strconv"
 )
 
 type Job struct {
 	Name   string
 	Active bool
+	// If true songs are resolved to MusicBrainz recordings before saving.
+	Enrich bool
+	// Used in the daemon mode: cron expression ("minute hour day-of-month month day-of-week") or
+	// interval in minutes between the starts. Job without schedule is started once.
+	Schedule    string
+	IntervalMin int
+	// Savers used by the job. When empty the embedded SaverJob is used, otherwise every song
+	// is saved by all the savers from the list (and the embedded SaverJob is ignored).
+	Savers []savers.SaverJob
+	// Thresholds used to report the issues of the job after the run.
+	Issues IssueThresholds
 	sources.SourceJob
 	savers.SaverJob
+	filters.FilterJob
+	pipeline.PipelineJob
+}
+
+// Issues are reported when the numbers are outside of the thresholds, zero disables the check unless
+// stated otherwise.
+type IssueThresholds struct {
+	MinAdded int64
+	MinSeen  int64
+	// Errors relative to the added and existing songs, default 0.3 when not set. Zero reports any
+	// error, a negative value disables the check.
+	MaxErrorRatio *float64
+	// Not found songs relative to all the songs seen by the saver.
+	MaxNotFoundRatio float64
+	// Number of the most frequent not found and erroring songs named in the issues, default 3.
+	TopTitles int
+	// Number of the days without added songs, based on the state kept between the runs, default 3.
+	MaxDaysWithoutAdded int
+}
+
+// Returns the configs of all the savers used by the job.
+func (j Job) SaverJobs() []savers.SaverJob {
+	if len(j.Savers) == 0 {
+		return []savers.SaverJob{j.SaverJob}
+	}
+	return j.Savers
+}
+
+// Returns the pipeline of the job, SubstrMap is replaced by the first stage.
+func (j Job) pipelineJob() pipeline.PipelineJob {
+	if len(j.SubstrMap) == 0 {
+		return j.PipelineJob
+	}
+	stages := []pipeline.Stage{{Type: pipeline.StageReplace, Substrings: j.SubstrMap}}
+	return pipeline.PipelineJob{Pipeline: append(stages, j.Pipeline...)}
+}
+
+// Returns the statistics names of the job savers: the job name when there is one saver,
+// "name/saverType" otherwise (with a number when the saver type is used more than once).
+func (j Job) statsNames() []string {
+	saverJobs := j.SaverJobs()
+	if len(saverJobs) == 1 {
+		return []string{j.Name}
+	}
+
+	result := make([]string, len(saverJobs))
+	count := make(map[string]int)
+	for i, s := range saverJobs {
+		count[s.SaverType]++
+		result[i] = j.Name + "/" + s.SaverType
+		if count[s.SaverType] > 1 {
+			result[i] += strconv.Itoa(count[s.SaverType])
+		}
+	}
+	return result
+}
+
+func (t IssueThresholds) withDefaults() IssueThresholds {
+	if t.MaxErrorRatio == nil {
+		ratio := 0.3
+		t.MaxErrorRatio = &ratio
+	}
+	if t.TopTitles == 0 {
+		t.TopTitles = 3
+	}
+	if t.MaxDaysWithoutAdded == 0 {
+		t.MaxDaysWithoutAdded = 3
+	}
+	return t
 }
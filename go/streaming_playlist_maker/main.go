@@ -3,9 +3,12 @@ package main
 import (
 	glog "birnenlabs.com/go/lib/alog"
 	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/streaming_playlist_maker/filters"
+	"birnenlabs.com/go/streaming_playlist_maker/pipeline"
 	"birnenlabs.com/go/streaming_playlist_maker/savers"
 	"birnenlabs.com/go/streaming_playlist_maker/sources"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -19,6 +22,26 @@ const appName = "Streaming playlist maker"
 
 var config = flag.String("config", "streaming-playlist-maker", "Configuration")
 var skipCleaning = flag.Bool("skip-cleaning", false, "If true cleaning of playlist will be skipped")
+var fullRescan = flag.Bool("full-rescan", false, "If true chart history sources ignore the checkpoints and fetch the whole date range")
+var offline = flag.Bool("offline", false, "If true web sources are using only the cached pages")
+var daemonMode = flag.Bool("daemon", false, "If true the process keeps running and starts the jobs according to their schedules, config is reloaded on SIGHUP")
+
+// Saver used by the job with its config and the name used in the statistics.
+type saveTarget struct {
+	saver     savers.SongSaver
+	conf      savers.SaverJob
+	statsName string
+}
+
+// Sources, savers and caches used by the jobs. In the daemon mode they are reused between the runs,
+// so the OAuth clients and caches are created only once.
+type environment struct {
+	sources     map[string]sources.SongSource
+	savers      map[string]savers.SongSaver
+	enricher    *enricher
+	filterStore *filters.Store
+	lock        sync.RWMutex
+}
 
 func main() {
 	flag.Parse()
@@ -28,6 +51,14 @@ func main() {
 
 	glog.UseFormattedPayload(appName)
 
+	if flag.NArg() > 0 {
+		err := runCommand(ctx, flag.Arg(0), flag.Args()[1:])
+		if err != nil {
+			glog.Exitf("Command %v failed: %v", flag.Arg(0), err)
+		}
+		return
+	}
+
 	var jobs []Job
 	err := conf.LoadConfigFromJson(*config, &jobs)
 	if err != nil {
@@ -35,39 +66,62 @@ func main() {
 	}
 	glog.V(3).Infof("Loaded configuration: %+v", jobs)
 
-	sourcesMap, saversMap, err := createSourcesAndSavers(ctx, jobs)
+	env := &environment{
+		filterStore: filters.LoadStore(),
+	}
+	err = env.update(ctx, jobs)
 	if err != nil {
 		glog.Exit("Could not create sources and savers: ", err)
 	}
 
+	if *daemonMode {
+		runDaemon(ctx, env, jobs)
+		return
+	}
+
+	stats := &statistics{}
+	for _, conf := range jobs {
+		if !conf.Active {
+			continue
+		}
+		err = initStats(stats, conf)
+		if err != nil {
+			glog.Exitf("Could initialize stats: %v", err)
+		}
+	}
+
 	if *skipCleaning {
 		glog.Warningf("Skipping cleaning saver")
 	} else {
 		glog.Infof("Cleaning savers")
-		err = cleanSavers(ctx, saversMap, jobs)
+		err = cleanSavers(ctx, env, jobs, stats)
 		if err != nil {
 			glog.Exit("Could not clean savers: ", err)
 		}
 	}
 
 	glog.Infof("Starting jobs")
-	stats := &statistics{}
 	var wg sync.WaitGroup
 	for _, conf := range jobs {
 		if !conf.Active {
 			continue
 		}
 
-		err = stats.Init(conf.Name)
+		filter, err := env.newFilter(conf)
 		if err != nil {
-			glog.Exitf("Could initialize stats: %v", err)
+			glog.Exitf("Could not create filter for %v: %v", conf.Name, err)
+		}
+
+		p, err := pipeline.New(conf.pipelineJob())
+		if err != nil {
+			glog.Exitf("Could not create pipeline for %v: %v", conf.Name, err)
 		}
 
 		wg.Add(1)
-		go func(ctx context.Context, conf Job, source sources.SongSource, saver savers.SongSaver, stats *statistics) {
+		go func(ctx context.Context, conf Job, source sources.SongSource, targets []saveTarget, p *pipeline.Pipeline, filter *filters.Filter, e *enricher, stats *statistics) {
 			defer wg.Done()
-			startJob(ctx, conf, source, saver, stats)
-		}(ctx, conf, sourcesMap[conf.SourceType], saversMap[conf.SaverType], stats)
+			startJob(ctx, conf, source, targets, p, filter, e, stats)
+		}(ctx, conf, env.source(conf), env.targets(conf), p, filter, env.enricher, stats)
 	}
 
 	handleCtrlC(stats)
@@ -76,6 +130,9 @@ func main() {
 	wg.Wait()
 	glog.Infof("Jobs completed")
 
+	env.save()
+	saveJobStates(stats, jobs, time.Now())
+
 	issues := stats.FindIssues()
 	glog.Infof("Statistics:\n%v%v", issues, stats)
 
@@ -83,39 +140,124 @@ func main() {
 	if len(issues) > 0 {
 		glog.Error(issues)
 	}
+	err = emailReport(stats, issues)
+	if err != nil {
+		glog.Errorf("Could not email the report: %v", err)
+	}
+}
+
+// Runs the subcommand instead of the jobs.
+func runCommand(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "review":
+		return runReview(os.Stdin, os.Stdout)
+	case "override":
+		return runOverride(ctx, args, os.Stdout)
+	case "export":
+		return runExport(ctx, args, os.Stdout)
+	case "restore":
+		return runRestore(ctx, args, os.Stdout)
+	}
+	return fmt.Errorf("unknown command %q, available: review, override, export, restore", name)
 }
 
-func createSourcesAndSavers(ctx context.Context, jobs []Job) (map[string]sources.SongSource, map[string]savers.SongSaver, error) {
-	sourcesMap := make(map[string]sources.SongSource)
-	saversMap := make(map[string]savers.SongSaver)
+// Creates sources and savers (and the enricher if needed) used by the jobs, which do not exist yet.
+func (env *environment) update(ctx context.Context, jobs []Job) error {
+	env.lock.Lock()
+	defer env.lock.Unlock()
+
+	if env.sources == nil {
+		env.sources = make(map[string]sources.SongSource)
+		env.savers = make(map[string]savers.SongSaver)
+	}
 	for _, conf := range jobs {
-		_, ok := sourcesMap[conf.SourceType]
+		_, ok := env.sources[conf.SourceType]
 		if !ok {
 			source, err := sources.Create(ctx, conf.SourceType)
 			if err != nil {
-				return nil, nil, err
+				return err
 			}
-			sourcesMap[conf.SourceType] = source
+			env.sources[conf.SourceType] = source
 		}
 
-		_, ok = saversMap[conf.SaverType]
-		if !ok {
-			saver, err := savers.Create(ctx, conf.SaverType)
-			if err != nil {
-				return nil, nil, err
+		for _, saverJob := range conf.SaverJobs() {
+			_, ok = env.savers[saverJob.SaverType]
+			if !ok {
+				saver, err := savers.Create(ctx, saverJob.SaverType)
+				if err != nil {
+					return err
+				}
+				env.savers[saverJob.SaverType] = saver
 			}
-			saversMap[conf.SaverType] = saver
 		}
 
+		if conf.Active && conf.Enrich && env.enricher == nil {
+			env.enricher = newEnricher()
+		}
+	}
+	return nil
+}
+
+func (env *environment) source(conf Job) sources.SongSource {
+	env.lock.RLock()
+	defer env.lock.RUnlock()
+
+	return env.sources[conf.SourceType]
+}
+
+// Returns the savers used by the job.
+func (env *environment) targets(conf Job) []saveTarget {
+	env.lock.RLock()
+	defer env.lock.RUnlock()
+
+	names := conf.statsNames()
+	result := make([]saveTarget, 0)
+	for i, saverJob := range conf.SaverJobs() {
+		result = append(result, saveTarget{
+			saver:     env.savers[saverJob.SaverType],
+			conf:      saverJob,
+			statsName: names[i],
+		})
 	}
-	return sourcesMap, saversMap, nil
+	return result
+}
+
+func (env *environment) getEnricher() *enricher {
+	env.lock.RLock()
+	defer env.lock.RUnlock()
+
+	return env.enricher
+}
+
+func (env *environment) newFilter(conf Job) (*filters.Filter, error) {
+	return filters.New(conf.FilterJob, env.filterStore.History(conf.Name))
+}
 
+// Saves the caches and learned filters.
+func (env *environment) save() {
+	if e := env.getEnricher(); e != nil {
+		e.SaveCache()
+	}
+	env.lock.RLock()
+	for name, saver := range env.savers {
+		if c, ok := saver.(savers.CacheSaver); ok {
+			err := c.SaveCache()
+			if err != nil {
+				glog.Errorf("Could not save %v saver cache: %v", name, err)
+			}
+		}
+	}
+	env.lock.RUnlock()
+	err := env.filterStore.Save()
+	if err != nil {
+		glog.Errorf("Could not save learned filters: %v", err)
+	}
 }
 
-func handleCtrlC(stats *statistics) {
+func handleCtrlC(stats fmt.Stringer) {
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func(stats *statistics) {
+	go func(stats fmt.Stringer) {
 		<-c
 		glog.Infof("Statistics:\n%v", stats)
 		glog.Flush()
@@ -123,46 +265,70 @@ func handleCtrlC(stats *statistics) {
 	}(stats)
 }
 
-func printStatsSometimes(stats *statistics) {
+func printStatsSometimes(stats fmt.Stringer) {
 	for true {
 		time.Sleep(time.Second * 60)
 		glog.Infof("Statistics:\n%v", stats)
 	}
 }
 
-func cleanSavers(ctx context.Context, s map[string]savers.SongSaver, jobs []Job) error {
-	errors := make(chan error)
+// Initializes statistics of all the job savers.
+func initStats(stats *statistics, conf Job) error {
+	for _, name := range conf.statsNames() {
+		err := stats.Init(name)
+		if err != nil {
+			return err
+		}
+		stats.SetThresholds(name, conf.Issues)
+	}
+	return nil
+}
 
+// Cleans the savers of the jobs, results are stored in the statistics. Returns the first error after all
+// the savers finished cleaning.
+func cleanSavers(ctx context.Context, env *environment, jobs []Job, stats *statistics) error {
+	targets := make([]saveTarget, 0)
 	for _, conf := range jobs {
-		saver, ok := s[conf.SaverType]
-		if !ok {
-			return fmt.Errorf("Saver not found for %v", conf)
+		for _, t := range env.targets(conf) {
+			if t.saver == nil {
+				return fmt.Errorf("Saver not found for %v", conf)
+			}
+			targets = append(targets, t)
 		}
+	}
 
-		go func(ctx context.Context, s savers.SongSaver, conf Job, errors chan<- error) {
+	errors := make(chan error, len(targets))
+	for _, t := range targets {
+		go func(ctx context.Context, t saveTarget, errors chan<- error) {
 			start := time.Now()
-			status, err := s.Clean(ctx, conf.SaverJob)
+			status, err := t.saver.Clean(ctx, t.conf)
 			if err != nil {
-				glog.Errorf("[%15.15s] Cleaned after %v, error: %v", conf.Name, time.Now().Sub(start), err)
+				glog.Errorf("[%15.15s] Cleaned after %v, error: %v", t.statsName, time.Now().Sub(start), err)
 			} else {
-				glog.Infof("[%15.15s] Cleaned after %v, stats:\n%v", conf.Name, time.Now().Sub(start), status)
+				glog.Infof("[%15.15s] Cleaned after %v, stats:\n%v", t.statsName, time.Now().Sub(start), status)
+				stats.Cleaned(t.statsName, status)
 			}
 			errors <- err
-		}(ctx, saver, conf, errors)
+		}(ctx, t, errors)
 	}
 
-	var err error
-	for _ = range jobs {
-		err = <-errors
-		if err != nil {
-			return err
+	var result error
+	for range targets {
+		err := <-errors
+		if result == nil {
+			result = err
 		}
 	}
-	return nil
+	return result
 }
 
-func startJob(ctx context.Context, conf Job, source sources.SongSource, saver savers.SongSaver, stats *statistics) {
-	glog.Infof("[%15.15s] Starting: %v -> %v (%T -> %T).", conf.Name, conf.SourceType, conf.SaverType, source, saver)
+func startJob(ctx context.Context, conf Job, source sources.SongSource, targets []saveTarget, p *pipeline.Pipeline, filter *filters.Filter, e *enricher, stats *statistics) {
+	for _, t := range targets {
+		glog.Infof("[%15.15s] Starting: %v -> %v (%T -> %T).", t.statsName, conf.SourceType, t.conf.SaverType, source, t.saver)
+	}
+	conf.CheckpointName = conf.Name
+	conf.FullRescan = conf.FullRescan || *fullRescan
+	conf.Offline = conf.Offline || *offline
 	ch := make(chan sources.Song, 10)
 	err := source.Start(ctx, conf.SourceJob, ch)
 	if err != nil {
@@ -175,29 +341,99 @@ func startJob(ctx context.Context, conf Job, source sources.SongSource, saver sa
 	for ok {
 		song, ok = <-ch
 		if song.Error == nil && ok {
-			status, err := saver.Save(ctx, conf.SaverJob, song.ArtistTitle)
-
-			if err != nil {
-				glog.Errorf("[%15.15s] ERROR %q: %v", conf.Name, song.ArtistTitle, err)
-				stats.Error(conf.Name, song.ArtistTitle, err)
-			} else {
-				if status.SongAdded {
-					// Song added
-					glog.Infof("[%15.15s] A %3d %q -> %q added", conf.Name, status.MatchQuality, song.ArtistTitle, status.FoundTitle)
-					stats.Added(conf.Name, song.ArtistTitle)
-				} else if status.SongExists {
-					stats.Exists(conf.Name, song.ArtistTitle)
-					glog.Infof("[%15.15s] E %3d %q -> %q exists", conf.Name, status.MatchQuality, song.ArtistTitle, status.FoundTitle)
-				} else {
-					// not added and not exists -> not found
-					stats.NotFound(conf.Name, song.ArtistTitle)
-					glog.Infof("[%15.15s] N %3d %q -> %q not added", conf.Name, status.MatchQuality, song.ArtistTitle, status.FoundTitle)
+			song, stage := p.Apply(song)
+			if stage != "" {
+				glog.Infof("[%15.15s] F %q dropped by pipeline %v", conf.Name, song.ArtistTitle, stage)
+				for _, t := range targets {
+					stats.Filtered(t.statsName, song.ArtistTitle, "pipeline "+stage)
+				}
+				continue
+			}
+			if rule := filter.Check(song.ArtistTitle); rule != "" {
+				glog.Infof("[%15.15s] F %q filtered by %v", conf.Name, song.ArtistTitle, rule)
+				for _, t := range targets {
+					stats.Filtered(t.statsName, song.ArtistTitle, rule)
 				}
+				continue
+			}
+			radioTitle := song.ArtistTitle
+			if conf.Enrich && e != nil {
+				song = e.Enrich(conf.Name, song)
+			}
+
+			// Song is saved by all the savers concurrently.
+			var wg sync.WaitGroup
+			var lock sync.Mutex
+			saved, found := false, false
+			for _, t := range targets {
+				wg.Add(1)
+				go func(t saveTarget) {
+					defer wg.Done()
+					status, err := saveSong(ctx, t, song, stats)
+					lock.Lock()
+					defer lock.Unlock()
+					if err == nil {
+						saved = true
+						// Song filtered by the saver exists, it should not be learned as a jingle.
+						found = found || status.SongAdded || status.SongExists || status.SongFiltered || status.SongSeasonal || status.SongUnsuitable || status.SongQueued
+					}
+				}(t)
+			}
+			wg.Wait()
+			if saved {
+				filter.Result(radioTitle, found)
 			}
 		} else if song.Error != nil {
+			var reconnect *sources.ReconnectError
+			if errors.As(song.Error, &reconnect) {
+				for _, t := range targets {
+					stats.Reconnected(t.statsName, reconnect.TitleTimeout, reconnect.Downtime)
+				}
+			}
 			glog.Infof("[%15.15s] Error: %v", conf.Name, song.Error)
 		}
 		// TODO include channel size in stats
 	}
 	glog.Infof("[%15.15s] Source stopped.", conf.Name)
 }
+
+// Saves the song using the saver and updates its statistics, the ISRC is used if the saver supports it.
+func saveSong(ctx context.Context, t saveTarget, song sources.Song, stats *statistics) (*savers.Status, error) {
+	artistTitle := song.ArtistTitle
+	var status *savers.Status
+	var err error
+	if isrcSaver, ok := t.saver.(savers.IsrcSaver); ok && len(song.Isrc) > 0 {
+		status, err = isrcSaver.SaveIsrc(ctx, t.conf, artistTitle, song.Isrc)
+	} else {
+		status, err = t.saver.Save(ctx, t.conf, artistTitle)
+	}
+	if err != nil {
+		glog.Errorf("[%15.15s] ERROR %q: %v", t.statsName, artistTitle, err)
+		stats.Error(t.statsName, artistTitle, err)
+	} else if status.SongAdded {
+		// Song added
+		glog.Infof("[%15.15s] A %3d %q -> %q added", t.statsName, status.MatchQuality, artistTitle, status.FoundTitle)
+		stats.Added(t.statsName, artistTitle)
+		stats.AddedTrack(t.statsName, artistTitle, status)
+	} else if status.SongExists {
+		stats.Exists(t.statsName, artistTitle)
+		glog.Infof("[%15.15s] E %3d %q -> %q exists", t.statsName, status.MatchQuality, artistTitle, status.FoundTitle)
+	} else if status.SongFiltered {
+		stats.Filtered(t.statsName, artistTitle, "saver")
+		glog.Infof("[%15.15s] F %3d %q -> %q filtered by saver", t.statsName, status.MatchQuality, artistTitle, status.FoundTitle)
+	} else if status.SongSeasonal {
+		stats.Filtered(t.statsName, artistTitle, "seasonal")
+		glog.Infof("[%15.15s] S %3d %q -> %q out of season", t.statsName, status.MatchQuality, artistTitle, status.FoundTitle)
+	} else if status.SongUnsuitable {
+		stats.Filtered(t.statsName, artistTitle, "audio features")
+		glog.Infof("[%15.15s] U %3d %q -> %q unsuitable", t.statsName, status.MatchQuality, artistTitle, status.FoundTitle)
+	} else if status.SongQueued {
+		stats.Filtered(t.statsName, artistTitle, "review queue")
+		glog.Infof("[%15.15s] Q %3d %q -> %q waiting for review", t.statsName, status.MatchQuality, artistTitle, status.FoundTitle)
+	} else {
+		// not added and not exists -> not found
+		stats.NotFound(t.statsName, artistTitle)
+		glog.Infof("[%15.15s] N %3d %q -> %q not added", t.statsName, status.MatchQuality, artistTitle, status.FoundTitle)
+	}
+	return status, err
+}
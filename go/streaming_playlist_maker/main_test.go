@@ -0,0 +1,202 @@
+package main
+
+import (
+	"birnenlabs.com/go/streaming_playlist_maker/pipeline"
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"birnenlabs.com/go/streaming_playlist_maker/sources"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// Source sending the songs from the list.
+type fakeSource struct {
+	songs []string
+}
+
+func (s *fakeSource) Start(ctx context.Context, conf sources.SourceJob, song chan<- sources.Song) error {
+	go func() {
+		defer close(song)
+		for _, t := range s.songs {
+			song <- sources.Song{ArtistTitle: t}
+		}
+	}()
+	return nil
+}
+
+// Saver recording saved songs, songs from the notFound list are not found, songs from the fail list return error.
+type fakeSaver struct {
+	saved    []string
+	notFound map[string]bool
+	fail     map[string]bool
+	cleanErr error
+	cleaned  int
+	lock     sync.Mutex
+}
+
+func (s *fakeSaver) Clean(ctx context.Context, conf savers.SaverJob) (*savers.CleanStatus, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cleaned++
+	if s.cleanErr != nil {
+		return nil, s.cleanErr
+	}
+	return &savers.CleanStatus{}, nil
+}
+
+func (s *fakeSaver) Save(ctx context.Context, conf savers.SaverJob, artistTitle string) (*savers.Status, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.fail[artistTitle] {
+		return nil, fmt.Errorf("failed")
+	}
+	s.saved = append(s.saved, conf.Playlist+":"+artistTitle)
+	return &savers.Status{SongAdded: !s.notFound[artistTitle]}, nil
+}
+
+func TestStatsNames(t *testing.T) {
+	for _, test := range []struct {
+		conf Job
+		want []string
+	}{
+		{
+			conf: Job{Name: "job", SaverJob: savers.SaverJob{SaverType: "spotify"}},
+			want: []string{"job"},
+		},
+		{
+			conf: Job{Name: "job", Savers: []savers.SaverJob{{SaverType: "spotify"}}},
+			want: []string{"job"},
+		},
+		{
+			conf: Job{Name: "job", Savers: []savers.SaverJob{{SaverType: "spotify"}, {SaverType: "stdout"}, {SaverType: "spotify"}}},
+			want: []string{"job/spotify", "job/stdout", "job/spotify2"},
+		},
+	} {
+		got := test.conf.statsNames()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v got: %v, want: %v", test.conf, got, test.want)
+		}
+	}
+}
+
+func TestPipelineJob(t *testing.T) {
+	conf := Job{
+		SourceJob:   sources.SourceJob{SubstrMap: map[string]string{"RADIO - ": ""}},
+		PipelineJob: pipeline.PipelineJob{Pipeline: []pipeline.Stage{{Type: pipeline.StageCase}}},
+	}
+	p, err := pipeline.New(conf.pipelineJob())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, _ := p.Apply(sources.Song{ArtistTitle: "RADIO - ARTIST - TITLE"}); got.ArtistTitle != "Artist - Title" {
+		t.Errorf("Apply got: %q, want: %q", got.ArtistTitle, "Artist - Title")
+	}
+	if len(conf.Pipeline) != 1 {
+		t.Errorf("job pipeline was modified: %+v", conf.Pipeline)
+	}
+}
+
+func TestStartJobFanOut(t *testing.T) {
+	source := &fakeSource{songs: []string{"Artist - Song 1", "Artist - Song 2", "Artist - Song 3"}}
+	spotify := &fakeSaver{notFound: map[string]bool{"Artist - Song 2": true}}
+	archive := &fakeSaver{fail: map[string]bool{"Artist - Song 3": true}}
+	conf := Job{
+		Name: "job",
+		Savers: []savers.SaverJob{
+			{SaverType: "spotify", Playlist: "p1"},
+			{SaverType: "archive", Playlist: "p2"},
+		},
+	}
+	targets := []saveTarget{
+		{saver: spotify, conf: conf.Savers[0], statsName: "job/spotify"},
+		{saver: archive, conf: conf.Savers[1], statsName: "job/archive"},
+	}
+	stats := &statistics{}
+	err := initStats(stats, conf)
+	if err != nil {
+		t.Fatalf("initStats: %v", err)
+	}
+
+	startJob(context.Background(), conf, source, targets, nil, nil, nil, stats)
+
+	if want := []string{"p1:Artist - Song 1", "p1:Artist - Song 2", "p1:Artist - Song 3"}; !reflect.DeepEqual(spotify.saved, want) {
+		t.Errorf("spotify got: %v, want: %v", spotify.saved, want)
+	}
+	if want := []string{"p2:Artist - Song 1", "p2:Artist - Song 2"}; !reflect.DeepEqual(archive.saved, want) {
+		t.Errorf("archive got: %v, want: %v", archive.saved, want)
+	}
+	if s := stats.m["job/spotify"]; s.added != 2 || s.notFound != 1 || s.errors != 0 {
+		t.Errorf("spotify stats got: %+v, want: 2 added, 1 not found", s)
+	}
+	if s := stats.m["job/archive"]; s.added != 2 || s.notFound != 0 || s.errors != 1 {
+		t.Errorf("archive stats got: %+v, want: 2 added, 1 error", s)
+	}
+}
+
+// Saver recording the ISRC of the saved songs.
+type fakeIsrcSaver struct {
+	fakeSaver
+}
+
+func (s *fakeIsrcSaver) SaveIsrc(ctx context.Context, conf savers.SaverJob, artistTitle string, isrc string) (*savers.Status, error) {
+	return s.Save(ctx, conf, artistTitle+"|"+isrc)
+}
+
+func TestSaveSongIsrc(t *testing.T) {
+	stats := &statistics{}
+	stats.Init("job")
+	isrcSaver := &fakeIsrcSaver{}
+	saver := &fakeSaver{}
+	for _, song := range []sources.Song{
+		{ArtistTitle: "Artist - Song 1", Isrc: "ISRC1"},
+		{ArtistTitle: "Artist - Song 2"},
+	} {
+		for _, s := range []savers.SongSaver{isrcSaver, saver} {
+			_, err := saveSong(context.Background(), saveTarget{saver: s, statsName: "job"}, song, stats)
+			if err != nil {
+				t.Fatalf("saveSong(%v): %v", song, err)
+			}
+		}
+	}
+
+	if want := []string{":Artist - Song 1|ISRC1", ":Artist - Song 2"}; !reflect.DeepEqual(isrcSaver.saved, want) {
+		t.Errorf("ISRC saver got: %v, want: %v", isrcSaver.saved, want)
+	}
+	if want := []string{":Artist - Song 1", ":Artist - Song 2"}; !reflect.DeepEqual(saver.saved, want) {
+		t.Errorf("Saver got: %v, want: %v", saver.saved, want)
+	}
+}
+
+func TestCleanSavers(t *testing.T) {
+	ok := &fakeSaver{}
+	failing := &fakeSaver{cleanErr: fmt.Errorf("failed")}
+	env := &environment{savers: map[string]savers.SongSaver{"ok": ok, "failing": failing}}
+	jobs := []Job{
+		{Name: "job1", Active: true, Savers: []savers.SaverJob{{SaverType: "failing"}, {SaverType: "ok"}}},
+		{Name: "job2", Active: true, SaverJob: savers.SaverJob{SaverType: "ok"}},
+	}
+	stats := &statistics{}
+	for _, j := range jobs {
+		initStats(stats, j)
+	}
+
+	err := cleanSavers(context.Background(), env, jobs, stats)
+	if err == nil || err.Error() != "failed" {
+		t.Errorf("cleanSavers got: %v, want: failed", err)
+	}
+	// All the savers are cleaned before returning.
+	if ok.cleaned != 2 || failing.cleaned != 1 {
+		t.Errorf("Cleaned got: %d %d, want: 2 1", ok.cleaned, failing.cleaned)
+	}
+	if stats.m["job2"].clean == nil || stats.m["job1/failing"].clean != nil {
+		t.Errorf("Clean statuses got: %v %v, want only job2", stats.m["job2"].clean, stats.m["job1/failing"].clean)
+	}
+
+	// Missing saver is reported before cleaning.
+	err = cleanSavers(context.Background(), env, append(jobs, Job{Name: "job3", SaverJob: savers.SaverJob{SaverType: "missing"}}), stats)
+	if err == nil || ok.cleaned != 2 {
+		t.Errorf("cleanSavers with missing saver got: %v (%d cleaned), want: error and nothing cleaned", err, ok.cleaned)
+	}
+}
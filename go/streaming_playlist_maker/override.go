@@ -0,0 +1,52 @@
+package main
+
+import (
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const overrideUsage = `usage:
+  override list
+  override set "Artist - Title" <spotify track id, uri or link>
+  override never "Artist - Title"
+  override remove "Artist - Title"`
+
+// Edits the manual overrides used by the Spotify saver instead of searching.
+func runOverride(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(overrideUsage)
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		overrides, err := savers.Overrides()
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(overrides))
+		for k := range overrides {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(out, "%q -> %v\n", k, overrides[k])
+		}
+		return nil
+	case args[0] == "set" && len(args) == 3:
+		o, err := savers.NewOverride(ctx, args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%q -> %v\n", args[1], o)
+		return savers.SetOverride(args[1], o)
+	case args[0] == "never" && len(args) == 2:
+		return savers.SetOverride(args[1], savers.Override{})
+	case args[0] == "remove" && len(args) == 2:
+		return savers.RemoveOverride(args[1])
+	}
+	return errors.New(overrideUsage)
+}
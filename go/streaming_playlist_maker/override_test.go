@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunOverride(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+	ctx := context.Background()
+
+	out := &bytes.Buffer{}
+	for _, args := range [][]string{{}, {"list", "x"}, {"set", "a"}, {"unknown"}} {
+		if err := runOverride(ctx, args, out); err == nil {
+			t.Errorf("runOverride(%q) should fail", args)
+		}
+	}
+	if err := runOverride(ctx, []string{"set", "a", "invalid"}, out); err == nil {
+		t.Errorf("runOverride() with invalid track should fail")
+	}
+
+	if err := runOverride(ctx, []string{"never", "Radio - Jingle"}, out); err != nil {
+		t.Fatalf("runOverride(never) error: %v", err)
+	}
+	if err := runOverride(ctx, []string{"list"}, out); err != nil {
+		t.Fatalf("runOverride(list) error: %v", err)
+	}
+	if want := `"radio - jingle" -> never match`; !strings.Contains(out.String(), want) {
+		t.Errorf("runOverride(list) got: %q, want: %q", out, want)
+	}
+
+	if err := runOverride(ctx, []string{"remove", "radio - jingle"}, out); err != nil {
+		t.Fatalf("runOverride(remove) error: %v", err)
+	}
+	out.Reset()
+	runOverride(ctx, []string{"list"}, out)
+	if out.Len() != 0 {
+		t.Errorf("runOverride(list) after remove got: %q, want empty", out)
+	}
+}
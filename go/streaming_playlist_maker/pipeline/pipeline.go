@@ -0,0 +1,139 @@
+// Package pipeline contains song transformations and filters applied between the source and the savers.
+package pipeline
+
+import (
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"birnenlabs.com/go/streaming_playlist_maker/sources"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	StageRewrite   = "rewrite"
+	StageCase      = "case"
+	StageDedupe    = "dedupe"
+	StageReplace   = "replace"
+	StageBlocklist = "blocklist"
+	StageSeasonal  = "seasonal"
+	StageRateLimit = "ratelimit"
+)
+
+type PipelineJob struct {
+	// Stages applied in order to every song.
+	Pipeline []Stage
+}
+
+// Configuration of the stage, only the fields used by the stage Type should be set.
+type Stage struct {
+	// One of the Stage* constants.
+	Type string
+
+	// rewrite: regular expression and its replacement (can use $1 etc.). Song rewritten to an empty string is dropped.
+	Pattern     string
+	Replacement string
+
+	// replace: every key is replaced with its value (no regular expressions).
+	Substrings map[string]string
+
+	// case: "fix" (default, Title Case only when the song is all upper or all lower case), "title", "lower" or "upper".
+	Case string
+
+	// dedupe: song repeated within that many minutes is dropped.
+	WindowMin int
+
+	// blocklist: song containing any of the strings (case insensitive) is dropped.
+	Blocklist []string
+
+	// seasonal: song matching any of the rules is dropped outside of the rule window, the same way as by
+	// the savers (which also check the title of the found song). Christmas songs are always dropped when empty.
+	SeasonalRules []savers.SeasonalRule
+
+	// ratelimit: songs above that number in the last hour are dropped.
+	MaxPerHour int
+}
+
+// Stage transforms the song or drops it.
+type transformer interface {
+	// Returns the transformed song and true or false when the song should be dropped.
+	apply(song sources.Song, now time.Time) (sources.Song, bool)
+	// Name of the stage reported when the song is dropped.
+	name() string
+}
+
+// Pipeline is not thread safe, every job run should use its own instance.
+type Pipeline struct {
+	stages []transformer
+	now    func() time.Time
+}
+
+// Creates the pipeline, returns error when any of the stages is not valid.
+func New(conf PipelineJob) (*Pipeline, error) {
+	p := &Pipeline{
+		now: time.Now,
+	}
+	for i, s := range conf.Pipeline {
+		t, err := newStage(s)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline stage %d (%v): %v", i, s.Type, err)
+		}
+		p.stages = append(p.stages, t)
+	}
+	return p, nil
+}
+
+func newStage(s Stage) (transformer, error) {
+	switch s.Type {
+	case StageRewrite:
+		return newRewrite(s)
+	case StageCase:
+		return newCase(s)
+	case StageDedupe:
+		return newDedupe(s)
+	case StageReplace:
+		return newReplace(s)
+	case StageBlocklist:
+		return newBlocklist(s)
+	case StageSeasonal:
+		return newSeasonal(s)
+	case StageRateLimit:
+		return newRateLimit(s)
+	default:
+		return nil, fmt.Errorf("invalid stage type")
+	}
+}
+
+// Returns the transformed song and empty string or the name of the stage that dropped the song.
+func (p *Pipeline) Apply(song sources.Song) (sources.Song, string) {
+	if p == nil {
+		return song, ""
+	}
+
+	now := p.now()
+	for _, s := range p.stages {
+		var keep bool
+		song, keep = s.apply(song, now)
+		if !keep {
+			return song, s.name()
+		}
+	}
+	return song, ""
+}
+
+// Returns the song with the new artist and title. Artist and Title fields are kept in sync
+// when the source has set them.
+func withArtistTitle(song sources.Song, artistTitle string) sources.Song {
+	if artistTitle == song.ArtistTitle {
+		return song
+	}
+	song.ArtistTitle = artistTitle
+	if len(song.Artist) > 0 || len(song.Title) > 0 {
+		parts := strings.SplitN(artistTitle, " - ", 2)
+		if len(parts) == 2 {
+			song.Artist, song.Title = parts[0], parts[1]
+		} else {
+			song.Artist, song.Title = "", ""
+		}
+	}
+	return song
+}
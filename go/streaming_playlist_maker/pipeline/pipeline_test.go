@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"birnenlabs.com/go/streaming_playlist_maker/sources"
+	"testing"
+	"time"
+)
+
+func newTestPipeline(t *testing.T, now *time.Time, stages ...Stage) *Pipeline {
+	p, err := New(PipelineJob{Pipeline: stages})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	p.now = func() time.Time { return *now }
+	return p
+}
+
+func TestApply_transformations(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	p := newTestPipeline(t, &now,
+		Stage{Type: StageReplace, Substrings: map[string]string{"RADIO - ": "", "`": "'"}},
+		Stage{Type: StageCase},
+		Stage{Type: StageRewrite, Pattern: ` \(Radio Edit\)$`},
+		Stage{Type: StageRewrite, Pattern: `^(.*), The - `, Replacement: "The $1 - "},
+	)
+
+	for _, test := range []struct {
+		song sources.Song
+		want sources.Song
+	}{
+		{
+			song: sources.Song{ArtistTitle: "Artist - Title (Radio Edit)"},
+			want: sources.Song{ArtistTitle: "Artist - Title"},
+		},
+		{
+			song: sources.Song{ArtistTitle: "BEATLES, THE - LET IT BE"},
+			want: sources.Song{ArtistTitle: "The Beatles - Let It Be"},
+		},
+		{
+			song: sources.Song{ArtistTitle: "michael jackson - don't stop 'til you get enough", Artist: "michael jackson", Title: "don't stop 'til you get enough"},
+			want: sources.Song{ArtistTitle: "Michael Jackson - Don't Stop 'til You Get Enough", Artist: "Michael Jackson", Title: "Don't Stop 'til You Get Enough"},
+		},
+		{
+			song: sources.Song{ArtistTitle: "Beatles, The - Help (Radio Edit)", Artist: "Beatles, The", Title: "Help (Radio Edit)"},
+			want: sources.Song{ArtistTitle: "The Beatles - Help", Artist: "The Beatles", Title: "Help"},
+		},
+		{
+			song: sources.Song{ArtistTitle: "RADIO - Artist - Don`t"},
+			want: sources.Song{ArtistTitle: "Artist - Don't"},
+		},
+		{
+			// Mixed case is kept.
+			song: sources.Song{ArtistTitle: "AC/DC - Thunderstruck"},
+			want: sources.Song{ArtistTitle: "AC/DC - Thunderstruck"},
+		},
+	} {
+		got, stage := p.Apply(test.song)
+		if stage != "" || got != test.want {
+			t.Errorf("Apply(%+v) got: %+v %q, want: %+v", test.song, got, stage, test.want)
+		}
+	}
+}
+
+func TestApply_filters(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	p := newTestPipeline(t, &now,
+		Stage{Type: StageBlocklist, Blocklist: []string{"Jingle", "reklama"}},
+		Stage{Type: StageDedupe, WindowMin: 60},
+		Stage{Type: StageRateLimit, MaxPerHour: 3},
+	)
+
+	for _, test := range []struct {
+		title string
+		after time.Duration
+		want  string
+	}{
+		{"Radio - JINGLE", 0, StageBlocklist},
+		{"Artist - Song 1", 0, ""},
+		{"artist - song 1", time.Minute, StageDedupe},
+		{"Artist - Song 2", time.Minute, ""},
+		{"Artist - Song 3", time.Minute, ""},
+		{"Artist - Song 4", time.Minute, StageRateLimit},
+		// Song 1 left the rate limit and dedupe windows.
+		{"Artist - Song 1", time.Hour, ""},
+	} {
+		now = now.Add(test.after)
+		_, got := p.Apply(sources.Song{ArtistTitle: test.title})
+		if got != test.want {
+			t.Errorf("Apply(%q) got: %q, want: %q", test.title, got, test.want)
+		}
+	}
+}
+
+func TestApply_seasonal(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	p := newTestPipeline(t, &now,
+		Stage{Type: StageSeasonal, SeasonalRules: []savers.SeasonalRule{
+			{Keywords: []string{"christmas"}, Start: "12-01", End: "12-26"},
+			{Name: "summer", Keywords: []string{"summer"}, Start: "06-01", End: "08-31"},
+		}},
+	)
+	defaults := newTestPipeline(t, &now, Stage{Type: StageSeasonal})
+
+	for _, test := range []struct {
+		title        string
+		now          time.Time
+		want         string
+		wantDefaults string
+	}{
+		{"Wham! - Last Christmas", time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC), StageSeasonal, StageSeasonal},
+		{"Wham! - Last Christmas", time.Date(2021, 12, 24, 12, 0, 0, 0, time.UTC), "", StageSeasonal},
+		{"Artist - Summer Song", time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC), "", ""},
+		{"Artist - Summer Song", time.Date(2021, 12, 24, 12, 0, 0, 0, time.UTC), StageSeasonal, ""},
+		{"Artist - Song", time.Date(2021, 12, 24, 12, 0, 0, 0, time.UTC), "", ""},
+	} {
+		now = test.now
+		if _, got := p.Apply(sources.Song{ArtistTitle: test.title}); got != test.want {
+			t.Errorf("Apply(%q) at %v got: %q, want: %q", test.title, now, got, test.want)
+		}
+		if _, got := defaults.Apply(sources.Song{ArtistTitle: test.title}); got != test.wantDefaults {
+			t.Errorf("Apply(%q) with default rules at %v got: %q, want: %q", test.title, now, got, test.wantDefaults)
+		}
+	}
+}
+
+func TestApply_dedupeWindow(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	p := newTestPipeline(t, &now, Stage{Type: StageDedupe, WindowMin: 60})
+
+	for _, test := range []struct {
+		after time.Duration
+		want  string
+	}{
+		{0, ""},
+		{40 * time.Minute, StageDedupe},
+		// Dropped song did not extend the window.
+		{40 * time.Minute, ""},
+		{40 * time.Minute, StageDedupe},
+	} {
+		now = now.Add(test.after)
+		if _, got := p.Apply(sources.Song{ArtistTitle: "Artist - Song"}); got != test.want {
+			t.Errorf("Apply at %v got: %q, want: %q", now, got, test.want)
+		}
+	}
+}
+
+func TestApply_empty(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, s := range []Stage{
+		{Type: StageRewrite, Pattern: `.*jingle.*`},
+		{Type: StageReplace, Substrings: map[string]string{"jingle": ""}},
+	} {
+		p := newTestPipeline(t, &now, s)
+		song := sources.Song{ArtistTitle: "jingle"}
+		if got, stage := p.Apply(song); got != song || stage != s.Type {
+			t.Errorf("Apply(%+v) got: %+v %q, want: original song dropped by %q", s, got, stage, s.Type)
+		}
+	}
+}
+
+func TestNew_invalid(t *testing.T) {
+	for _, s := range []Stage{
+		{Type: "unknown"},
+		{Type: StageRewrite},
+		{Type: StageRewrite, Pattern: "("},
+		{Type: StageCase, Case: "camel"},
+		{Type: StageDedupe},
+		{Type: StageBlocklist},
+		{Type: StageReplace},
+		{Type: StageReplace, Substrings: map[string]string{"": "a"}},
+		{Type: StageSeasonal, SeasonalRules: []savers.SeasonalRule{{Start: "13-01", End: "12-26"}}},
+		{Type: StageRateLimit},
+	} {
+		_, err := New(PipelineJob{Pipeline: []Stage{s}})
+		if err == nil {
+			t.Errorf("New(%+v) got: nil, want: error", s)
+		}
+	}
+}
+
+func TestApply_nil(t *testing.T) {
+	var p *Pipeline
+	song := sources.Song{ArtistTitle: "a - b"}
+	if got, stage := p.Apply(song); got != song || stage != "" {
+		t.Errorf("nil pipeline should not change songs")
+	}
+}
@@ -0,0 +1,268 @@
+package pipeline
+
+import (
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"birnenlabs.com/go/streaming_playlist_maker/sources"
+	"fmt"
+	"github.com/golang/glog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type rewriteStage struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+type replaceStage struct {
+	// Substrings sorted so they are always replaced in the same order.
+	substrings   []string
+	replacements map[string]string
+}
+
+type caseStage struct {
+	mode string
+}
+
+type dedupeStage struct {
+	window   time.Duration
+	lastSeen map[string]time.Time
+}
+
+type blocklistStage struct {
+	blocklist []string
+}
+
+type seasonalStage struct {
+	rules []savers.SeasonalRule
+}
+
+type rateLimitStage struct {
+	maxPerHour int
+	// Times of the songs passed in the last hour.
+	passed []time.Time
+}
+
+func newRewrite(s Stage) (transformer, error) {
+	if len(s.Pattern) == 0 {
+		return nil, fmt.Errorf("Pattern not set")
+	}
+	pattern, err := regexp.Compile(s.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &rewriteStage{
+		pattern:     pattern,
+		replacement: s.Replacement,
+	}, nil
+}
+
+func (r *rewriteStage) apply(song sources.Song, now time.Time) (sources.Song, bool) {
+	artistTitle := strings.TrimSpace(r.pattern.ReplaceAllString(song.ArtistTitle, r.replacement))
+	return withNonEmptyArtistTitle(song, artistTitle, StageRewrite)
+}
+
+func (r *rewriteStage) name() string {
+	return StageRewrite
+}
+
+func newReplace(s Stage) (transformer, error) {
+	if len(s.Substrings) == 0 {
+		return nil, fmt.Errorf("Substrings not set")
+	}
+	r := &replaceStage{
+		replacements: s.Substrings,
+	}
+	for substr := range s.Substrings {
+		if len(substr) == 0 {
+			return nil, fmt.Errorf("empty substring")
+		}
+		r.substrings = append(r.substrings, substr)
+	}
+	sort.Strings(r.substrings)
+	return r, nil
+}
+
+func (r *replaceStage) apply(song sources.Song, now time.Time) (sources.Song, bool) {
+	artistTitle := song.ArtistTitle
+	for _, substr := range r.substrings {
+		artistTitle = strings.Replace(artistTitle, substr, r.replacements[substr], -1)
+	}
+	return withNonEmptyArtistTitle(song, strings.TrimSpace(artistTitle), StageReplace)
+}
+
+func (r *replaceStage) name() string {
+	return StageReplace
+}
+
+// Returns the song with the new artist and title, the original song is dropped when the new
+// artist and title is empty.
+func withNonEmptyArtistTitle(song sources.Song, artistTitle string, stage string) (sources.Song, bool) {
+	if len(artistTitle) == 0 {
+		glog.V(1).Infof("Dropping %q, %v stage returned empty song", song.ArtistTitle, stage)
+		return song, false
+	}
+	return withArtistTitle(song, artistTitle), true
+}
+
+func newCase(s Stage) (transformer, error) {
+	switch s.Case {
+	case "":
+		return &caseStage{mode: "fix"}, nil
+	case "fix", "title", "lower", "upper":
+		return &caseStage{mode: s.Case}, nil
+	default:
+		return nil, fmt.Errorf("invalid Case %q", s.Case)
+	}
+}
+
+func (c *caseStage) apply(song sources.Song, now time.Time) (sources.Song, bool) {
+	convert := c.convert(song.ArtistTitle)
+	if convert == nil {
+		return song, true
+	}
+	song.ArtistTitle = convert(song.ArtistTitle)
+	song.Artist = convert(song.Artist)
+	song.Title = convert(song.Title)
+	return song, true
+}
+
+// Returns the conversion function or nil if the song should not be changed.
+func (c *caseStage) convert(s string) func(string) string {
+	switch c.mode {
+	case "title":
+		return titleCase
+	case "lower":
+		return strings.ToLower
+	case "upper":
+		return strings.ToUpper
+	default:
+		if s == strings.ToUpper(s) || s == strings.ToLower(s) {
+			return titleCase
+		}
+		return nil
+	}
+}
+
+func (c *caseStage) name() string {
+	return StageCase
+}
+
+// Capitalizes first letter of every word, other letters are lower case.
+func titleCase(s string) string {
+	result := []rune(strings.ToLower(s))
+	wordStart := true
+	for i, r := range result {
+		if wordStart && unicode.IsLetter(r) {
+			result[i] = unicode.ToUpper(r)
+		}
+		// Apostrophe does not start a new word (e.g. "Don't").
+		wordStart = !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}
+	return string(result)
+}
+
+func newDedupe(s Stage) (transformer, error) {
+	if s.WindowMin <= 0 {
+		return nil, fmt.Errorf("WindowMin should be positive")
+	}
+	return &dedupeStage{
+		window:   time.Duration(s.WindowMin) * time.Minute,
+		lastSeen: make(map[string]time.Time),
+	}, nil
+}
+
+func (d *dedupeStage) apply(song sources.Song, now time.Time) (sources.Song, bool) {
+	key := strings.ToLower(song.ArtistTitle)
+	if last, ok := d.lastSeen[key]; ok && now.Sub(last) < d.window {
+		// Dropped songs do not extend the window.
+		return song, false
+	}
+	d.lastSeen[key] = now
+	return song, true
+}
+
+func (d *dedupeStage) name() string {
+	return StageDedupe
+}
+
+func newBlocklist(s Stage) (transformer, error) {
+	if len(s.Blocklist) == 0 {
+		return nil, fmt.Errorf("Blocklist not set")
+	}
+	return &blocklistStage{
+		blocklist: lowerAll(s.Blocklist),
+	}, nil
+}
+
+func (b *blocklistStage) apply(song sources.Song, now time.Time) (sources.Song, bool) {
+	return song, !containsAny(song.ArtistTitle, b.blocklist)
+}
+
+func (b *blocklistStage) name() string {
+	return StageBlocklist
+}
+
+func newSeasonal(s Stage) (transformer, error) {
+	rules, err := savers.SeasonalRules(s.SeasonalRules)
+	if err != nil {
+		return nil, err
+	}
+	return &seasonalStage{
+		rules: rules,
+	}, nil
+}
+
+func (s *seasonalStage) apply(song sources.Song, now time.Time) (sources.Song, bool) {
+	return song, savers.NotAllowedRule(s.rules, song.ArtistTitle, now) == nil
+}
+
+func (s *seasonalStage) name() string {
+	return StageSeasonal
+}
+
+func newRateLimit(s Stage) (transformer, error) {
+	if s.MaxPerHour <= 0 {
+		return nil, fmt.Errorf("MaxPerHour should be positive")
+	}
+	return &rateLimitStage{
+		maxPerHour: s.MaxPerHour,
+	}, nil
+}
+
+func (r *rateLimitStage) apply(song sources.Song, now time.Time) (sources.Song, bool) {
+	for len(r.passed) > 0 && now.Sub(r.passed[0]) >= time.Hour {
+		r.passed = r.passed[1:]
+	}
+	if len(r.passed) >= r.maxPerHour {
+		return song, false
+	}
+	r.passed = append(r.passed, now)
+	return song, true
+}
+
+func (r *rateLimitStage) name() string {
+	return StageRateLimit
+}
+
+func lowerAll(list []string) []string {
+	result := make([]string, len(list))
+	for i, s := range list {
+		result[i] = strings.ToLower(s)
+	}
+	return result
+}
+
+// Returns true if s contains (case insensitive) any of the lower case substrings.
+func containsAny(s string, substrings []string) bool {
+	s = strings.ToLower(s)
+	for _, substr := range substrings {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
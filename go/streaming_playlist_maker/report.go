@@ -0,0 +1,160 @@
+package main
+
+import (
+	"birnenlabs.com/go/lib/mailgun"
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+// Maximum number of the added songs listed per job, the rest is only counted.
+const maxReportTracks = 100
+
+var reportEmail = flag.String("report-email", "", "If set the run report is emailed to this address using the mailgun config, in the daemon mode only the runs with issues are reported")
+
+var reportTemplate = template.Must(template.New("report").Parse(`<html><body>
+{{if .Issues}}<h3>Issues</h3><ul>{{range .Issues}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{range .Jobs}}<h3>{{.Name}}</h3>
+<p>Added: {{.Added}}, not found: {{.NotFound}}, exists: {{.Exists}}, errors: {{.Errors}}{{range $rule, $count := .Filtered}}, filtered by {{$rule}}: {{$count}}{{end}}</p>
+{{with .Clean}}<p>Cleaning: unavailable {{.Unavailable}} (replaced {{.UnavailableReplaced}}), removed duplicates {{.Duplicates}}, terrible {{.Terrible}}, seasonal {{.Seasonal}}, filtered {{.Filtered}}, similar {{.SimilarRemoved}}</p>
+{{if .Similar}}<table>{{range .Similar}}<tr><td>{{.AvgMatchRatio}}</td><td>{{.Title1}}</td><td>{{.Title2}}</td><td>{{if .Removed}}removed {{.Removed}} ({{.RemovedBy}}){{end}}</td></tr>{{end}}</table>{{end}}{{end}}
+{{if .Tracks}}<ul>{{range .Tracks}}<li>{{if .Url}}<a href="{{.Url}}">{{.FoundTitle}}</a>{{else}}{{.FoundTitle}}{{end}} ({{.ArtistTitle}})</li>{{end}}{{if .MoreTracks}}<li>and {{.MoreTracks}} more</li>{{end}}</ul>{{end}}
+{{end}}</body></html>`))
+
+type runReport struct {
+	Issues []string
+	Jobs   []jobReport
+}
+
+type jobReport struct {
+	Name     string
+	Added    int64
+	NotFound int64
+	Exists   int64
+	Errors   int64
+	Filtered map[string]int64
+	Clean    *savers.CleanStatus
+	Tracks   []addedTrack
+	// Number of the added songs which are not listed.
+	MoreTracks int
+}
+
+// Sends the report when the report email is set.
+func emailReport(stats *statistics, issues string) error {
+	if len(*reportEmail) == 0 {
+		return nil
+	}
+
+	m, err := mailgun.New()
+	if err != nil {
+		return err
+	}
+	r := stats.report(issues)
+	html, err := r.Html()
+	if err != nil {
+		return err
+	}
+	return m.SendEmail(mailgun.Email{
+		From:    fmt.Sprintf("%s <%s>", appName, m.CreateAddress("streaming-playlist-maker")),
+		To:      *reportEmail,
+		Subject: r.Subject(),
+		Text:    r.Text(),
+		Html:    html,
+	})
+}
+
+// Returns the snapshot of the statistics with the jobs sorted by name.
+func (s *statistics) report(issues string) *runReport {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	r := &runReport{}
+	for _, line := range strings.Split(issues, "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			r.Issues = append(r.Issues, line)
+		}
+	}
+	for k, v := range s.m {
+		j := jobReport{
+			Name:     k,
+			Added:    v.added,
+			NotFound: v.notFound,
+			Exists:   v.exists,
+			Errors:   v.errors,
+			Filtered: make(map[string]int64),
+			Clean:    v.clean,
+			Tracks:   v.tracks,
+		}
+		for rule, count := range v.filtered {
+			j.Filtered[rule] = count
+		}
+		if len(j.Tracks) > maxReportTracks {
+			j.MoreTracks = len(j.Tracks) - maxReportTracks
+			j.Tracks = j.Tracks[:maxReportTracks]
+		}
+		r.Jobs = append(r.Jobs, j)
+	}
+	sort.Slice(r.Jobs, func(i, j int) bool { return r.Jobs[i].Name < r.Jobs[j].Name })
+	return r
+}
+
+func (r *runReport) Subject() string {
+	added := int64(0)
+	for _, j := range r.Jobs {
+		added += j.Added
+	}
+	subject := fmt.Sprintf("%s: %d songs added", appName, added)
+	if len(r.Issues) > 0 {
+		subject += fmt.Sprintf(", %d issues", len(r.Issues))
+	}
+	return subject
+}
+
+func (r *runReport) Text() string {
+	var buf bytes.Buffer
+	for _, issue := range r.Issues {
+		buf.WriteString("Issue: ")
+		buf.WriteString(issue)
+		buf.WriteString("\n")
+	}
+	for _, j := range r.Jobs {
+		buf.WriteString(fmt.Sprintf("\n[%v] Added: %d, not found: %d, exists: %d, errors: %d\n", j.Name, j.Added, j.NotFound, j.Exists, j.Errors))
+		rules := make([]string, 0, len(j.Filtered))
+		for rule := range j.Filtered {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		for _, rule := range rules {
+			buf.WriteString(fmt.Sprintf("Filtered by %q: %d\n", rule, j.Filtered[rule]))
+		}
+		if j.Clean != nil {
+			buf.WriteString(j.Clean.String())
+			buf.WriteString("\n")
+		}
+		for _, t := range j.Tracks {
+			buf.WriteString(fmt.Sprintf("+ %v (%v)", t.FoundTitle, t.ArtistTitle))
+			if len(t.Url) > 0 {
+				buf.WriteString(" ")
+				buf.WriteString(t.Url)
+			}
+			buf.WriteString("\n")
+		}
+		if j.MoreTracks > 0 {
+			buf.WriteString(fmt.Sprintf("+ and %d more\n", j.MoreTracks))
+		}
+	}
+	return buf.String()
+}
+
+func (r *runReport) Html() (string, error) {
+	var buf bytes.Buffer
+	err := reportTemplate.Execute(&buf, r)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	s := &statistics{}
+	s.Init("b")
+	s.Init("a")
+	s.Added("a", "Artist - Song")
+	s.AddedTrack("a", "Artist - Song", &savers.Status{FoundTitle: "Artist - Song <Live>", FoundUrl: "https://open.spotify.com/track/1"})
+	s.Filtered("a", "Artist - Jingle", "jingle")
+	s.Cleaned("a", &savers.CleanStatus{Duplicates: 2, Similar: []*savers.SimilarTrack{{Title1: "X - One", Title2: "X - One (Remix)", AvgMatchRatio: 90}}})
+	// Jobs which are not tracked are ignored.
+	s.Cleaned("inactive", &savers.CleanStatus{})
+	for i := 0; i < maxReportTracks+5; i++ {
+		s.AddedTrack("b", "Other - Song", &savers.Status{FoundTitle: "Other - Song"})
+	}
+
+	r := s.report("b: no songs were added or existed before.\n")
+	if len(r.Jobs) != 2 || r.Jobs[0].Name != "a" || r.Jobs[1].Name != "b" {
+		t.Fatalf("Jobs got: %+v, want a and b", r.Jobs)
+	}
+	if len(r.Jobs[1].Tracks) != maxReportTracks || r.Jobs[1].MoreTracks != 5 {
+		t.Errorf("Tracks got: %d (%d more), want: %d (5 more)", len(r.Jobs[1].Tracks), r.Jobs[1].MoreTracks, maxReportTracks)
+	}
+	if got, want := r.Subject(), appName+": 1 songs added, 1 issues"; got != want {
+		t.Errorf("Subject got: %q, want: %q", got, want)
+	}
+
+	text := r.Text()
+	for _, want := range []string{
+		"Issue: b: no songs were added",
+		"[a] Added: 1",
+		`Filtered by "jingle": 1`,
+		"Removed duplicates:   2",
+		"X - One = X - One (Remix)",
+		"+ Artist - Song <Live> (Artist - Song) https://open.spotify.com/track/1\n",
+		"+ Other - Song (Other - Song)\n",
+		"+ and 5 more",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Text got: %v, want: %q", text, want)
+		}
+	}
+
+	html, err := r.Html()
+	if err != nil {
+		t.Fatalf("Html: %v", err)
+	}
+	for _, want := range []string{
+		`<a href="https://open.spotify.com/track/1">Artist - Song &lt;Live&gt;</a>`,
+		"<td>X - One (Remix)</td>",
+		"removed duplicates 2",
+		"<li>and 5 more</li>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Html got: %v, want: %q", html, want)
+		}
+	}
+}
+
+func TestEmailReportDisabled(t *testing.T) {
+	err := emailReport(&statistics{}, "")
+	if err != nil {
+		t.Errorf("emailReport without the address got: %v, want: nil", err)
+	}
+}
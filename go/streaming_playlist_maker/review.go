@@ -0,0 +1,65 @@
+package main
+
+import (
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Shows the songs waiting for the review one by one and asks for the decision:
+// number picks the candidate, "r" rejects the song, "s" or empty line skips it and "q" quits.
+func runReview(in io.Reader, out io.Writer) error {
+	items, err := savers.PendingReviews()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(out, "Nothing to review.")
+		return nil
+	}
+
+	scanner := bufio.NewScanner(in)
+	for i, item := range items {
+		fmt.Fprintf(out, "\n[%d/%d] %v: %q (played %d times)\n", i+1, len(items), item.Playlist, item.ArtistTitle, item.Seen)
+		for j, c := range item.Candidates {
+			fmt.Fprintf(out, "  %d) %3d %v\n", j+1, c.MatchQuality, c)
+		}
+
+		for {
+			fmt.Fprint(out, "Candidate number, (r)eject, (s)kip or (q)uit: ")
+			if !scanner.Scan() {
+				fmt.Fprintln(out)
+				return scanner.Err()
+			}
+
+			answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+			if answer == "q" {
+				return nil
+			}
+			if answer == "s" || answer == "" {
+				break
+			}
+			if answer == "r" {
+				err := savers.DecideReview(item, -1)
+				if err != nil {
+					return err
+				}
+				break
+			}
+			n, err := strconv.Atoi(answer)
+			if err != nil || n < 1 || n > len(item.Candidates) {
+				fmt.Fprintf(out, "Invalid answer: %q\n", answer)
+				continue
+			}
+			err = savers.DecideReview(item, n-1)
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunReview(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	out := &bytes.Buffer{}
+	err := runReview(strings.NewReader(""), out)
+	if err != nil || !strings.Contains(out.String(), "Nothing to review") {
+		t.Fatalf("runReview() on empty queue got: %q %v", out, err)
+	}
+
+	// The queue file is written directly, the same way the saver does it.
+	queue := struct {
+		Items map[string]*savers.ReviewItem
+	}{
+		Items: map[string]*savers.ReviewItem{
+			"p|a": {Playlist: "p", ArtistTitle: "a", Added: time.Unix(1, 0), Candidates: []savers.ReviewCandidate{{Id: "1"}, {Id: "2"}}},
+			"p|b": {Playlist: "p", ArtistTitle: "b", Added: time.Unix(2, 0), Candidates: []savers.ReviewCandidate{{Id: "3"}}},
+			"p|c": {Playlist: "p", ArtistTitle: "c", Added: time.Unix(3, 0)},
+		},
+	}
+	err = conf.SaveConfigToFile("streaming-playlist-maker-review", queue)
+	if err != nil {
+		t.Fatalf("Could not save queue: %v", err)
+	}
+
+	// Invalid answer is repeated, second candidate of "a" is approved and "b" is rejected, "c" is not answered.
+	out.Reset()
+	err = runReview(strings.NewReader("5\n2\nr\n"), out)
+	if err != nil {
+		t.Fatalf("runReview() error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Invalid answer") {
+		t.Errorf("runReview() output %q should report invalid answer", out)
+	}
+
+	pending, err := savers.PendingReviews()
+	if err != nil || len(pending) != 1 || pending[0].ArtistTitle != "c" {
+		t.Errorf("PendingReviews() got: %+v, want only %q", pending, "c")
+	}
+	overrides, err := savers.Overrides()
+	if err != nil || overrides["a"].TrackId != "2" || overrides["b"].TrackId != "" || len(overrides) != 2 {
+		t.Errorf("Overrides() got: %+v, want a -> 2 and b rejected", overrides)
+	}
+}
@@ -0,0 +1,119 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"strings"
+)
+
+func (c SaverJob) hasArtistFilter() bool {
+	return len(c.AllowArtists)+len(c.DenyArtists) > 0 || c.hasGenreFilter()
+}
+
+func (c SaverJob) hasGenreFilter() bool {
+	return len(c.AllowGenres)+len(c.DenyGenres) > 0
+}
+
+// Returns the reason why the song should not be in the playlist or empty string if it is allowed.
+func artistFilterReason(conf SaverJob, artists []string, genres []string) string {
+	for _, a := range artists {
+		if containsFold(conf.DenyArtists, a) {
+			return fmt.Sprintf("artist %q denied", a)
+		}
+	}
+	if len(conf.AllowArtists) > 0 {
+		allowed := false
+		for _, a := range artists {
+			allowed = allowed || containsFold(conf.AllowArtists, a)
+		}
+		if !allowed {
+			return fmt.Sprintf("artists %q not allowed", artists)
+		}
+	}
+
+	for _, g := range genres {
+		if matchesGenre(conf.DenyGenres, g) {
+			return fmt.Sprintf("genre %q denied", g)
+		}
+	}
+	if len(conf.AllowGenres) > 0 {
+		allowed := false
+		for _, g := range genres {
+			allowed = allowed || matchesGenre(conf.AllowGenres, g)
+		}
+		if !allowed {
+			return fmt.Sprintf("genres %q not allowed", genres)
+		}
+	}
+	return ""
+}
+
+// Returns the reason why the track should not be in the playlist, genres are requested only when needed.
+func (s *spotifySaver) filterReason(ctx context.Context, conf SaverJob, track *spotify.ImmutableSpotifyTrack) (string, error) {
+	if track == nil || !conf.hasArtistFilter() {
+		return "", nil
+	}
+
+	genres := make([]string, 0)
+	if conf.hasGenreFilter() {
+		artistGenres, err := s.spotify.ArtistGenres(ctx, track.ArtistIds())
+		if err != nil {
+			return "", err
+		}
+		for _, id := range track.ArtistIds() {
+			genres = append(genres, artistGenres[id]...)
+		}
+	}
+	return artistFilterReason(conf, track.Artists(), genres), nil
+}
+
+// Removes the songs that are not allowed by the artist and genre filters.
+func (s *spotifySaver) removeFilteredArtists(ctx context.Context, conf SaverJob) (int, error) {
+	if !conf.hasArtistFilter() {
+		return 0, nil
+	}
+
+	tracks, err := s.spotify.ListPlaylist(ctx, conf.Playlist)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, t := range tracks {
+		reason, err := s.filterReason(ctx, conf, t)
+		if err != nil {
+			return 0, err
+		}
+		if len(reason) > 0 {
+			glog.V(1).Infof("[%v] Removing filtered song (%v): %#v", conf.Playlist, reason, t)
+			err = s.spotify.RemoveFromPlaylist(ctx, conf.Playlist, t)
+			if err != nil {
+				return 0, fmt.Errorf("error while removing: %q when removing filtered song %#v", err, t)
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Genre matches when it contains any of the strings, e.g. "rock" matches "classic rock".
+func matchesGenre(list []string, genre string) bool {
+	genre = strings.ToLower(genre)
+	for _, l := range list {
+		if strings.Contains(genre, strings.ToLower(l)) {
+			return true
+		}
+	}
+	return false
+}
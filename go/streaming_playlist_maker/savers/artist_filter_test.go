@@ -0,0 +1,44 @@
+package savers
+
+import (
+	"testing"
+)
+
+func TestArtistFilterReason(t *testing.T) {
+	for _, test := range []struct {
+		conf    SaverJob
+		artists []string
+		genres  []string
+		want    bool
+	}{
+		{SaverJob{}, []string{"Artist"}, nil, false},
+		{SaverJob{DenyArtists: []string{"artist"}}, []string{"Other", "Artist"}, nil, true},
+		{SaverJob{DenyArtists: []string{"artist"}}, []string{"Artist 2"}, nil, false},
+		{SaverJob{AllowArtists: []string{"ARTIST"}}, []string{"Other", "Artist"}, nil, false},
+		{SaverJob{AllowArtists: []string{"ARTIST"}}, []string{"Other"}, nil, true},
+		{SaverJob{DenyGenres: []string{"polka"}}, []string{"A"}, []string{"pop", "polish polka"}, true},
+		{SaverJob{DenyGenres: []string{"polka"}}, []string{"A"}, []string{"pop"}, false},
+		{SaverJob{AllowGenres: []string{"rock"}}, []string{"A"}, []string{"pop", "Classic Rock"}, false},
+		{SaverJob{AllowGenres: []string{"rock"}}, []string{"A"}, []string{"pop"}, true},
+		// Artists without genres are not allowed when AllowGenres is set.
+		{SaverJob{AllowGenres: []string{"rock"}}, []string{"A"}, nil, true},
+		{SaverJob{AllowGenres: []string{"rock"}, DenyGenres: []string{"soft rock"}}, []string{"A"}, []string{"soft rock"}, true},
+	} {
+		got := artistFilterReason(test.conf, test.artists, test.genres)
+		if (len(got) > 0) != test.want {
+			t.Errorf("%+v %v %v got: %q, want filtered: %v", test.conf, test.artists, test.genres, got, test.want)
+		}
+	}
+}
+
+func TestHasArtistFilter(t *testing.T) {
+	if (SaverJob{Playlist: "p"}).hasArtistFilter() {
+		t.Errorf("empty filter got: true, want: false")
+	}
+	if !(SaverJob{AllowGenres: []string{"rock"}}).hasArtistFilter() {
+		t.Errorf("genre filter got: false, want: true")
+	}
+	if (SaverJob{DenyArtists: []string{"a"}}).hasGenreFilter() {
+		t.Errorf("artist filter should not request genres")
+	}
+}
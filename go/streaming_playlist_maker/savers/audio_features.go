@@ -0,0 +1,103 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+)
+
+// Allowed ranges of the Spotify audio features, zero Min or Max is not checked.
+type AudioFeatureRanges struct {
+	// Beats per minute.
+	Tempo Range
+	// Values from 0 to 1.
+	Energy       Range
+	Danceability Range
+	Valence      Range
+	DurationSec  Range
+}
+
+type Range struct {
+	Min float64
+	Max float64
+}
+
+func (r Range) isSet() bool {
+	return r.Min != 0 || r.Max != 0
+}
+
+func (r Range) contains(v float64) bool {
+	return v >= r.Min && (r.Max == 0 || v <= r.Max)
+}
+
+func (a AudioFeatureRanges) isSet() bool {
+	return a.Tempo.isSet() || a.Energy.isSet() || a.Danceability.isSet() || a.Valence.isSet() || a.DurationSec.isSet()
+}
+
+// Returns the reason why the track with the features is not suitable or empty string.
+// Tracks without features (nil) are not suitable.
+func (a AudioFeatureRanges) unsuitableReason(f *spotify.AudioFeatures) string {
+	if f == nil {
+		return "audio features not available"
+	}
+	for _, check := range []struct {
+		name  string
+		r     Range
+		value float64
+	}{
+		{"tempo", a.Tempo, f.Tempo},
+		{"energy", a.Energy, f.Energy},
+		{"danceability", a.Danceability, f.Danceability},
+		{"valence", a.Valence, f.Valence},
+		{"duration", a.DurationSec, float64(f.DurationMs) / 1000},
+	} {
+		if check.r.isSet() && !check.r.contains(check.value) {
+			return fmt.Sprintf("%v %.2f not in %v-%v", check.name, check.value, check.r.Min, check.r.Max)
+		}
+	}
+	return ""
+}
+
+// Returns the track or the first of the candidates which is suitable for the playlist. When none is
+// suitable nil and the reason why the track is not suitable are returned. Features of all the candidates
+// are requested in one batch. When the features can not be requested the track is not suitable.
+func (s *spotifySaver) suitableTrack(ctx context.Context, conf SaverJob, track *spotify.ImmutableSpotifyTrack, candidates []*spotify.ImmutableSpotifyTrack) (*spotify.ImmutableSpotifyTrack, string) {
+	if track == nil || !conf.AudioFeatures.isSet() {
+		return track, ""
+	}
+
+	ids := []string{track.Id()}
+	for _, c := range candidates {
+		ids = append(ids, c.Id())
+	}
+	features, err := s.spotify.AudioFeatures(ctx, ids)
+	if err != nil {
+		glog.Warningf("Could not get audio features of %q: %v", track, err)
+		return nil, fmt.Sprintf("audio features not available: %v", err)
+	}
+	return conf.AudioFeatures.suitableTrack(track, candidates, features)
+}
+
+// Returns the track or the first of the candidates with suitable features, or nil and the reason why
+// the track is not suitable.
+func (a AudioFeatureRanges) suitableTrack(track *spotify.ImmutableSpotifyTrack, candidates []*spotify.ImmutableSpotifyTrack, features map[string]spotify.AudioFeatures) (*spotify.ImmutableSpotifyTrack, string) {
+	reason := a.unsuitableReason(trackFeatures(track, features))
+	if len(reason) == 0 {
+		return track, ""
+	}
+	for _, c := range candidates {
+		if c.Id() != track.Id() && len(a.unsuitableReason(trackFeatures(c, features))) == 0 {
+			return c, ""
+		}
+	}
+	return nil, reason
+}
+
+func trackFeatures(track *spotify.ImmutableSpotifyTrack, features map[string]spotify.AudioFeatures) *spotify.AudioFeatures {
+	f, ok := features[track.Id()]
+	if !ok {
+		return nil
+	}
+	return &f
+}
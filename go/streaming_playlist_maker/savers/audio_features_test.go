@@ -0,0 +1,64 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"testing"
+)
+
+func TestUnsuitableReason(t *testing.T) {
+	running := AudioFeatureRanges{
+		Tempo:       Range{Min: 150, Max: 190},
+		Energy:      Range{Min: 0.6},
+		DurationSec: Range{Max: 360},
+	}
+	for _, test := range []struct {
+		ranges   AudioFeatureRanges
+		features *spotify.AudioFeatures
+		want     bool
+	}{
+		{running, &spotify.AudioFeatures{Tempo: 170, Energy: 0.8, DurationMs: 200000}, false},
+		{running, &spotify.AudioFeatures{Tempo: 150, Energy: 0.6, DurationMs: 360000}, false},
+		{running, &spotify.AudioFeatures{Tempo: 120, Energy: 0.8, DurationMs: 200000}, true},
+		{running, &spotify.AudioFeatures{Tempo: 170, Energy: 0.5, DurationMs: 200000}, true},
+		{running, &spotify.AudioFeatures{Tempo: 170, Energy: 0.8, DurationMs: 400000}, true},
+		{running, nil, true},
+		{AudioFeatureRanges{Valence: Range{Max: 0.3}}, &spotify.AudioFeatures{Valence: 0.9}, true},
+		{AudioFeatureRanges{Danceability: Range{Min: 0.5}}, &spotify.AudioFeatures{Danceability: 0.7}, false},
+	} {
+		got := test.ranges.unsuitableReason(test.features)
+		if (len(got) > 0) != test.want {
+			t.Errorf("%+v %+v got: %q, want unsuitable: %v", test.ranges, test.features, got, test.want)
+		}
+	}
+
+	if (AudioFeatureRanges{}).isSet() || !running.isSet() {
+		t.Errorf("isSet got: %v %v, want: false true", (AudioFeatureRanges{}).isSet(), running.isSet())
+	}
+}
+
+func TestSuitableTrack(t *testing.T) {
+	ranges := AudioFeatureRanges{Tempo: Range{Min: 150}}
+	slow := spotify.NewImmutableSpotifyTrack("slow", "Artist", "Song")
+	fast := spotify.NewImmutableSpotifyTrack("fast", "Artist", "Song (Remix)")
+	unknown := spotify.NewImmutableSpotifyTrack("unknown", "Artist", "Song (Live)")
+	features := map[string]spotify.AudioFeatures{
+		"slow": {Id: "slow", Tempo: 100},
+		"fast": {Id: "fast", Tempo: 170},
+	}
+
+	for _, test := range []struct {
+		track      *spotify.ImmutableSpotifyTrack
+		candidates []*spotify.ImmutableSpotifyTrack
+		want       *spotify.ImmutableSpotifyTrack
+	}{
+		{fast, nil, fast},
+		{slow, nil, nil},
+		{slow, []*spotify.ImmutableSpotifyTrack{slow, unknown, fast}, fast},
+		{unknown, []*spotify.ImmutableSpotifyTrack{unknown, slow}, nil},
+	} {
+		got, reason := ranges.suitableTrack(test.track, test.candidates, features)
+		if got != test.want || (got == nil) != (len(reason) > 0) {
+			t.Errorf("suitableTrack(%v, %v) got: %v %q, want: %v", test.track, test.candidates, got, reason, test.want)
+		}
+	}
+}
@@ -9,9 +9,38 @@ import (
 )
 
 type SaverJob struct {
-	Playlist           string
-	SaverType          string
+	Playlist  string
+	SaverType string
+	// Used only when SeasonalRules are empty: if false Christmas songs are never added.
 	AllowChristmasSong bool
+	// Songs that are allowed only in the date windows.
+	SeasonalRules []SeasonalRule
+	// Songs by the denied artists (case insensitive) are not added and they are removed during cleaning.
+	// When AllowArtists is set, songs need at least one of the allowed artists.
+	AllowArtists []string
+	DenyArtists  []string
+	// Same as above for the Spotify genres of the artists, genre matches when it contains the string
+	// (e.g. "rock" matches "classic rock").
+	AllowGenres []string
+	DenyGenres  []string
+	// Songs with the Spotify audio features outside of the ranges are not added.
+	AudioFeatures AudioFeatureRanges
+	// Songs with the match quality in the range [ReviewMin, ReviewMax) are not added but put into the
+	// review queue. Review is disabled when ReviewMax is 0.
+	ReviewMin int
+	ReviewMax int
+	// Rules used to decide which of the similar songs is removed during cleaning, applied in order
+	// until one decides: "available", "non-remix", "popular", "earlier". Empty only reports them.
+	SimilarResolution []string
+	// If true the similar songs are only reported, even if the rules decide which to remove.
+	SimilarDryRun bool
+	// Pairs of "Artist - Title" that are known not to be duplicates.
+	NotSimilar [][2]string
+	// Output of the stdout saver: "text" (default), "json" (one object per line) or "csv".
+	StdoutFormat string
+	// If true the stdout saver searches the songs in Spotify and prints the best match, nothing is
+	// added to Spotify.
+	StdoutMatch bool
 }
 
 type Status struct {
@@ -21,8 +50,18 @@ type Status struct {
 	SongExists bool
 	// Title of the song found by the saver, empty if not found.
 	FoundTitle string
+	// Link to the song found by the saver, empty if the saver has no links.
+	FoundUrl string
 	// Match quality 0-100
 	MatchQuality int
+	// True if song was found but not added because of the artist or genre filters.
+	SongFiltered bool
+	// True if song was found but not added because of the seasonal rules, it can be added in its season.
+	SongSeasonal bool
+	// True if song was found but not added because its audio features are outside of the ranges.
+	SongUnsuitable bool
+	// True if song was not added because it waits for the manual review.
+	SongQueued bool
 }
 
 type CleanStatus struct {
@@ -32,16 +71,25 @@ type CleanStatus struct {
 	UnavailableReplaced int
 	// Number of duplicates that were removed
 	Duplicates int
-	// List of possible duplicates that were not removed
+	// List of similar songs, including the resolved ones
 	Similar []*SimilarTrack
+	// Number of similar songs removed by the resolution policy
+	SimilarRemoved int
 	// Number of terrible song names that were removed
 	Terrible int
+	// Number of seasonal songs that were removed after their season
+	Seasonal int
+	// Number of songs removed by the artist and genre filters
+	Filtered int
 }
 
 type SimilarTrack struct {
 	Title1        string
 	Title2        string
 	AvgMatchRatio int
+	// Title of the song removed by the resolution rule (or that would be removed in the dry run), empty if not resolved.
+	Removed   string
+	RemovedBy string
 }
 
 type SongSaver interface {
@@ -52,6 +100,16 @@ type SongSaver interface {
 	Save(ctx context.Context, conf SaverJob, artistTitle string) (*Status, error)
 }
 
+// Implemented by the savers which can find the song by its ISRC, set by the sources or the enrichment.
+type IsrcSaver interface {
+	SaveIsrc(ctx context.Context, conf SaverJob, artistTitle string, isrc string) (*Status, error)
+}
+
+// Implemented by the savers keeping the caches in memory, they are saved at the end of the run.
+type CacheSaver interface {
+	SaveCache() error
+}
+
 func Create(ctx context.Context, saverType string) (SongSaver, error) {
 	glog.V(3).Infof("Creating %v saver", saverType)
 	switch saverType {
@@ -59,6 +117,10 @@ func Create(ctx context.Context, saverType string) (SongSaver, error) {
 		return newSpotify(ctx)
 	case "stdout":
 		return newStdout()
+	case "subsonic":
+		return newSubsonic()
+	case "local":
+		return newLocal()
 	default:
 		return nil, fmt.Errorf("Invalid saver type definition (%v).", saverType)
 	}
@@ -74,6 +136,12 @@ func (c *CleanStatus) String() string {
 	buf.WriteString(strconv.Itoa(c.Duplicates))
 	buf.WriteString("\nRemoved terrible:   ")
 	buf.WriteString(strconv.Itoa(c.Terrible))
+	buf.WriteString("\nRemoved seasonal:   ")
+	buf.WriteString(strconv.Itoa(c.Seasonal))
+	buf.WriteString("\nRemoved filtered:   ")
+	buf.WriteString(strconv.Itoa(c.Filtered))
+	buf.WriteString("\nRemoved similar:   ")
+	buf.WriteString(strconv.Itoa(c.SimilarRemoved))
 	for _, s := range c.Similar {
 		buf.WriteString("\n")
 		buf.WriteString(strconv.Itoa(s.AvgMatchRatio))
@@ -81,6 +149,13 @@ func (c *CleanStatus) String() string {
 		buf.WriteString(s.Title1)
 		buf.WriteString(" = ")
 		buf.WriteString(s.Title2)
+		if len(s.Removed) > 0 {
+			buf.WriteString(" -> remove ")
+			buf.WriteString(s.Removed)
+			buf.WriteString(" (")
+			buf.WriteString(s.RemovedBy)
+			buf.WriteString(")")
+		}
 	}
 	return buf.String()
 }
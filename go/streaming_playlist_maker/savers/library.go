@@ -0,0 +1,130 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/matcher"
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+)
+
+// Music library of the saver (e.g. Subsonic server or local files) used by saveToLibrary.
+type songLibrary interface {
+	// Returns the songs of the playlist.
+	playlistSongs(ctx context.Context, playlist string) ([]librarySong, error)
+	// Returns the songs of the library which can match the artist and title.
+	searchSongs(ctx context.Context, artistTitle string) ([]librarySong, error)
+	// Adds the song returned by searchSongs to the playlist.
+	addSong(ctx context.Context, playlist string, song librarySong) error
+}
+
+type librarySong struct {
+	Artist string
+	Title  string
+	// Empty if the library does not know the genre.
+	Genre string
+	// Library specific song, e.g. the Subsonic song id.
+	Item interface{}
+}
+
+func (s librarySong) String() string {
+	return s.Artist + " - " + s.Title
+}
+
+// Saves the song to the playlist of the library: songs in the not found cache and songs already in the
+// playlist are skipped, otherwise the best match from the library is added. Seasonal rules and artist
+// and genre filters are used, Spotify specific options are ignored.
+func saveToLibrary(ctx context.Context, conf SaverJob, artistTitle string, library songLibrary, notFound *nfCache) (*Status, error) {
+	glog.V(2).Infof("Saving song: %v", artistTitle)
+
+	if len(artistTitle) == 0 {
+		return nil, fmt.Errorf("Empty song title")
+	}
+
+	rules, err := conf.seasonalRules()
+	if err != nil {
+		return nil, err
+	}
+
+	// First check if the song is in not found cache
+	cachedStatus := notFound.IsNotFound(artistTitle)
+	if cachedStatus != nil {
+		return cachedStatus, nil
+	}
+
+	// Then check if the song is already in playlist
+	existingSongs, err := library.playlistSongs(ctx, conf.Playlist)
+	if err != nil {
+		return nil, err
+	}
+	existingSong, existingSongMatch := bestLibraryMatch(existingSongs, artistTitle)
+	if existingSongMatch >= validMatch {
+		return &Status{
+			FoundTitle:   existingSong.String(),
+			MatchQuality: existingSongMatch,
+			SongExists:   true,
+		}, nil
+	}
+
+	newSongs, err := library.searchSongs(ctx, artistTitle)
+	if err != nil {
+		return nil, err
+	}
+	newSong, newSongMatch := bestLibraryMatch(newSongs, artistTitle)
+
+	if newSongMatch >= validMatch {
+		if status := seasonalStatus(rules, artistTitle, newSong.String(), newSongMatch); status != nil {
+			return status, nil
+		}
+		if reason := artistFilterReason(conf, []string{newSong.Artist}, genreList(newSong.Genre)); len(reason) > 0 {
+			glog.V(1).Infof("Ignoring filtered song (%v): %q", reason, artistTitle)
+			return &Status{
+				FoundTitle:   newSong.String(),
+				MatchQuality: newSongMatch,
+				SongFiltered: true,
+			}, nil
+		}
+
+		err = library.addSong(ctx, conf.Playlist, *newSong)
+		if err != nil {
+			return nil, err
+		}
+		return &Status{
+			FoundTitle:   newSong.String(),
+			MatchQuality: newSongMatch,
+			SongAdded:    true,
+		}, nil
+	}
+
+	status := &Status{
+		MatchQuality: newSongMatch,
+	}
+	if newSong != nil {
+		status.FoundTitle = newSong.String()
+	}
+	notFound.AddNotFound(artistTitle, status)
+	return status, nil
+}
+
+func bestLibraryMatch(songs []librarySong, artistTitle string) (*librarySong, int) {
+	bestMatch := -1
+	var best *librarySong
+	for i := range songs {
+		match := matcher.MatchRatio(artistTitle, matcher.NewTrack(songs[i].Artist, songs[i].Title))
+		if match > bestMatch {
+			bestMatch = match
+			best = &songs[i]
+		}
+		if bestMatch == 100 {
+			break
+		}
+	}
+	return best, bestMatch
+}
+
+// Returns the genre as the list used by the genre filters.
+func genreList(genre string) []string {
+	if len(genre) == 0 {
+		return nil
+	}
+	return []string{genre}
+}
@@ -0,0 +1,65 @@
+package savers
+
+import (
+	"context"
+	"testing"
+)
+
+type fakeLibrary struct {
+	songs    []librarySong
+	playlist []librarySong
+	searches int
+}
+
+func (f *fakeLibrary) playlistSongs(ctx context.Context, playlist string) ([]librarySong, error) {
+	return f.playlist, nil
+}
+
+func (f *fakeLibrary) searchSongs(ctx context.Context, artistTitle string) ([]librarySong, error) {
+	f.searches++
+	return f.songs, nil
+}
+
+func (f *fakeLibrary) addSong(ctx context.Context, playlist string, song librarySong) error {
+	f.playlist = append(f.playlist, song)
+	return nil
+}
+
+func TestSaveToLibrary(t *testing.T) {
+	ctx := context.Background()
+	library := &fakeLibrary{songs: []librarySong{
+		{Artist: "Artist", Title: "Song", Item: 1},
+		{Artist: "Band", Title: "Dance", Genre: "Polka", Item: 2},
+		{Artist: "Singer", Title: "White Christmas", Item: 3},
+	}}
+	notFound := newCache()
+	conf := SaverJob{Playlist: "p", DenyGenres: []string{"polka"}}
+
+	for _, test := range []struct {
+		artistTitle string
+		want        Status
+		searches    int
+	}{
+		{"Artist - Song", Status{SongAdded: true, FoundTitle: "Artist - Song", MatchQuality: 100}, 1},
+		{"Artist - Song", Status{SongExists: true, FoundTitle: "Artist - Song", MatchQuality: 100}, 1},
+		{"Band - Dance", Status{SongFiltered: true, FoundTitle: "Band - Dance", MatchQuality: 100}, 2},
+		{"Singer - White Christmas", Status{SongSeasonal: true, FoundTitle: "Singer - White Christmas", MatchQuality: 100}, 3},
+		// Seasonal songs are not cached.
+		{"Singer - White Christmas", Status{SongSeasonal: true, FoundTitle: "Singer - White Christmas", MatchQuality: 100}, 4},
+		{"Nobody - Nothing", Status{FoundTitle: "Artist - Song", MatchQuality: 0}, 5},
+		// Not found songs are cached.
+		{"Nobody - Nothing", Status{FoundTitle: "Artist - Song", MatchQuality: 0}, 5},
+	} {
+		got, err := saveToLibrary(ctx, conf, test.artistTitle, library, notFound)
+		if err != nil || *got != test.want || library.searches != test.searches {
+			t.Errorf("saveToLibrary(%q) got: %+v %v (%d searches), want: %+v (%d searches)", test.artistTitle, got, err, library.searches, test.want, test.searches)
+		}
+	}
+
+	if len(library.playlist) != 1 || library.playlist[0].Item != 1 {
+		t.Errorf("Playlist got: %+v, want only song 1", library.playlist)
+	}
+	if _, err := saveToLibrary(ctx, conf, "", library, notFound); err == nil {
+		t.Errorf("saveToLibrary() with empty title got no error")
+	}
+}
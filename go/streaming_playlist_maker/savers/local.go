@@ -0,0 +1,382 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/lib/flac"
+	"birnenlabs.com/go/lib/id3"
+	"birnenlabs.com/go/lib/matcher"
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Music directory is indexed again after this time, so the new files are found without restarting.
+const localIndexMaxAge = time.Hour
+
+// Configuration of the local saver stored in "local-library" json.
+type localConfig struct {
+	// Directory with the mp3 and flac files.
+	MusicDir string
+	// Directory of the m3u8 playlists, MusicDir is used if empty.
+	PlaylistDir string
+}
+
+type localSong struct {
+	Path     string
+	Artist   string
+	Title    string
+	Genre    string
+	LengthMs int64
+}
+
+// Saver maintaining the "<SaverJob.Playlist>.m3u8" playlists of the songs from the music directory.
+type localSaver struct {
+	config localConfig
+	// Guards the index and the playlist files.
+	lock     sync.Mutex
+	index    []localSong
+	indexed  time.Time
+	notFound *nfCache
+}
+
+func newLocal() (SongSaver, error) {
+	var config localConfig
+	err := conf.LoadConfigFromJson("local-library", &config)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.MusicDir) == 0 {
+		return nil, fmt.Errorf("MusicDir is not set in local-library config")
+	}
+	return newLocalWithConfig(config), nil
+}
+
+func newLocalWithConfig(config localConfig) *localSaver {
+	if len(config.PlaylistDir) == 0 {
+		config.PlaylistDir = config.MusicDir
+	}
+	return &localSaver{
+		config:   config,
+		notFound: newCache(),
+	}
+}
+
+func (s localSong) String() string {
+	return s.Artist + " - " + s.Title
+}
+
+func (s *localSaver) Clean(ctx context.Context, conf SaverJob) (*CleanStatus, error) {
+	rules, err := conf.seasonalRules()
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Always index during cleaning to find the moved files.
+	s.indexed = time.Time{}
+	library, err := s.songs()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.readPlaylist(conf.Playlist)
+	if err != nil {
+		return nil, err
+	}
+	entries = withTags(entries, library)
+
+	librarySongs := localLibrarySongs(library)
+	status := &CleanStatus{}
+	result := make([]localSong, 0, len(entries))
+	paths := make(map[string]bool)
+	now := time.Now()
+	for _, e := range entries {
+		if _, err := os.Stat(e.Path); err != nil {
+			// File could be moved or renamed.
+			replacement, match := bestLibraryMatch(librarySongs, e.String())
+			if match < validMatch {
+				glog.V(1).Infof("[%v] Removing missing file: %v", conf.Playlist, e.Path)
+				status.Unavailable++
+				continue
+			}
+			moved := replacement.Item.(localSong)
+			glog.V(1).Infof("[%v] Replacing missing file: %v -> %v", conf.Playlist, e.Path, moved.Path)
+			status.UnavailableReplaced++
+			e = moved
+		}
+
+		if paths[e.Path] {
+			glog.V(1).Infof("[%v] Removing duplicate: %v", conf.Playlist, e.Path)
+			status.Duplicates++
+		} else if matcher.IsTerrible(e.String()) {
+			glog.V(1).Infof("[%v] Removing terrible song: %q", conf.Playlist, e)
+			status.Terrible++
+		} else if rule := expiredRule(rules, strings.ToLower(e.String()), now); rule != nil {
+			glog.V(1).Infof("[%v] Removing %v song after its season: %q", conf.Playlist, rule.Name, e)
+			status.Seasonal++
+		} else if reason := artistFilterReason(conf, []string{e.Artist}, genreList(e.Genre)); len(reason) > 0 {
+			glog.V(1).Infof("[%v] Removing filtered song (%v): %q", conf.Playlist, reason, e)
+			status.Filtered++
+		} else {
+			paths[e.Path] = true
+			result = append(result, e)
+		}
+	}
+
+	if len(result) != len(entries) || status.UnavailableReplaced > 0 {
+		err = s.writePlaylist(conf.Playlist, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+func (s *localSaver) Save(ctx context.Context, conf SaverJob, artistTitle string) (*Status, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Indexing can clear the not found cache, so it is done first.
+	library, err := s.songs()
+	if err != nil {
+		return nil, err
+	}
+	return saveToLibrary(ctx, conf, artistTitle, &localLibrary{s, library}, s.notFound)
+}
+
+// Indexed songs of the local saver, used while its lock is held.
+type localLibrary struct {
+	s       *localSaver
+	library []localSong
+}
+
+func (l *localLibrary) playlistSongs(ctx context.Context, playlist string) ([]librarySong, error) {
+	entries, err := l.s.readPlaylist(playlist)
+	if err != nil {
+		return nil, err
+	}
+	return localLibrarySongs(withTags(entries, l.library)), nil
+}
+
+func (l *localLibrary) searchSongs(ctx context.Context, artistTitle string) ([]librarySong, error) {
+	return localLibrarySongs(l.library), nil
+}
+
+func (l *localLibrary) addSong(ctx context.Context, playlist string, song librarySong) error {
+	entries, err := l.s.readPlaylist(playlist)
+	if err != nil {
+		return err
+	}
+	return l.s.writePlaylist(playlist, append(withTags(entries, l.library), song.Item.(localSong)))
+}
+
+// Library songs with the local songs.
+func localLibrarySongs(songs []localSong) []librarySong {
+	result := make([]librarySong, len(songs))
+	for i, song := range songs {
+		result[i] = librarySong{
+			Artist: song.Artist,
+			Title:  song.Title,
+			Genre:  song.Genre,
+			Item:   song,
+		}
+	}
+	return result
+}
+
+// Returns the indexed songs, indexing the music directory if needed. Lock must be held.
+func (s *localSaver) songs() ([]localSong, error) {
+	if time.Since(s.indexed) < localIndexMaxAge {
+		return s.index, nil
+	}
+
+	start := time.Now()
+	index, err := indexLibrary(s.config.MusicDir)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("Indexed %d songs in %v after %v", len(index), s.config.MusicDir, time.Since(start))
+	s.index = index
+	s.indexed = time.Now()
+	// New files can match the songs that were not found before.
+	s.notFound = newCache()
+	return s.index, nil
+}
+
+func indexLibrary(dir string) ([]localSong, error) {
+	result := make([]localSong, 0)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		song, ok := readLocalSong(path)
+		if ok {
+			result = append(result, song)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Reads the tags of the mp3 or flac file, "Artist - Title.mp3" file name is used if there are no tags.
+func readLocalSong(path string) (localSong, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".mp3" && ext != ".flac" {
+		return localSong{}, false
+	}
+
+	song := localSong{Path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		glog.Warningf("Could not open %v: %v", path, err)
+		return localSong{}, false
+	}
+	defer f.Close()
+
+	if ext == ".flac" {
+		tag, err := flac.Parse(bufio.NewReader(f))
+		if err == nil {
+			song.Artist, song.Title, song.Genre, song.LengthMs = tag.Artist(), tag.Title(), tag.Genre(), tag.LengthMs
+		} else {
+			glog.V(1).Infof("Could not read FLAC tags of %v: %v", path, err)
+		}
+	} else {
+		tag, err := readId3(f)
+		if err == nil {
+			song.Artist, song.Title, song.LengthMs = tag.Artist(), tag.Title(), tag.LengthMs()
+			song.Genre = tag.Text("TCON")
+			if tag.Version == 2 {
+				song.Genre = tag.Text("TCO")
+			}
+		} else {
+			glog.V(1).Infof("Could not read ID3 tags of %v: %v", path, err)
+		}
+	}
+
+	if len(song.Artist) == 0 || len(song.Title) == 0 {
+		artistTitle := strings.SplitN(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), " - ", 2)
+		if len(artistTitle) != 2 {
+			glog.V(1).Infof("Ignoring file without tags: %v", path)
+			return localSong{}, false
+		}
+		song.Artist, song.Title = artistTitle[0], artistTitle[1]
+	}
+	return song, true
+}
+
+func readId3(r io.Reader) (*id3.Tag, error) {
+	header := make([]byte, 10)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+	size := id3.TagSize(header)
+	if size == 0 {
+		return nil, fmt.Errorf("ID3 header not found")
+	}
+	b := make([]byte, size)
+	copy(b, header)
+	_, err = io.ReadFull(r, b[len(header):])
+	if err != nil {
+		return nil, err
+	}
+	return id3.Parse(b)
+}
+
+// Replaces the playlist entries with the indexed songs, as the tags can be changed since the song was added.
+func withTags(entries []localSong, library []localSong) []localSong {
+	byPath := make(map[string]localSong)
+	for _, song := range library {
+		byPath[song.Path] = song
+	}
+	for i, e := range entries {
+		if song, ok := byPath[e.Path]; ok {
+			entries[i] = song
+		}
+	}
+	return entries
+}
+
+func (s *localSaver) playlistPath(playlist string) string {
+	return filepath.Join(s.config.PlaylistDir, playlist+".m3u8")
+}
+
+// Reads the playlist entries, artist and title are taken from #EXTINF. Missing playlist is empty.
+func (s *localSaver) readPlaylist(playlist string) ([]localSong, error) {
+	f, err := os.Open(s.playlistPath(playlist))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := make([]localSong, 0)
+	var info localSong
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#EXTINF:") {
+			lengthTitle := strings.SplitN(strings.TrimPrefix(line, "#EXTINF:"), ",", 2)
+			info = localSong{}
+			if sec, err := strconv.ParseInt(lengthTitle[0], 10, 64); err == nil && sec > 0 {
+				info.LengthMs = sec * 1000
+			}
+			if len(lengthTitle) == 2 {
+				artistTitle := strings.SplitN(lengthTitle[1], " - ", 2)
+				info.Artist = artistTitle[0]
+				if len(artistTitle) == 2 {
+					info.Title = artistTitle[1]
+				}
+			}
+		} else if len(line) > 0 && !strings.HasPrefix(line, "#") {
+			info.Path = line
+			if !filepath.IsAbs(line) {
+				info.Path = filepath.Join(s.config.PlaylistDir, line)
+			}
+			result = append(result, info)
+			info = localSong{}
+		}
+	}
+	return result, scanner.Err()
+}
+
+// Writes the playlist with the paths relative to the playlist directory.
+func (s *localSaver) writePlaylist(playlist string, songs []localSong) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, song := range songs {
+		path := song.Path
+		if rel, err := filepath.Rel(s.config.PlaylistDir, path); err == nil {
+			path = rel
+		}
+		length := int64(-1)
+		if song.LengthMs > 0 {
+			length = song.LengthMs / 1000
+		}
+		fmt.Fprintf(&b, "#EXTINF:%d,%v\n%v\n", length, song, filepath.ToSlash(path))
+	}
+
+	// Written to the temporary file first, so the players never see the partial playlist.
+	path := s.playlistPath(playlist)
+	err := os.WriteFile(path+".tmp", []byte(b.String()), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
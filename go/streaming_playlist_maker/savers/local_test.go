@@ -0,0 +1,131 @@
+package savers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Creates ID3v2.3 tag with the latin-1 text frames.
+func makeId3(frames map[string]string) []byte {
+	var body []byte
+	for id, text := range frames {
+		size := len(text) + 1
+		body = append(body, id...)
+		body = append(body, byte(size>>24), byte(size>>16), byte(size>>8), byte(size), 0, 0, 0)
+		body = append(body, text...)
+	}
+	size := len(body)
+	header := []byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, body...)
+}
+
+func writeFile(t *testing.T, path string, content []byte) {
+	os.MkdirAll(filepath.Dir(path), 0755)
+	err := os.WriteFile(path, content, 0644)
+	if err != nil {
+		t.Fatalf("Could not write %v: %v", path, err)
+	}
+}
+
+func newTestLocal(t *testing.T) (*localSaver, string) {
+	dir := t.TempDir()
+	writeFile(t, dir+"/music/a/01.mp3", append(makeId3(map[string]string{"TPE1": "Artist", "TIT2": "Song", "TLEN": "185000"}), "audio"...))
+	writeFile(t, dir+"/music/b/Band - Other.mp3", []byte("no tags"))
+	writeFile(t, dir+"/music/c/polka.mp3", makeId3(map[string]string{"TPE1": "Polka Band", "TIT2": "Dance", "TCON": "Polka"}))
+	writeFile(t, dir+"/music/c/notes.txt", []byte("Artist - Song"))
+	writeFile(t, dir+"/music/no tags.mp3", []byte("nothing"))
+	os.Mkdir(dir+"/playlists", 0755)
+	return newLocalWithConfig(localConfig{MusicDir: dir + "/music", PlaylistDir: dir + "/playlists"}), dir
+}
+
+func TestIndexLibrary(t *testing.T) {
+	_, dir := newTestLocal(t)
+	songs, err := indexLibrary(dir + "/music")
+	if err != nil {
+		t.Fatalf("indexLibrary() error: %v", err)
+	}
+	want := map[string]localSong{
+		"Artist - Song":      {Path: dir + "/music/a/01.mp3", Artist: "Artist", Title: "Song", LengthMs: 185000},
+		"Band - Other":       {Path: dir + "/music/b/Band - Other.mp3", Artist: "Band", Title: "Other"},
+		"Polka Band - Dance": {Path: dir + "/music/c/polka.mp3", Artist: "Polka Band", Title: "Dance", Genre: "Polka"},
+	}
+	if len(songs) != len(want) {
+		t.Errorf("indexLibrary() got: %+v, want: %+v", songs, want)
+	}
+	for _, s := range songs {
+		if s != want[s.String()] {
+			t.Errorf("indexLibrary() got: %+v, want: %+v", s, want[s.String()])
+		}
+	}
+}
+
+func TestLocalSave(t *testing.T) {
+	ctx := context.Background()
+	s, dir := newTestLocal(t)
+	conf := SaverJob{Playlist: "radio", DenyGenres: []string{"polka"}}
+
+	for _, test := range []struct {
+		artistTitle string
+		want        Status
+	}{
+		{"Artist - Song", Status{SongAdded: true, FoundTitle: "Artist - Song", MatchQuality: 100}},
+		{"Band - Other", Status{SongAdded: true, FoundTitle: "Band - Other", MatchQuality: 100}},
+		{"artist - song", Status{SongExists: true, FoundTitle: "Artist - Song", MatchQuality: 100}},
+		{"Polka Band - Dance", Status{SongFiltered: true, FoundTitle: "Polka Band - Dance", MatchQuality: 100}},
+		{"Nobody - Nothing", Status{FoundTitle: "Artist - Song", MatchQuality: 0}},
+	} {
+		got, err := s.Save(ctx, conf, test.artistTitle)
+		if err != nil || *got != test.want {
+			t.Errorf("Save(%q) got: %+v %v, want: %+v", test.artistTitle, got, err, test.want)
+		}
+	}
+
+	got, err := os.ReadFile(dir + "/playlists/radio.m3u8")
+	want := "#EXTM3U\n#EXTINF:185,Artist - Song\n../music/a/01.mp3\n#EXTINF:-1,Band - Other\n../music/b/Band - Other.mp3\n"
+	if err != nil || string(got) != want {
+		t.Errorf("Playlist got: %q %v, want: %q", got, err, want)
+	}
+}
+
+func TestLocalClean(t *testing.T) {
+	ctx := context.Background()
+	s, dir := newTestLocal(t)
+	writeFile(t, dir+"/playlists/radio.m3u8", []byte(strings.Join([]string{
+		"#EXTM3U",
+		"#EXTINF:185,Artist - Song",
+		"../music/a/01.mp3",
+		"#EXTINF:185,Artist - Song",
+		dir + "/music/a/01.mp3",
+		// Moved file is replaced
+		"#EXTINF:10,Band - Other",
+		"../music/old/other.mp3",
+		"#EXTINF:10,Gone - Forever",
+		"../music/gone.mp3",
+		"../music/c/polka.mp3",
+	}, "\n")))
+
+	got, err := s.Clean(ctx, SaverJob{Playlist: "radio", DenyArtists: []string{"polka band"}})
+	if err != nil {
+		t.Fatalf("Clean() error: %v", err)
+	}
+	// Polka song without #EXTINF is filtered using its tags.
+	if got.Duplicates != 1 || got.Unavailable != 1 || got.UnavailableReplaced != 1 || got.Filtered != 1 {
+		t.Errorf("Clean() got: %+v", got)
+	}
+
+	playlist, err := s.readPlaylist("radio")
+	if err != nil || len(playlist) != 2 || playlist[1].Path != dir+"/music/b/Band - Other.mp3" {
+		t.Errorf("Playlist after Clean() got: %+v %v", playlist, err)
+	}
+}
+
+func TestLocalMissingPlaylist(t *testing.T) {
+	s, _ := newTestLocal(t)
+	got, err := s.readPlaylist("missing")
+	if err != nil || len(got) != 0 {
+		t.Errorf("readPlaylist() of missing playlist got: %v %v, want empty", got, err)
+	}
+}
@@ -0,0 +1,187 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name of the gob file in the config directory with the manual overrides shared by all the jobs.
+const overridesName = "streaming-playlist-maker-overrides"
+
+// Guards the overrides file and its cache within the process. The file is saved atomically and
+// loaded again when it is modified, so the overrides set by the override command are used by the
+// running jobs (the command should not be used by two processes at the same time).
+var overrideLock sync.Mutex
+
+// Overrides loaded from the file, path and modification time of the file when it was loaded.
+var overrideCache struct {
+	overrides map[string]Override
+	path      string
+	modTime   time.Time
+	size      int64
+}
+
+var trackIdRegexp = regexp.MustCompile(`^(?:spotify:track:|https://open\.spotify\.com/track/)?([0-9A-Za-z]{22})(?:\?.*)?$`)
+
+// Manual match of the radio song, used instead of searching.
+type Override struct {
+	// Spotify track id, empty if the song should never be matched.
+	TrackId string
+	Artist  string
+	Title   string
+	Updated time.Time
+}
+
+func (o Override) String() string {
+	if len(o.TrackId) == 0 {
+		return "never match"
+	}
+	return fmt.Sprintf("%v (%v - %v)", o.TrackId, o.Artist, o.Title)
+}
+
+// Normalizes the "Artist - Title" so the same song from different radios uses the same override.
+func normalizeArtistTitle(artistTitle string) string {
+	return strings.Join(strings.Fields(strings.ToLower(artistTitle)), " ")
+}
+
+// Returns the cached overrides, they are loaded again when the file was modified. Missing file means no
+// overrides, file that can not be decoded is an error so it is never overwritten. Lock must be held and
+// the result must not be modified.
+func loadOverrides() (map[string]Override, error) {
+	path := conf.ConfigFilePath(overridesName)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]Override{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &overrideCache
+	if c.overrides != nil && c.path == path && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.overrides, nil
+	}
+
+	overrides := make(map[string]Override)
+	err = conf.LoadConfigFromFile(overridesName, &overrides)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load overrides: %v", err)
+	}
+	glog.V(1).Infof("Loaded %d overrides.", len(overrides))
+	c.overrides, c.path, c.modTime, c.size = overrides, path, info.ModTime(), info.Size()
+	return overrides, nil
+}
+
+// Loads the overrides, calls f with their copy and saves them.
+func updateOverrides(f func(overrides map[string]Override) error) error {
+	overrideLock.Lock()
+	defer overrideLock.Unlock()
+
+	loaded, err := loadOverrides()
+	if err != nil {
+		return err
+	}
+	overrides := make(map[string]Override, len(loaded))
+	for k, v := range loaded {
+		overrides[k] = v
+	}
+	err = f(overrides)
+	if err != nil {
+		return err
+	}
+	return conf.SaveConfigToFileAtomic(overridesName, overrides)
+}
+
+// Returns all the overrides keyed by the normalized "Artist - Title".
+func Overrides() (map[string]Override, error) {
+	overrideLock.Lock()
+	defer overrideLock.Unlock()
+
+	overrides, err := loadOverrides()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]Override, len(overrides))
+	for k, v := range overrides {
+		result[k] = v
+	}
+	return result, nil
+}
+
+func findOverride(artistTitle string) (*Override, error) {
+	overrideLock.Lock()
+	defer overrideLock.Unlock()
+
+	overrides, err := loadOverrides()
+	if err != nil {
+		return nil, err
+	}
+	o, ok := overrides[normalizeArtistTitle(artistTitle)]
+	if !ok {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+// Sets the override of the song, zero Override means the song is never matched.
+func SetOverride(artistTitle string, o Override) error {
+	if len(normalizeArtistTitle(artistTitle)) == 0 {
+		return fmt.Errorf("Empty song title")
+	}
+	o.Updated = time.Now()
+	return updateOverrides(func(overrides map[string]Override) error {
+		overrides[normalizeArtistTitle(artistTitle)] = o
+		return nil
+	})
+}
+
+func RemoveOverride(artistTitle string) error {
+	return updateOverrides(func(overrides map[string]Override) error {
+		key := normalizeArtistTitle(artistTitle)
+		if _, ok := overrides[key]; !ok {
+			return fmt.Errorf("override of %q not found", artistTitle)
+		}
+		delete(overrides, key)
+		return nil
+	})
+}
+
+// Returns the track id from the id, spotify uri or open.spotify.com link.
+func parseTrackId(s string) (string, error) {
+	m := trackIdRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", fmt.Errorf("invalid spotify track: %q", s)
+	}
+	return m[1], nil
+}
+
+// Creates the override with the track, it is verified using the Spotify API.
+// Track can be the id, spotify uri or open.spotify.com link.
+func NewOverride(ctx context.Context, track string) (Override, error) {
+	id, err := parseTrackId(track)
+	if err != nil {
+		return Override{}, err
+	}
+
+	s, err := NewSpotify(ctx)
+	if err != nil {
+		return Override{}, err
+	}
+	t, err := s.GetTrack(ctx, id)
+	if err != nil {
+		return Override{}, err
+	}
+	return Override{
+		TrackId: t.Id(),
+		Artist:  t.Artist(),
+		Title:   t.Title(),
+	}, nil
+}
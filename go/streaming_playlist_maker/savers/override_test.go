@@ -0,0 +1,107 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestOverrides(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	if o, err := findOverride("Artist - Title"); err != nil || o != nil {
+		t.Errorf("findOverride() on empty table got: %+v, want: nil", o)
+	}
+
+	err := SetOverride("Artist -  Title ", Override{TrackId: "1", Artist: "Artist", Title: "Title"})
+	if err != nil {
+		t.Fatalf("SetOverride() error: %v", err)
+	}
+	err = SetOverride("Jingle - Radio", Override{})
+	if err != nil {
+		t.Fatalf("SetOverride() error: %v", err)
+	}
+	if err = SetOverride("  ", Override{}); err == nil {
+		t.Errorf("SetOverride() with empty title should fail")
+	}
+
+	if o, err := findOverride("ARTIST - TITLE"); err != nil || o == nil || o.TrackId != "1" || o.Updated.IsZero() {
+		t.Errorf("findOverride() got: %+v, want track 1", o)
+	}
+	if o, err := findOverride("jingle - radio"); err != nil || o == nil || o.TrackId != "" || o.String() != "never match" {
+		t.Errorf("findOverride() got: %+v, want never match", o)
+	}
+	if got, err := Overrides(); err != nil || len(got) != 2 {
+		t.Errorf("Overrides() got: %v %v, want: 2 overrides", got, err)
+	}
+
+	if err = RemoveOverride("artist - title"); err != nil {
+		t.Errorf("RemoveOverride() error: %v", err)
+	}
+	if err = RemoveOverride("artist - title"); err == nil {
+		t.Errorf("RemoveOverride() of missing override should fail")
+	}
+	if o, err := findOverride("Artist - Title"); err != nil || o != nil {
+		t.Errorf("findOverride() after remove got: %+v, want: nil", o)
+	}
+}
+
+func TestOverrides_fileChanged(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	if err := SetOverride("Artist - Title", Override{TrackId: "1"}); err != nil {
+		t.Fatalf("SetOverride() error: %v", err)
+	}
+	if o, err := findOverride("Artist - Title"); err != nil || o == nil || o.TrackId != "1" {
+		t.Fatalf("findOverride() got: %+v %v, want track 1", o, err)
+	}
+
+	// File changed by the other process is loaded again.
+	path := conf.ConfigFilePath(overridesName)
+	err := conf.SaveConfigToFile(overridesName, map[string]Override{"artist - title": {TrackId: "2"}})
+	if err != nil {
+		t.Fatalf("SaveConfigToFile() error: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if o, err := findOverride("Artist - Title"); err != nil || o == nil || o.TrackId != "2" {
+		t.Errorf("findOverride() after file change got: %+v %v, want track 2", o, err)
+	}
+
+	// Corrupted file is an error and it is not overwritten.
+	os.WriteFile(path, []byte("not gob"), 0600)
+	os.Chtimes(path, later.Add(time.Minute), later.Add(time.Minute))
+	if _, err := findOverride("Artist - Title"); err == nil {
+		t.Errorf("findOverride() on corrupted file got no error")
+	}
+	if err := SetOverride("Other - Song", Override{}); err == nil {
+		t.Errorf("SetOverride() on corrupted file got no error")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "not gob" {
+		t.Errorf("Corrupted overrides were overwritten: %q", b)
+	}
+}
+
+func TestParseTrackId(t *testing.T) {
+	id := "4uLU6hMCjMI75M1A2tKUQC"
+	for _, test := range []struct {
+		in      string
+		wantErr bool
+	}{
+		{id, false},
+		{"spotify:track:" + id, false},
+		{"https://open.spotify.com/track/" + id + "?si=abc", false},
+		{"https://open.spotify.com/album/" + id, true},
+		{"short", true},
+	} {
+		got, err := parseTrackId(test.in)
+		if (err != nil) != test.wantErr || (err == nil && got != id) {
+			t.Errorf("parseTrackId(%q) got: %q %v, want error: %v", test.in, got, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/lib/spotify"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Name of the gob file in the config directory with the review queue.
+	reviewQueueName = "streaming-playlist-maker-review"
+	// Maximum number of candidates stored for the review.
+	maxReviewCandidates = 5
+)
+
+// Guards the review queue file within the process. The queue is loaded on every use and saved
+// atomically, so the review command always reads a complete queue, but the changes made at the
+// same time by the other process can be lost.
+var reviewLock sync.Mutex
+
+// Low-confidence match waiting for the review.
+type ReviewItem struct {
+	Playlist    string
+	ArtistTitle string
+	// Candidate tracks, best match first.
+	Candidates []ReviewCandidate
+	Added      time.Time
+	// Number of times the song was played while waiting for the review.
+	Seen int
+}
+
+type ReviewCandidate struct {
+	Id           string
+	Artist       string
+	Title        string
+	MatchQuality int
+}
+
+type reviewQueue struct {
+	Items map[string]*ReviewItem
+}
+
+func reviewKey(playlist string, artistTitle string) string {
+	return playlist + "|" + normalizeArtistTitle(artistTitle)
+}
+
+// Missing queue is empty, queue that can not be decoded is an error so it is never overwritten.
+func loadReviewQueue() (*reviewQueue, error) {
+	q := &reviewQueue{}
+	err := conf.LoadConfigFromFile(reviewQueueName, q)
+	if errors.Is(err, fs.ErrNotExist) {
+		glog.V(1).Infof("Review queue not found, starting with empty one.")
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not load review queue: %v", err)
+	}
+	if q.Items == nil {
+		q.Items = make(map[string]*ReviewItem)
+	}
+	return q, nil
+}
+
+// Loads the queue, calls f and saves the queue when f returns true.
+func updateReviewQueue(f func(q *reviewQueue) bool) error {
+	reviewLock.Lock()
+	defer reviewLock.Unlock()
+
+	q, err := loadReviewQueue()
+	if err != nil {
+		return err
+	}
+	if !f(q) {
+		return nil
+	}
+	return conf.SaveConfigToFileAtomic(reviewQueueName, q)
+}
+
+// Returns true if the song waits for the review, the song is counted as seen again.
+func reviewPending(playlist string, artistTitle string) (bool, error) {
+	pending := false
+	err := updateReviewQueue(func(q *reviewQueue) bool {
+		item, ok := q.Items[reviewKey(playlist, artistTitle)]
+		if ok {
+			item.Seen++
+			pending = true
+		}
+		return ok
+	})
+	return pending, err
+}
+
+// Adds the song to the review queue or updates it if it is already waiting.
+func enqueueReview(playlist string, artistTitle string, candidates []ReviewCandidate) error {
+	if len(candidates) > maxReviewCandidates {
+		candidates = candidates[:maxReviewCandidates]
+	}
+	return updateReviewQueue(func(q *reviewQueue) bool {
+		key := reviewKey(playlist, artistTitle)
+		item, ok := q.Items[key]
+		if !ok {
+			item = &ReviewItem{
+				Playlist:    playlist,
+				ArtistTitle: artistTitle,
+				Added:       time.Now(),
+			}
+			q.Items[key] = item
+		}
+		item.Candidates = candidates
+		item.Seen++
+		return true
+	})
+}
+
+// Returns the songs waiting for the review, oldest first.
+func PendingReviews() ([]*ReviewItem, error) {
+	reviewLock.Lock()
+	defer reviewLock.Unlock()
+
+	q, err := loadReviewQueue()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*ReviewItem, 0)
+	for _, item := range q.Items {
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Added.Before(result[j].Added)
+	})
+	return result, nil
+}
+
+// Approves the candidate of the review item, negative candidate rejects the song.
+// The decision is saved as the override, so it is used by all the jobs.
+func DecideReview(item *ReviewItem, candidate int) error {
+	if candidate >= len(item.Candidates) {
+		return fmt.Errorf("invalid candidate %d, there are %d candidates", candidate, len(item.Candidates))
+	}
+
+	override := Override{}
+	if candidate >= 0 {
+		c := item.Candidates[candidate]
+		override.TrackId = c.Id
+		override.Artist = c.Artist
+		override.Title = c.Title
+	}
+	err := SetOverride(item.ArtistTitle, override)
+	if err != nil {
+		return err
+	}
+	// The override is used in all the playlists, so the song is removed from all of them.
+	return updateReviewQueue(func(q *reviewQueue) bool {
+		for key, i := range q.Items {
+			if normalizeArtistTitle(i.ArtistTitle) == normalizeArtistTitle(item.ArtistTitle) {
+				delete(q.Items, key)
+			}
+		}
+		return true
+	})
+}
+
+// Returns the candidates with the match quality at least minMatch, best first.
+func reviewCandidates(tracks []*spotify.ImmutableSpotifyTrack, artistTitle string, minMatch int) []ReviewCandidate {
+	result := make([]ReviewCandidate, 0)
+	for _, t := range tracks {
+		match := spotify.CalculateMatchRatio(artistTitle, t)
+		if match >= minMatch {
+			result = append(result, ReviewCandidate{
+				Id:           t.Id(),
+				Artist:       t.Artist(),
+				Title:        t.Title(),
+				MatchQuality: match,
+			})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].MatchQuality > result[j].MatchQuality
+	})
+	return result
+}
+
+func (c ReviewCandidate) String() string {
+	return c.Artist + " - " + c.Title
+}
+
+// Returns true if the match should be reviewed manually.
+func (c SaverJob) needsReview(match int) bool {
+	return c.ReviewMax > 0 && match >= c.ReviewMin && match < c.ReviewMax
+}
@@ -0,0 +1,109 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"os"
+	"testing"
+)
+
+func TestReviewQueue(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	candidates := []ReviewCandidate{{Id: "1", Artist: "A", Title: "T", MatchQuality: 70}, {Id: "2", Artist: "B", Title: "T", MatchQuality: 60}}
+	for _, song := range []string{"A - T", "a - t", "C - D"} {
+		err := enqueueReview("p", song, candidates)
+		if err != nil {
+			t.Fatalf("enqueueReview(%q) error: %v", song, err)
+		}
+	}
+
+	pending, err := PendingReviews()
+	if err != nil || len(pending) != 2 || pending[0].ArtistTitle != "A - T" || pending[0].Seen != 2 {
+		t.Fatalf("PendingReviews() got: %+v %v, want 2 items with %q seen twice", pending, err, "A - T")
+	}
+	if got, err := reviewPending("p", "A  -  t"); !got || err != nil {
+		t.Errorf("reviewPending() got: %v %v, want: true", got, err)
+	}
+	if got, err := reviewPending("other", "A - T"); got || err != nil {
+		t.Errorf("reviewPending() other playlist got: %v %v, want: false", got, err)
+	}
+	// Plays of the pending song are counted.
+	if pending, _ = PendingReviews(); pending[0].Seen != 3 {
+		t.Errorf("Seen after reviewPending() got: %d, want: 3", pending[0].Seen)
+	}
+	// Decision is used in all the playlists, so it removes the song from all of them.
+	if err := enqueueReview("other", "A - T", candidates); err != nil {
+		t.Fatalf("enqueueReview() error: %v", err)
+	}
+
+	if err := DecideReview(pending[0], 2); err == nil {
+		t.Errorf("DecideReview() with invalid candidate should fail")
+	}
+	if err := DecideReview(pending[0], 1); err != nil {
+		t.Fatalf("DecideReview() error: %v", err)
+	}
+	if err := DecideReview(pending[1], -1); err != nil {
+		t.Fatalf("DecideReview() error: %v", err)
+	}
+
+	if got, err := PendingReviews(); err != nil || len(got) != 0 {
+		t.Errorf("PendingReviews() after decisions got: %+v %v, want empty", got, err)
+	}
+	if o, err := findOverride("A - T"); err != nil || o == nil || o.TrackId != "2" {
+		t.Errorf("approved override got: %+v, want track 2", o)
+	}
+	if o, err := findOverride("C - D"); err != nil || o == nil || o.TrackId != "" {
+		t.Errorf("rejected override got: %+v, want empty track id", o)
+	}
+}
+
+func TestReviewQueue_corrupted(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	path := home + "/.config/" + reviewQueueName + ".gob"
+	os.WriteFile(path, []byte("not gob"), 0600)
+	if _, err := PendingReviews(); err == nil {
+		t.Errorf("PendingReviews() got no error, want: error")
+	}
+	if err := enqueueReview("p", "A - T", nil); err == nil {
+		t.Errorf("enqueueReview() got no error, want: error")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "not gob" {
+		t.Errorf("Corrupted queue was overwritten: %q", b)
+	}
+}
+
+func TestReviewCandidates(t *testing.T) {
+	tracks := []*spotify.ImmutableSpotifyTrack{
+		spotify.NewImmutableSpotifyTrack("1", "Other", "Song"),
+		spotify.NewImmutableSpotifyTrack("2", "Artist", "Title (Live)"),
+		spotify.NewImmutableSpotifyTrack("3", "Artist", "Title"),
+	}
+	got := reviewCandidates(tracks, "Artist - Title", 50)
+	if len(got) != 2 || got[0].Id != "3" || got[1].Id != "2" {
+		t.Errorf("reviewCandidates() got: %+v, want tracks 3 and 2", got)
+	}
+}
+
+func TestNeedsReview(t *testing.T) {
+	conf := SaverJob{ReviewMin: 55, ReviewMax: 85}
+	for _, test := range []struct {
+		conf  SaverJob
+		match int
+		want  bool
+	}{
+		{conf, 54, false},
+		{conf, 55, true},
+		{conf, 75, true},
+		{conf, 85, false},
+		{SaverJob{}, 60, false},
+	} {
+		if got := test.conf.needsReview(test.match); got != test.want {
+			t.Errorf("needsReview(%d) got: %v, want: %v", test.match, got, test.want)
+		}
+	}
+}
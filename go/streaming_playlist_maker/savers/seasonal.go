@@ -0,0 +1,138 @@
+package savers
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"strings"
+	"time"
+)
+
+var christmasSongNames = []string{"christmas", "xmas", "x-mas"}
+
+// Seasonal songs are allowed only in the date window, outside of it they are not added to the playlist
+// and removed from it during cleaning.
+type SeasonalRule struct {
+	// Name used in logs, e.g. "christmas".
+	Name string
+	// Songs containing any of the keywords (case insensitive) are seasonal, Christmas names by default.
+	Keywords []string
+	// Songs are added from Start to End (inclusive), dates in the "01-02" (month-day) format. Window can
+	// wrap around the new year, e.g. "11-15" - "12-26". Songs are never added when Start or End is empty.
+	Start string
+	End   string
+	// Songs are removed from the playlist by Clean after that date (until the next Start), End by default.
+	RemoveAfter string
+}
+
+// Returns seasonal rules of the job. When SeasonalRules are not set and AllowChristmasSong is false
+// Christmas songs are never allowed.
+func (c SaverJob) seasonalRules() ([]SeasonalRule, error) {
+	if len(c.SeasonalRules) == 0 && c.AllowChristmasSong {
+		return nil, nil
+	}
+	return SeasonalRules(c.SeasonalRules)
+}
+
+// Returns the rules with the defaults set, returns error when any of the dates is not valid. When the
+// rules are empty Christmas songs are never allowed.
+func SeasonalRules(rules []SeasonalRule) ([]SeasonalRule, error) {
+	if len(rules) == 0 {
+		return []SeasonalRule{{Name: "christmas", Keywords: christmasSongNames}}, nil
+	}
+
+	result := make([]SeasonalRule, len(rules))
+	for i, r := range rules {
+		if len(r.Keywords) == 0 {
+			r.Keywords = christmasSongNames
+		}
+		if len(r.Name) == 0 {
+			r.Name = strings.Join(r.Keywords, "/")
+		}
+		if len(r.RemoveAfter) == 0 {
+			r.RemoveAfter = r.End
+		}
+		for _, date := range []string{r.Start, r.End, r.RemoveAfter} {
+			if _, err := parseMonthDay(date); err != nil {
+				return nil, fmt.Errorf("seasonal rule %v: %v", r.Name, err)
+			}
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
+// Returns the rule that does not allow adding the song now or nil, rules should be returned by SeasonalRules.
+func NotAllowedRule(rules []SeasonalRule, artistTitle string, now time.Time) *SeasonalRule {
+	for i, r := range rules {
+		if r.matches(artistTitle) && !inWindow(now, r.Start, r.End) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// Returns the status of the found song that is not allowed now or nil. The status should not be cached
+// as not found, the song is added in its season.
+func seasonalStatus(rules []SeasonalRule, artistTitle string, foundTitle string, match int) *Status {
+	rule := NotAllowedRule(rules, foundTitle, time.Now())
+	if rule == nil {
+		return nil
+	}
+	glog.V(1).Infof("Ignoring %v song: %q", rule.Name, artistTitle)
+	return &Status{
+		FoundTitle:   foundTitle,
+		MatchQuality: match,
+		SongSeasonal: true,
+	}
+}
+
+// Returns the rule requiring to remove the song from the playlist now or nil.
+func expiredRule(rules []SeasonalRule, artistTitle string, now time.Time) *SeasonalRule {
+	for i, r := range rules {
+		if r.matches(artistTitle) && !inWindow(now, r.Start, r.RemoveAfter) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func (r *SeasonalRule) matches(artistTitle string) bool {
+	artistTitle = strings.ToLower(artistTitle)
+	for _, k := range r.Keywords {
+		if strings.Contains(artistTitle, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the day of now is in the window, false when start or end is empty.
+func inWindow(now time.Time, start string, end string) bool {
+	s, err := parseMonthDay(start)
+	if err != nil || s == 0 {
+		return false
+	}
+	e, err := parseMonthDay(end)
+	if err != nil || e == 0 {
+		return false
+	}
+
+	day := int(now.Month())*100 + now.Day()
+	if s <= e {
+		return s <= day && day <= e
+	}
+	// Window wraps around the new year.
+	return day >= s || day <= e
+}
+
+// Parses "01-02" into 102, empty string is returned as 0.
+func parseMonthDay(date string) (int, error) {
+	if len(date) == 0 {
+		return 0, nil
+	}
+	t, err := time.Parse("01-02", date)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q, want month-day (e.g. 12-24)", date)
+	}
+	return int(t.Month())*100 + t.Day(), nil
+}
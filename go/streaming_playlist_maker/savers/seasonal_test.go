@@ -0,0 +1,80 @@
+package savers
+
+import (
+	"testing"
+	"time"
+)
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2021, month, day, 12, 0, 0, 0, time.UTC)
+}
+
+func TestSeasonalRules_legacy(t *testing.T) {
+	rules, err := SaverJob{AllowChristmasSong: true}.seasonalRules()
+	if err != nil || len(rules) != 0 {
+		t.Errorf("AllowChristmasSong got: %v %v, want: no rules", rules, err)
+	}
+
+	rules, err = SaverJob{}.seasonalRules()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, now := range []time.Time{date(time.December, 24), date(time.July, 1)} {
+		if NotAllowedRule(rules, "Wham! - Last Christmas", now) == nil {
+			t.Errorf("%v: Christmas song allowed", now)
+		}
+		if expiredRule(rules, "Wham! - Last Christmas", now) == nil {
+			t.Errorf("%v: Christmas song not removed", now)
+		}
+	}
+}
+
+func TestSeasonalRules_windows(t *testing.T) {
+	rules, err := SaverJob{
+		// AllowChristmasSong is ignored when rules are set.
+		AllowChristmasSong: true,
+		SeasonalRules: []SeasonalRule{
+			{Start: "11-15", End: "12-26", RemoveAfter: "01-10"},
+			{Name: "summer", Keywords: []string{"Summer"}, Start: "06-01", End: "08-31"},
+		},
+	}.seasonalRules()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, test := range []struct {
+		title       string
+		now         time.Time
+		wantAllowed bool
+		wantKept    bool
+	}{
+		{"Wham! - Last Christmas", date(time.November, 14), false, false},
+		{"Wham! - Last Christmas", date(time.November, 15), true, true},
+		{"Wham! - Last Christmas", date(time.December, 26), true, true},
+		{"Wham! - Last Christmas", date(time.December, 27), false, true},
+		{"Wham! - Last Christmas", date(time.January, 10), false, true},
+		{"Wham! - Last Christmas", date(time.January, 11), false, false},
+		{"Mungo Jerry - In the Summertime", date(time.July, 1), true, true},
+		{"Mungo Jerry - In the Summertime", date(time.September, 1), false, false},
+		{"Artist - Title", date(time.September, 1), true, true},
+	} {
+		allowed := NotAllowedRule(rules, test.title, test.now) == nil
+		kept := expiredRule(rules, test.title, test.now) == nil
+		if allowed != test.wantAllowed || kept != test.wantKept {
+			t.Errorf("%q %v got: allowed %v kept %v, want: allowed %v kept %v", test.title, test.now.Format("01-02"), allowed, kept, test.wantAllowed, test.wantKept)
+		}
+	}
+}
+
+func TestSeasonalRules_invalid(t *testing.T) {
+	for _, r := range []SeasonalRule{
+		{Start: "12", End: "12-26"},
+		{Start: "12-01", End: "13-01"},
+		{Start: "12-01", End: "12-26", RemoveAfter: "tomorrow"},
+	} {
+		_, err := SaverJob{SeasonalRules: []SeasonalRule{r}}.seasonalRules()
+		if err == nil {
+			t.Errorf("%+v got: nil, want: error", r)
+		}
+	}
+}
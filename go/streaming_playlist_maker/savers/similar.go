@@ -0,0 +1,188 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rules of the similar tracks resolution policy.
+const (
+	// Keeps the track available in the market.
+	KeepAvailable = "available"
+	// Keeps the track which is not a remix.
+	KeepNonRemix = "non-remix"
+	// Keeps the track with higher Spotify popularity.
+	KeepPopular = "popular"
+	// Keeps the track added to the playlist earlier.
+	KeepEarlier = "earlier"
+)
+
+// Words used by more tracks are not used to find the similar tracks candidates, unless the track
+// has only such words. It keeps the comparisons near linear for big playlists.
+const maxTokenTracks = 100
+
+var remixRegexp = regexp.MustCompile(`(?i)\b(remix|rmx|mix|bootleg|rework)\b`)
+
+var tokenRegexp = regexp.MustCompile(`[\p{L}\d]+`)
+
+// Words which do not identify the song, they are not used to find the similar tracks candidates.
+var ignoredTokens = map[string]bool{
+	"edit":       true,
+	"feat":       true,
+	"ft":         true,
+	"live":       true,
+	"mix":        true,
+	"radio":      true,
+	"remastered": true,
+	"remix":      true,
+	"version":    true,
+	"vs":         true,
+}
+
+type similarPair struct {
+	t1      *spotify.ImmutableSpotifyTrack
+	t2      *spotify.ImmutableSpotifyTrack
+	match12 int
+	match21 int
+}
+
+func (c SaverJob) validateSimilarResolution() error {
+	for _, rule := range c.SimilarResolution {
+		switch rule {
+		case KeepAvailable, KeepNonRemix, KeepPopular, KeepEarlier:
+		default:
+			return fmt.Errorf("unknown similar resolution rule: %q", rule)
+		}
+	}
+	return nil
+}
+
+// Returns true if the pair is in the NotSimilar allowlist, order of the pair does not matter.
+func (c SaverJob) notSimilar(title1 string, title2 string) bool {
+	t1 := normalizeArtistTitle(title1)
+	t2 := normalizeArtistTitle(title2)
+	for _, pair := range c.NotSimilar {
+		p1 := normalizeArtistTitle(pair[0])
+		p2 := normalizeArtistTitle(pair[1])
+		if (p1 == t1 && p2 == t2) || (p1 == t2 && p2 == t1) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the track that should be removed and the rule that decided, or nil if none of the rules decides.
+func resolveSimilar(policy []string, t1 *spotify.ImmutableSpotifyTrack, t2 *spotify.ImmutableSpotifyTrack) (*spotify.ImmutableSpotifyTrack, string) {
+	for _, rule := range policy {
+		var keep1, keep2 bool
+		switch rule {
+		case KeepAvailable:
+			keep1, keep2 = t1.AvailableIn(spotifyMarket), t2.AvailableIn(spotifyMarket)
+		case KeepNonRemix:
+			keep1, keep2 = !remixRegexp.MatchString(t1.Title()), !remixRegexp.MatchString(t2.Title())
+		case KeepPopular:
+			keep1, keep2 = t1.Popularity() > t2.Popularity(), t2.Popularity() > t1.Popularity()
+		case KeepEarlier:
+			if !t1.AddedAt().IsZero() && !t2.AddedAt().IsZero() {
+				keep1, keep2 = t1.AddedAt().Before(t2.AddedAt()), t2.AddedAt().Before(t1.AddedAt())
+			}
+		}
+		if keep1 && !keep2 {
+			return t2, rule
+		}
+		if keep2 && !keep1 {
+			return t1, rule
+		}
+	}
+	return nil, ""
+}
+
+// Returns the similar tracks in the playlist order. Only the tracks sharing a word are compared,
+// they are found using the inverted index of the words.
+func findSimilarPairs(conf SaverJob, tracks []*spotify.ImmutableSpotifyTrack) []similarPair {
+	result := make([]similarPair, 0)
+	for _, c := range similarCandidates(tracks) {
+		t1, t2 := tracks[c[0]], tracks[c[1]]
+		if conf.notSimilar(t1.String(), t2.String()) {
+			continue
+		}
+		match12 := spotify.CalculateMatchRatio(t1.String(), t2)
+		match21 := spotify.CalculateMatchRatio(t2.String(), t1)
+		if match12+match21 >= 2*validMatch {
+			result = append(result, similarPair{t1, t2, match12, match21})
+		}
+	}
+	return result
+}
+
+// Returns sorted pairs of the indexes (i < j) of the tracks sharing at least one word.
+func similarCandidates(tracks []*spotify.ImmutableSpotifyTrack) [][2]int {
+	tokens := make([][]string, len(tracks))
+	index := make(map[string][]int)
+	for i, t := range tracks {
+		tokens[i] = trackTokens(t)
+		for _, token := range tokens[i] {
+			index[token] = append(index[token], i)
+		}
+	}
+
+	// Pairs are added from both tracks, as one of them can use the common word when it has only such words.
+	candidates := make(map[[2]int]bool)
+	for i := range tracks {
+		for _, token := range indexedTokens(tokens[i], index) {
+			for _, j := range index[token] {
+				if j < i {
+					candidates[[2]int{j, i}] = true
+				} else if j > i {
+					candidates[[2]int{i, j}] = true
+				}
+			}
+		}
+	}
+
+	result := make([][2]int, 0, len(candidates))
+	for c := range candidates {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a][0] != result[b][0] {
+			return result[a][0] < result[b][0]
+		}
+		return result[a][1] < result[b][1]
+	})
+	return result
+}
+
+// Returns the track tokens that are not too common, or the rarest one if all of them are common.
+func indexedTokens(tokens []string, index map[string][]int) []string {
+	result := make([]string, 0, len(tokens))
+	rarest := ""
+	for _, token := range tokens {
+		if len(index[token]) <= maxTokenTracks {
+			result = append(result, token)
+		}
+		if rarest == "" || len(index[token]) < len(index[rarest]) {
+			rarest = token
+		}
+	}
+	if len(result) == 0 && rarest != "" {
+		result = append(result, rarest)
+	}
+	return result
+}
+
+// Returns unique lower case words of the artist and title, without the ignored ones.
+func trackTokens(t *spotify.ImmutableSpotifyTrack) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, token := range tokenRegexp.FindAllString(strings.ToLower(t.String()), -1) {
+		if !ignoredTokens[token] && !seen[token] {
+			seen[token] = true
+			result = append(result, token)
+		}
+	}
+	return result
+}
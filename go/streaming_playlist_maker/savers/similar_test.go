@@ -0,0 +1,172 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testTrack(id string, title string, popularity int, added int64, available bool) *spotify.ImmutableSpotifyTrack {
+	t := &spotify.SpotifyTrack{
+		Id:         id,
+		Name:       title,
+		Popularity: popularity,
+		Artists:    []spotify.SpotifyArtist{{Name: "Artist"}},
+		AddedAt:    time.Unix(added, 0),
+	}
+	if available {
+		t.AvailableMarkets = []string{spotifyMarket}
+	}
+	return t.Immutable()
+}
+
+func TestResolveSimilar(t *testing.T) {
+	for _, test := range []struct {
+		policy   []string
+		t1       *spotify.ImmutableSpotifyTrack
+		t2       *spotify.ImmutableSpotifyTrack
+		wantId   string
+		wantRule string
+	}{
+		{nil, testTrack("1", "Song", 10, 1, true), testTrack("2", "Song", 20, 2, true), "", ""},
+		{[]string{KeepPopular}, testTrack("1", "Song", 10, 1, true), testTrack("2", "Song", 20, 2, true), "1", KeepPopular},
+		{[]string{KeepEarlier}, testTrack("1", "Song", 10, 1, true), testTrack("2", "Song", 20, 2, true), "2", KeepEarlier},
+		{[]string{KeepAvailable, KeepPopular}, testTrack("1", "Song", 10, 1, true), testTrack("2", "Song", 20, 2, false), "2", KeepAvailable},
+		// First rule does not decide when both are available.
+		{[]string{KeepAvailable, KeepPopular}, testTrack("1", "Song", 10, 1, true), testTrack("2", "Song", 20, 2, true), "1", KeepPopular},
+		{[]string{KeepNonRemix}, testTrack("1", "Song (Club Remix)", 50, 1, true), testTrack("2", "Song", 20, 2, true), "1", KeepNonRemix},
+		{[]string{KeepNonRemix}, testTrack("1", "Song - Radio Edit", 50, 1, true), testTrack("2", "Song", 20, 2, true), "", ""},
+		{[]string{KeepPopular}, testTrack("1", "Song", 20, 1, true), testTrack("2", "Song", 20, 2, true), "", ""},
+	} {
+		got, rule := resolveSimilar(test.policy, test.t1, test.t2)
+		gotId := ""
+		if got != nil {
+			gotId = got.Id()
+		}
+		if gotId != test.wantId || rule != test.wantRule {
+			t.Errorf("resolveSimilar(%v) got: %q %q, want: %q %q", test.policy, gotId, rule, test.wantId, test.wantRule)
+		}
+	}
+}
+
+func TestNotSimilar(t *testing.T) {
+	conf := SaverJob{NotSimilar: [][2]string{{"A - Song", "A - Song (Live)"}}}
+	if !conf.notSimilar("a - song (live)", "A -  Song") {
+		t.Errorf("notSimilar() of allowlisted pair got: false, want: true")
+	}
+	if conf.notSimilar("A - Song", "A - Other") {
+		t.Errorf("notSimilar() of other pair got: true, want: false")
+	}
+}
+
+func TestValidateSimilarResolution(t *testing.T) {
+	if err := (SaverJob{SimilarResolution: []string{KeepAvailable, KeepEarlier}}).validateSimilarResolution(); err != nil {
+		t.Errorf("validateSimilarResolution() error: %v", err)
+	}
+	if err := (SaverJob{SimilarResolution: []string{"newest"}}).validateSimilarResolution(); err == nil {
+		t.Errorf("validateSimilarResolution() with unknown rule should fail")
+	}
+}
+
+// Creates the playlist with random titles, every 20th song is a variant of one of the previous songs,
+// every 3rd song is by the same artist to have words used by many tracks.
+func syntheticPlaylist(size int) []*spotify.ImmutableSpotifyTrack {
+	r := rand.New(rand.NewSource(1))
+	word := func() string {
+		return "w" + strconv.Itoa(r.Intn(5*size))
+	}
+	variants := []string{" (Remastered)", " - Radio Edit", " (Live)", " (Club Remix)", ""}
+
+	result := make([]*spotify.ImmutableSpotifyTrack, size)
+	for i := range result {
+		artist := "Artist " + word()
+		title := word() + " " + word()
+		if i%3 == 0 {
+			artist = "Popular Band"
+		}
+		if i%20 == 19 {
+			previous := result[r.Intn(i)]
+			artist = previous.Artist()
+			title = previous.Title() + variants[r.Intn(len(variants))]
+		}
+		t := &spotify.SpotifyTrack{
+			Id:      strconv.Itoa(i),
+			Name:    title,
+			Artists: []spotify.SpotifyArtist{{Name: artist}},
+		}
+		result[i] = t.Immutable()
+	}
+	return result
+}
+
+// Compares all the pairs, used to verify the index.
+func bruteForceSimilarPairs(tracks []*spotify.ImmutableSpotifyTrack) []similarPair {
+	result := make([]similarPair, 0)
+	for i, t1 := range tracks {
+		for _, t2 := range tracks[i+1:] {
+			match12 := spotify.CalculateMatchRatio(t1.String(), t2)
+			match21 := spotify.CalculateMatchRatio(t2.String(), t1)
+			if match12+match21 >= 2*validMatch {
+				result = append(result, similarPair{t1, t2, match12, match21})
+			}
+		}
+	}
+	return result
+}
+
+func TestFindSimilarPairs(t *testing.T) {
+	tracks := syntheticPlaylist(400)
+	want := bruteForceSimilarPairs(tracks)
+	got := findSimilarPairs(SaverJob{}, tracks)
+	if len(want) == 0 || !reflect.DeepEqual(got, want) {
+		t.Errorf("findSimilarPairs() found %d pairs, brute force found %d", len(got), len(want))
+	}
+
+	// Track with only common words is still compared.
+	common := syntheticPlaylist(300)
+	for i := 0; i < 2; i++ {
+		t := &spotify.SpotifyTrack{Id: "x" + strconv.Itoa(i), Name: "Band", Artists: []spotify.SpotifyArtist{{Name: "Popular"}}}
+		common = append(common, t.Immutable())
+	}
+	got = findSimilarPairs(SaverJob{}, common)
+	if len(got) == 0 || got[len(got)-1].t2.Id() != "x1" {
+		t.Errorf("findSimilarPairs() did not find the tracks with common words")
+	}
+}
+
+func TestSimilarCandidates(t *testing.T) {
+	tracks := []*spotify.ImmutableSpotifyTrack{
+		spotify.NewImmutableSpotifyTrack("1", "A", "Song feat B"),
+		spotify.NewImmutableSpotifyTrack("2", "C", "Other"),
+		spotify.NewImmutableSpotifyTrack("3", "A", "Song"),
+		spotify.NewImmutableSpotifyTrack("4", "D", "Thing feat E"),
+	}
+	want := [][2]int{{0, 2}}
+	if got := similarCandidates(tracks); !reflect.DeepEqual(got, want) {
+		t.Errorf("similarCandidates() got: %v, want: %v", got, want)
+	}
+}
+
+func BenchmarkFindSimilarPairs(b *testing.B) {
+	for _, size := range []int{500, 1000, 5000} {
+		tracks := syntheticPlaylist(size)
+		b.Run(fmt.Sprintf("index/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				findSimilarPairs(SaverJob{}, tracks)
+			}
+		})
+	}
+	// Brute force of 5000 tracks takes minutes.
+	for _, size := range []int{500, 1000} {
+		tracks := syntheticPlaylist(size)
+		b.Run(fmt.Sprintf("brute-force/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bruteForceSimilarPairs(tracks)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"strings"
+	"time"
 )
 
 const validMatch = 75
@@ -13,15 +14,18 @@ const validMatch = 75
 // TODO: this should be in config.
 const spotifyMarket = "PL"
 
-var christmasSongNames = []string{"christmas", "xmas", "x-mas"}
-
 type spotifySaver struct {
 	spotify  *spotify.Spotify
 	notFound *nfCache
 }
 
+// Creates the Spotify client using the same market as the saver.
+func NewSpotify(ctx context.Context) (*spotify.Spotify, error) {
+	return spotify.New(ctx, spotifyMarket)
+}
+
 func newSpotify(ctx context.Context) (SongSaver, error) {
-	s, err := spotify.New(ctx, spotifyMarket)
+	s, err := NewSpotify(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -32,14 +36,32 @@ func newSpotify(ctx context.Context) (SongSaver, error) {
 	}, nil
 }
 
+func (s *spotifySaver) SaveCache() error {
+	return s.spotify.SaveCaches()
+}
+
 func (s *spotifySaver) Clean(ctx context.Context, conf SaverJob) (*CleanStatus, error) {
+	rules, err := conf.seasonalRules()
+	if err != nil {
+		return nil, err
+	}
+	err = conf.validateSimilarResolution()
+	if err != nil {
+		return nil, err
+	}
+
 	// Replace unplayable should be first as it uses ListPlaylistWithFilter method that always connects to spotify.
 	unplayable, err := s.replaceUnplayable(ctx, conf.Playlist)
 	if err != nil {
 		return nil, err
 	}
 
-	terrible, err := s.removeTerribleSongs(ctx, conf.Playlist, conf.AllowChristmasSong)
+	terrible, seasonal, err := s.removeTerribleSongs(ctx, conf.Playlist, rules, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	filtered, err := s.removeFilteredArtists(ctx, conf)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +71,7 @@ func (s *spotifySaver) Clean(ctx context.Context, conf SaverJob) (*CleanStatus,
 		return nil, err
 	}
 
-	similarTracks, err := s.findDuplicatesByName(ctx, conf.Playlist)
+	similarTracks, similarRemoved, err := s.findDuplicatesByName(ctx, conf)
 	if err != nil {
 		return nil, err
 	}
@@ -59,17 +81,48 @@ func (s *spotifySaver) Clean(ctx context.Context, conf SaverJob) (*CleanStatus,
 		UnavailableReplaced: unplayable.UnavailableReplaced,
 		Duplicates:          duplicates,
 		Similar:             similarTracks,
+		SimilarRemoved:      similarRemoved,
 		Terrible:            terrible,
+		Seasonal:            seasonal,
+		Filtered:            filtered,
 	}, nil
 }
 
 func (s *spotifySaver) Save(ctx context.Context, conf SaverJob, artistTitle string) (*Status, error) {
-	glog.V(2).Infof("Saving song: %v", artistTitle)
+	return s.SaveIsrc(ctx, conf, artistTitle, "")
+}
+
+// Tracks with the ISRC are used before searching by the artist and title.
+func (s *spotifySaver) SaveIsrc(ctx context.Context, conf SaverJob, artistTitle string, isrc string) (*Status, error) {
+	glog.V(2).Infof("Saving song: %v (%v)", artistTitle, isrc)
 
 	if len(artistTitle) == 0 {
 		return nil, fmt.Errorf("Empty song title")
 	}
 
+	rules, err := conf.seasonalRules()
+	if err != nil {
+		return nil, err
+	}
+
+	// Manual overrides are used instead of the cache and searching
+	override, err := findOverride(artistTitle)
+	if err != nil {
+		return nil, err
+	}
+	if override != nil {
+		return s.saveOverride(ctx, conf, artistTitle, override, rules)
+	}
+	if conf.ReviewMax > 0 {
+		pending, err := reviewPending(conf.Playlist, artistTitle)
+		if err != nil {
+			return nil, err
+		}
+		if pending {
+			return &Status{SongQueued: true}, nil
+		}
+	}
+
 	// First check if the track is in not found cache
 	cachedStatus := s.notFound.IsNotFound(artistTitle)
 	if cachedStatus != nil {
@@ -94,37 +147,35 @@ func (s *spotifySaver) Save(ctx context.Context, conf SaverJob, artistTitle stri
 	}
 
 	// If not in the playlist search for it in spotify
-	newTracks, err := s.spotify.FindTracks(ctx, artistTitle)
+	newTracks, newTrack, newTrackMatch, err := s.search(ctx, artistTitle, isrc)
 	if err != nil {
 		return nil, err
 	}
-	newTrack, newTrackMatch := s.findBestMatch(newTracks, artistTitle)
 
-	if !conf.AllowChristmasSong {
-		artistTitleLower := strings.ToLower(newTrack.String())
-		for _, christmasName := range christmasSongNames {
-			if strings.Contains(artistTitleLower, christmasName) {
-				glog.V(1).Infof("Ignoring christmas song: %q", artistTitle)
-				newTrackMatch = -1
-			}
+	if newTrackMatch >= validMatch || conf.needsReview(newTrackMatch) {
+		if status := seasonalStatus(rules, artistTitle, newTrack.String(), newTrackMatch); status != nil {
+			return status, nil
 		}
 	}
 
-	// if new track is a good match add it to the playlist
-	if newTrackMatch >= validMatch {
-		err = s.spotify.AddToPlaylist(ctx, conf.Playlist, newTrack)
+	if conf.needsReview(newTrackMatch) {
+		glog.V(1).Infof("Queueing song for review (%d): %q", newTrackMatch, artistTitle)
+		err = enqueueReview(conf.Playlist, artistTitle, reviewCandidates(newTracks, artistTitle, conf.ReviewMin))
 		if err != nil {
 			return nil, err
 		}
-
 		return &Status{
 			FoundTitle:   newTrack.String(),
 			MatchQuality: newTrackMatch,
-			SongAdded:    true,
-			SongExists:   false,
+			SongQueued:   true,
 		}, nil
 	}
 
+	// if new track is a good match add it to the playlist
+	if newTrackMatch >= validMatch {
+		return s.addTrack(ctx, conf, artistTitle, newTrack, newTrackMatch, s.goodMatches(newTracks, artistTitle))
+	}
+
 	// If there was no match add it to cache
 	status := &Status{
 		FoundTitle:   newTrack.String(),
@@ -136,6 +187,85 @@ func (s *spotifySaver) Save(ctx context.Context, conf SaverJob, artistTitle stri
 	return status, nil
 }
 
+// Adds the track unless it is not allowed by the filters, when its audio features are not suitable the first suitable
+// candidate is added instead.
+func (s *spotifySaver) addTrack(ctx context.Context, conf SaverJob, artistTitle string, track *spotify.ImmutableSpotifyTrack, match int, candidates []*spotify.ImmutableSpotifyTrack) (*Status, error) {
+	reason, err := s.filterReason(ctx, conf, track)
+	if err != nil {
+		return nil, err
+	}
+	if len(reason) > 0 {
+		// Not cached as not found, other playlists can use different filters.
+		glog.V(1).Infof("Ignoring filtered song (%v): %q", reason, artistTitle)
+		return &Status{
+			FoundTitle:   track.String(),
+			MatchQuality: match,
+			SongFiltered: true,
+		}, nil
+	}
+
+	suitable, reason := s.suitableTrack(ctx, conf, track, candidates)
+	if suitable == nil {
+		glog.V(1).Infof("Ignoring unsuitable song (%v): %q", reason, artistTitle)
+		return &Status{
+			FoundTitle:     track.String(),
+			MatchQuality:   match,
+			SongUnsuitable: true,
+		}, nil
+	}
+	if suitable != track {
+		glog.V(1).Infof("Using suitable candidate %q instead of %q for %q", suitable, track, artistTitle)
+		track = suitable
+		match = spotify.CalculateMatchRatio(artistTitle, track)
+	}
+
+	err = s.spotify.AddToPlaylist(ctx, conf.Playlist, track)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Status{
+		FoundTitle:   track.String(),
+		FoundUrl:     track.Url(),
+		MatchQuality: match,
+		SongAdded:    true,
+		SongExists:   false,
+	}, nil
+}
+
+// Saves the track set by the override. Filters are still used as the overrides are shared by all the playlists.
+func (s *spotifySaver) saveOverride(ctx context.Context, conf SaverJob, artistTitle string, override *Override, rules []SeasonalRule) (*Status, error) {
+	if len(override.TrackId) == 0 {
+		glog.V(1).Infof("Song is never matched: %q", artistTitle)
+		return &Status{MatchQuality: -1}, nil
+	}
+
+	existingTracks, err := s.spotify.ListPlaylist(ctx, conf.Playlist)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range existingTracks {
+		if t.Id() == override.TrackId {
+			return &Status{
+				FoundTitle:   t.String(),
+				MatchQuality: 100,
+				SongExists:   true,
+			}, nil
+		}
+	}
+
+	if status := seasonalStatus(rules, artistTitle, override.Artist+" - "+override.Title, 100); status != nil {
+		return status, nil
+	}
+
+	// Track is requested to have the artist ids used by the genre filters.
+	track, err := s.spotify.GetTrack(ctx, override.TrackId)
+	if err != nil {
+		return nil, err
+	}
+	return s.addTrack(ctx, conf, artistTitle, track, 100, nil)
+}
+
 func (s *spotifySaver) findBestMatch(tracks []*spotify.ImmutableSpotifyTrack, artistTitle string) (*spotify.ImmutableSpotifyTrack, int) {
 	bestTrackMatch := -1
 	var bestTrack *spotify.ImmutableSpotifyTrack
@@ -153,6 +283,40 @@ func (s *spotifySaver) findBestMatch(tracks []*spotify.ImmutableSpotifyTrack, ar
 	return bestTrack, bestTrackMatch
 }
 
+// Returns the found tracks and the best match. Track found by the ISRC is the exact match, artist and
+// title are searched when the ISRC is empty or not known by Spotify.
+func (s *spotifySaver) search(ctx context.Context, artistTitle string, isrc string) ([]*spotify.ImmutableSpotifyTrack, *spotify.ImmutableSpotifyTrack, int, error) {
+	if len(isrc) > 0 {
+		tracks, err := s.spotify.FindTracksByIsrc(ctx, isrc)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if len(tracks) > 0 {
+			track, _ := s.findBestMatch(tracks, artistTitle)
+			return tracks, track, 100, nil
+		}
+		glog.V(1).Infof("ISRC %v not found, searching for %q.", isrc, artistTitle)
+	}
+
+	tracks, err := s.spotify.FindTracks(ctx, artistTitle)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	track, match := s.findBestMatch(tracks, artistTitle)
+	return tracks, track, match, nil
+}
+
+// Returns the tracks that are valid matches of the song.
+func (s *spotifySaver) goodMatches(tracks []*spotify.ImmutableSpotifyTrack, artistTitle string) []*spotify.ImmutableSpotifyTrack {
+	result := make([]*spotify.ImmutableSpotifyTrack, 0)
+	for _, track := range tracks {
+		if spotify.CalculateMatchRatio(artistTitle, track) >= validMatch {
+			result = append(result, track)
+		}
+	}
+	return result
+}
+
 func (s *spotifySaver) replaceUnplayable(ctx context.Context, playlistId string) (*CleanStatus, error) {
 	trueForUnavailable := func(track spotify.SpotifyTrack) bool {
 		for _, market := range track.AvailableMarkets {
@@ -215,32 +379,40 @@ func (s *spotifySaver) replaceUnplayable(ctx context.Context, playlistId string)
 	}, nil
 }
 
-func (s *spotifySaver) removeTerribleSongs(ctx context.Context, playlistId string, allowChristmasSong bool) (int, error) {
+// Removes terrible songs and seasonal songs after their season, returns number of both removed.
+func (s *spotifySaver) removeTerribleSongs(ctx context.Context, playlistId string, rules []SeasonalRule, now time.Time) (int, int, error) {
 	tracks, err := s.spotify.ListPlaylist(ctx, playlistId)
 	if err != nil {
-		return 0, err
-	}
-
-	terribleSongNames := append([]string(nil), spotify.TerribleSongNames...)
-	if !allowChristmasSong {
-		terribleSongNames = append(terribleSongNames, christmasSongNames...)
+		return 0, 0, err
 	}
 
 	removed := 0
+	seasonal := 0
 	for _, t := range tracks {
 		artistTitle := strings.ToLower(t.String())
-		for _, terribleName := range terribleSongNames {
+		isTerrible := false
+		for _, terribleName := range spotify.TerribleSongNames {
 			if strings.Contains(artistTitle, terribleName) {
 				glog.V(1).Infof("[%v] Removing terrible song: %#v", playlistId, t)
 				err = s.spotify.RemoveFromPlaylist(ctx, playlistId, t)
 				if err != nil {
-					return 0, fmt.Errorf("error while removing: %q when removing wrong song %#v", err, t)
+					return 0, 0, fmt.Errorf("error while removing: %q when removing wrong song %#v", err, t)
 				}
 				removed++
+				isTerrible = true
 			}
 		}
+
+		if rule := expiredRule(rules, artistTitle, now); rule != nil && !isTerrible {
+			glog.V(1).Infof("[%v] Removing %v song after its season: %#v", playlistId, rule.Name, t)
+			err = s.spotify.RemoveFromPlaylist(ctx, playlistId, t)
+			if err != nil {
+				return 0, 0, fmt.Errorf("error while removing: %q when removing seasonal song %#v", err, t)
+			}
+			seasonal++
+		}
 	}
-	return removed, nil
+	return removed, seasonal, nil
 }
 
 func (s *spotifySaver) findDuplicatesById(ctx context.Context, playlistId string) (int, error) {
@@ -281,31 +453,53 @@ func (s *spotifySaver) findDuplicatesById(ctx context.Context, playlistId string
 	return len(toRemove), nil
 }
 
-func (s *spotifySaver) findDuplicatesByName(ctx context.Context, playlistId string) ([]*SimilarTrack, error) {
+// Finds the similar tracks and removes one of them if the resolution policy decides which to keep.
+// Returns the similar tracks and the number of removed ones.
+func (s *spotifySaver) findDuplicatesByName(ctx context.Context, conf SaverJob) ([]*SimilarTrack, int, error) {
+	playlistId := conf.Playlist
 	tracks, err := s.spotify.ListPlaylist(ctx, playlistId)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	result := make([]*SimilarTrack, 0)
 
 	if len(tracks) <= 1 {
-		return result, nil
+		return result, 0, nil
 	}
 
-	for i, t1 := range tracks[0 : len(tracks)-1] {
-		for _, t2 := range tracks[i+1:] {
-			match12 := spotify.CalculateMatchRatio(t1.String(), t2)
-			match21 := spotify.CalculateMatchRatio(t2.String(), t1)
-			if match12+match21 >= 2*validMatch {
-				glog.V(1).Infof("[%v] %3d %3d %q==%q", playlistId, match12, match21, t1, t2)
-				result = append(result, &SimilarTrack{
-					Title1:        t1.String(),
-					Title2:        t2.String(),
-					AvgMatchRatio: (match12 + match21) / 2,
-				})
-			}
+	// Track can be similar to many other tracks, but it is removed only once.
+	removed := make(map[string]bool)
+	for _, pair := range findSimilarPairs(conf, tracks) {
+		if removed[pair.t1.Id()] || removed[pair.t2.Id()] {
+			continue
+		}
+
+		glog.V(1).Infof("[%v] %3d %3d %q==%q", playlistId, pair.match12, pair.match21, pair.t1, pair.t2)
+		similar := &SimilarTrack{
+			Title1:        pair.t1.String(),
+			Title2:        pair.t2.String(),
+			AvgMatchRatio: (pair.match12 + pair.match21) / 2,
+		}
+		result = append(result, similar)
+
+		toRemove, rule := resolveSimilar(conf.SimilarResolution, pair.t1, pair.t2)
+		if toRemove == nil {
+			continue
+		}
+		similar.Removed = toRemove.String()
+		similar.RemovedBy = rule
+		if conf.SimilarDryRun {
+			glog.V(1).Infof("[%v] Dry run, not removing similar song (%v): %q", playlistId, rule, toRemove)
+			continue
+		}
+
+		glog.V(1).Infof("[%v] Removing similar song (%v): %q", playlistId, rule, toRemove)
+		err = s.spotify.RemoveFromPlaylist(ctx, playlistId, toRemove)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error while removing: %q when removing similar song %q", err, toRemove)
 		}
+		removed[toRemove.Id()] = true
 	}
-	return result, nil
+	return result, len(removed), nil
 }
@@ -1,25 +1,315 @@
 package savers
 
 import (
+	"birnenlabs.com/go/lib/matcher"
+	"birnenlabs.com/go/lib/spotify"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-type stdoutSaver struct{}
+const (
+	StdoutText = "text"
+	StdoutJson = "json"
+	StdoutCsv  = "csv"
+)
+
+// Number of the added songs remembered per playlist, the oldest are forgotten.
+const maxStdoutSongs = 10000
+
+var stdoutCsvHeader = []string{"Time", "Playlist", "ArtistTitle", "Result", "FoundTitle", "TrackId", "MatchQuality"}
+
+// Read-only part of the Spotify client used to show the matches.
+type trackFinder interface {
+	FindTracks(ctx context.Context, query string) ([]*spotify.ImmutableSpotifyTrack, error)
+}
+
+// Saver printing the songs instead of saving them, useful for debugging and piping. Every song is printed,
+// songs already added to the playlist are reported as existing. The output format is chosen by
+// SaverJob.StdoutFormat. When SaverJob.StdoutMatch is set the songs are searched in Spotify, nothing
+// is modified there.
+type stdoutSaver struct {
+	out    io.Writer
+	finder trackFinder
+	lock   sync.Mutex
+	// Added songs by the playlist.
+	added         map[string]*stdoutPlaylist
+	maxSongs      int
+	headerWritten bool
+}
+
+type stdoutSong struct {
+	ArtistTitle string
+	Artists     []string
+	// Number of the song in the playlist order.
+	seq int
+}
+
+type stdoutPlaylist struct {
+	// Songs by the normalized artist title.
+	songs map[string]stdoutSong
+	// Songs in the order they were added, entries of the removed songs are skipped.
+	order   []stdoutSong
+	nextSeq int
+}
+
+// Single line of the output.
+type stdoutRecord struct {
+	Time        time.Time
+	Playlist    string
+	ArtistTitle string
+	// One of "added", "exists", "filtered", "seasonal", "not found".
+	Result       string
+	FoundTitle   string
+	TrackId      string
+	MatchQuality int
+}
 
 func newStdout() (SongSaver, error) {
-	return &stdoutSaver{}, nil
+	return newStdoutWithWriter(os.Stdout, nil), nil
+}
+
+// Finder is used when SaverJob.StdoutMatch is set, if nil the Spotify client is created on the first use.
+func newStdoutWithWriter(out io.Writer, finder trackFinder) *stdoutSaver {
+	return &stdoutSaver{
+		out:      out,
+		finder:   finder,
+		added:    make(map[string]*stdoutPlaylist),
+		maxSongs: maxStdoutSongs,
+	}
+}
+
+// Adds the song, the oldest songs are forgotten above max songs.
+func (p *stdoutPlaylist) add(key string, song stdoutSong, max int) {
+	song.seq = p.nextSeq
+	p.nextSeq++
+	p.songs[key] = song
+	p.order = append(p.order, song)
+	for len(p.songs) > max {
+		oldest := p.order[0]
+		p.order = p.order[1:]
+		oldestKey := normalizeArtistTitle(oldest.ArtistTitle)
+		if s, ok := p.songs[oldestKey]; ok && s.seq == oldest.seq {
+			delete(p.songs, oldestKey)
+		}
+	}
+	// Entries of the removed songs are dropped when they are the majority.
+	if len(p.order) > 2*len(p.songs) {
+		order := make([]stdoutSong, 0, len(p.songs))
+		for _, s := range p.order {
+			if current, ok := p.songs[normalizeArtistTitle(s.ArtistTitle)]; ok && current.seq == s.seq {
+				order = append(order, s)
+			}
+		}
+		p.order = order
+	}
 }
 
+// Nothing is printed, the songs which would be removed by the real saver are forgotten so they are
+// reported as added again when they are played.
 func (s *stdoutSaver) Clean(ctx context.Context, conf SaverJob) (*CleanStatus, error) {
-	return nil, nil
+	rules, err := conf.seasonalRules()
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	status := &CleanStatus{}
+	now := time.Now()
+	p := s.added[conf.Playlist]
+	if p == nil {
+		return status, nil
+	}
+	for key, song := range p.songs {
+		if matcher.IsTerrible(song.ArtistTitle) {
+			status.Terrible++
+		} else if rule := expiredRule(rules, strings.ToLower(song.ArtistTitle), now); rule != nil {
+			status.Seasonal++
+		} else if reason := artistFilterReason(conf, song.Artists, nil); len(reason) > 0 {
+			status.Filtered++
+		} else {
+			continue
+		}
+		glog.V(1).Infof("[%v] Forgetting added song: %q", conf.Playlist, song.ArtistTitle)
+		delete(p.songs, key)
+	}
+	return status, nil
 }
 
 func (s *stdoutSaver) Save(ctx context.Context, conf SaverJob, artistTitle string) (*Status, error) {
-	time.Sleep(time.Millisecond * 100)
-	return &Status{
-		MatchQuality: -99,
-		FoundTitle:   "NOTHING WAS SAVED",
-		SongAdded:    true,
-	}, nil
+	if len(artistTitle) == 0 {
+		return nil, fmt.Errorf("Empty song title")
+	}
+	if !validStdoutFormat(conf.StdoutFormat) {
+		return nil, fmt.Errorf("invalid stdout format: %q", conf.StdoutFormat)
+	}
+
+	key := normalizeArtistTitle(artistTitle)
+	s.lock.Lock()
+	var existing stdoutSong
+	ok := false
+	if p := s.added[conf.Playlist]; p != nil {
+		existing, ok = p.songs[key]
+	}
+	s.lock.Unlock()
+
+	var status *Status
+	var record *stdoutRecord
+	if ok {
+		status = &Status{
+			FoundTitle:   existing.ArtistTitle,
+			MatchQuality: 100,
+			SongExists:   true,
+		}
+		record = &stdoutRecord{
+			Time:         time.Now(),
+			Playlist:     conf.Playlist,
+			ArtistTitle:  artistTitle,
+			Result:       "exists",
+			FoundTitle:   existing.ArtistTitle,
+			MatchQuality: 100,
+		}
+	} else {
+		var song stdoutSong
+		var err error
+		status, song, record, err = s.match(ctx, conf, artistTitle)
+		if err != nil {
+			return nil, err
+		}
+		if status.SongAdded {
+			s.lock.Lock()
+			p := s.added[conf.Playlist]
+			if p == nil {
+				p = &stdoutPlaylist{songs: make(map[string]stdoutSong)}
+				s.added[conf.Playlist] = p
+			}
+			p.add(key, song, s.maxSongs)
+			s.lock.Unlock()
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.write(conf.StdoutFormat, record)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Without the matching the song is printed as it is, the artist is everything before " - ".
+func (s *stdoutSaver) match(ctx context.Context, conf SaverJob, artistTitle string) (*Status, stdoutSong, *stdoutRecord, error) {
+	record := &stdoutRecord{
+		Time:        time.Now(),
+		Playlist:    conf.Playlist,
+		ArtistTitle: artistTitle,
+	}
+
+	if !conf.StdoutMatch {
+		record.Result = "added"
+		record.FoundTitle = artistTitle
+		record.MatchQuality = 100
+		artist := strings.SplitN(artistTitle, " - ", 2)[0]
+		return &Status{
+			FoundTitle:   artistTitle,
+			MatchQuality: 100,
+			SongAdded:    true,
+		}, stdoutSong{ArtistTitle: artistTitle, Artists: []string{artist}}, record, nil
+	}
+
+	rules, err := conf.seasonalRules()
+	if err != nil {
+		return nil, stdoutSong{}, nil, err
+	}
+	finder, err := s.trackFinder(ctx)
+	if err != nil {
+		return nil, stdoutSong{}, nil, err
+	}
+	tracks, err := finder.FindTracks(ctx, artistTitle)
+	if err != nil {
+		return nil, stdoutSong{}, nil, err
+	}
+
+	status := &Status{MatchQuality: -1}
+	var track *spotify.ImmutableSpotifyTrack
+	for _, t := range tracks {
+		match := spotify.CalculateMatchRatio(artistTitle, t)
+		if match > status.MatchQuality {
+			status.MatchQuality = match
+			track = t
+		}
+	}
+	if track != nil {
+		status.FoundTitle = track.String()
+		status.FoundUrl = track.Url()
+		record.FoundTitle = track.String()
+		record.TrackId = track.Id()
+	}
+	record.MatchQuality = status.MatchQuality
+
+	// Genres are not checked to avoid additional Spotify requests.
+	if status.MatchQuality < validMatch {
+		record.Result = "not found"
+	} else if seasonal := seasonalStatus(rules, artistTitle, track.String(), status.MatchQuality); seasonal != nil {
+		record.Result = "seasonal"
+		status.SongSeasonal = true
+	} else if reason := artistFilterReason(conf, track.Artists(), nil); len(reason) > 0 {
+		glog.V(1).Infof("Ignoring filtered song (%v): %q", reason, artistTitle)
+		record.Result = "filtered"
+		status.SongFiltered = true
+	} else {
+		record.Result = "added"
+		status.SongAdded = true
+	}
+	if track == nil {
+		return status, stdoutSong{}, record, nil
+	}
+	return status, stdoutSong{ArtistTitle: track.String(), Artists: track.Artists()}, record, nil
+}
+
+func (s *stdoutSaver) trackFinder(ctx context.Context) (trackFinder, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.finder == nil {
+		sp, err := NewSpotify(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.finder = sp
+	}
+	return s.finder, nil
+}
+
+// Must be called with the lock held.
+func (s *stdoutSaver) write(format string, r *stdoutRecord) error {
+	switch format {
+	case StdoutJson:
+		return json.NewEncoder(s.out).Encode(r)
+	case StdoutCsv:
+		w := csv.NewWriter(s.out)
+		if !s.headerWritten {
+			w.Write(stdoutCsvHeader)
+			s.headerWritten = true
+		}
+		w.Write([]string{r.Time.Format(time.RFC3339), r.Playlist, r.ArtistTitle, r.Result, r.FoundTitle, r.TrackId, strconv.Itoa(r.MatchQuality)})
+		w.Flush()
+		return w.Error()
+	default:
+		_, err := fmt.Fprintf(s.out, "%v [%v] %-9v %3d %q -> %q\n", r.Time.Format("15:04:05"), r.Playlist, r.Result, r.MatchQuality, r.ArtistTitle, r.FoundTitle)
+		return err
+	}
+}
+
+func validStdoutFormat(format string) bool {
+	return format == "" || format == StdoutText || format == StdoutJson || format == StdoutCsv
 }
@@ -0,0 +1,144 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/spotify"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type fakeFinder struct {
+	tracks  []*spotify.ImmutableSpotifyTrack
+	queries int
+}
+
+func (f *fakeFinder) FindTracks(ctx context.Context, query string) ([]*spotify.ImmutableSpotifyTrack, error) {
+	f.queries++
+	return f.tracks, nil
+}
+
+func TestStdoutSave(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	s := newStdoutWithWriter(&out, nil)
+	conf := SaverJob{Playlist: "p"}
+
+	got, err := s.Save(ctx, conf, "Artist - Song")
+	if err != nil || !got.SongAdded || got.FoundTitle != "Artist - Song" {
+		t.Errorf("Save() got: %+v %v, want added", got, err)
+	}
+	got, err = s.Save(ctx, conf, "ARTIST -  song")
+	if err != nil || !got.SongExists {
+		t.Errorf("Save() second time got: %+v %v, want exists", got, err)
+	}
+	// Other playlist adds the song again.
+	got, err = s.Save(ctx, SaverJob{Playlist: "other"}, "Artist - Song")
+	if err != nil || !got.SongAdded {
+		t.Errorf("Save() other playlist got: %+v %v, want added", got, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	// Every song is printed, the existing ones with the existing title.
+	if len(lines) != 3 || !strings.Contains(lines[0], `[p] added`) || !strings.Contains(lines[0], `"Artist - Song"`) ||
+		!strings.Contains(lines[1], `[p] exists`) || !strings.Contains(lines[1], `"ARTIST -  song"`) || !strings.Contains(lines[2], `[other] added`) {
+		t.Errorf("Output got: %q, want 3 lines", out.String())
+	}
+
+	_, err = s.Save(ctx, SaverJob{StdoutFormat: "xml"}, "Artist - Song")
+	if err == nil {
+		t.Errorf("Save() with invalid format got no error")
+	}
+}
+
+func TestStdoutFormats(t *testing.T) {
+	ctx := context.Background()
+	finder := &fakeFinder{tracks: []*spotify.ImmutableSpotifyTrack{
+		spotify.NewImmutableSpotifyTrack("id1", "Artist", "Song"),
+		spotify.NewImmutableSpotifyTrack("id2", "Artist", "Song (Karaoke)"),
+	}}
+
+	var out bytes.Buffer
+	s := newStdoutWithWriter(&out, finder)
+	s.Save(ctx, SaverJob{Playlist: "p", StdoutFormat: StdoutJson, StdoutMatch: true}, "Artist - Song")
+	var r stdoutRecord
+	err := json.Unmarshal(out.Bytes(), &r)
+	if err != nil || r.Result != "added" || r.TrackId != "id1" || r.MatchQuality != 100 || r.Playlist != "p" {
+		t.Errorf("JSON got: %q %v, want added id1", out.String(), err)
+	}
+
+	out.Reset()
+	s = newStdoutWithWriter(&out, finder)
+	conf := SaverJob{Playlist: "p", StdoutFormat: StdoutCsv, StdoutMatch: true, DenyArtists: []string{"artist"}}
+	s.Save(ctx, conf, "Artist - Song")
+	s.Save(ctx, conf, "Nobody - Nothing")
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(records) != 3 {
+		t.Fatalf("CSV got: %q %v, want header and 2 records", out.String(), err)
+	}
+	if records[0][3] != "Result" || records[1][3] != "filtered" || records[2][3] != "not found" {
+		t.Errorf("CSV results got: %v, want filtered and not found", records)
+	}
+	if finder.queries != 3 {
+		t.Errorf("Spotify queries got: %d, want: 3", finder.queries)
+	}
+}
+
+func TestStdoutClean(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	s := newStdoutWithWriter(&out, nil)
+	conf := SaverJob{Playlist: "p"}
+	for _, song := range []string{"Artist - Song", "Denied - Song", "Singer - Last Christmas"} {
+		s.Save(ctx, conf, song)
+	}
+
+	conf.DenyArtists = []string{"denied"}
+	status, err := s.Clean(ctx, conf)
+	if err != nil || status == nil || status.Filtered != 1 || status.Seasonal != 1 {
+		t.Fatalf("Clean() got: %v %v, want 1 filtered and 1 seasonal", status, err)
+	}
+	if len(s.added["p"].songs) != 1 {
+		t.Errorf("Added songs after clean got: %v, want 1", s.added["p"].songs)
+	}
+
+	// Forgotten songs are added again.
+	got, err := s.Save(ctx, SaverJob{Playlist: "p"}, "Denied - Song")
+	if err != nil || !got.SongAdded {
+		t.Errorf("Save() after clean got: %+v %v, want added", got, err)
+	}
+}
+
+func TestStdoutMaxSongs(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	s := newStdoutWithWriter(&out, nil)
+	s.maxSongs = 2
+	conf := SaverJob{Playlist: "p"}
+	for _, song := range []string{"Artist - Song 1", "Artist - Song 2", "Artist - Song 1", "Artist - Song 3", "Artist - Song 4", "Artist - Song 5"} {
+		s.Save(ctx, conf, song)
+	}
+
+	p := s.added["p"]
+	if len(p.songs) != 2 || len(p.order) > 4 {
+		t.Errorf("Added songs got: %v (%d in order), want 2", p.songs, len(p.order))
+	}
+	// The oldest songs are forgotten and added again.
+	for _, test := range []struct {
+		song  string
+		added bool
+	}{
+		{"Artist - Song 5", false},
+		{"Artist - Song 1", true},
+	} {
+		got, err := s.Save(ctx, conf, test.song)
+		if err != nil || got.SongAdded != test.added || got.SongExists == test.added {
+			t.Errorf("Save(%q) got: %+v %v, want added: %v", test.song, got, err, test.added)
+		}
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 8 {
+		t.Errorf("Output got: %d lines, want: 8", len(lines))
+	}
+}
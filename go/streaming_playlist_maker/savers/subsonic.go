@@ -0,0 +1,113 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/matcher"
+	"birnenlabs.com/go/lib/subsonic"
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"strings"
+	"time"
+)
+
+// Saver of the Subsonic compatible servers (e.g. Navidrome), SaverJob.Playlist is the playlist id.
+type subsonicSaver struct {
+	subsonic *subsonic.Subsonic
+	notFound *nfCache
+}
+
+func newSubsonic() (SongSaver, error) {
+	s, err := subsonic.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return &subsonicSaver{
+		subsonic: s,
+		notFound: newCache(),
+	}, nil
+}
+
+func (s *subsonicSaver) Clean(ctx context.Context, conf SaverJob) (*CleanStatus, error) {
+	rules, err := conf.seasonalRules()
+	if err != nil {
+		return nil, err
+	}
+
+	songs, err := s.subsonic.Playlist(ctx, conf.Playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	// All the songs are removed at once by their indexes.
+	status := &CleanStatus{}
+	toRemove := make([]int, 0)
+	ids := make(map[string]bool)
+	now := time.Now()
+	for i, song := range songs {
+		if ids[song.Id] {
+			glog.V(1).Infof("[%v] Removing duplicate: %q", conf.Playlist, song)
+			status.Duplicates++
+		} else if matcher.IsTerrible(song.String()) {
+			glog.V(1).Infof("[%v] Removing terrible song: %q", conf.Playlist, song)
+			status.Terrible++
+		} else if rule := expiredRule(rules, strings.ToLower(song.String()), now); rule != nil {
+			glog.V(1).Infof("[%v] Removing %v song after its season: %q", conf.Playlist, rule.Name, song)
+			status.Seasonal++
+		} else if reason := artistFilterReason(conf, []string{song.Artist}, genreList(song.Genre)); len(reason) > 0 {
+			glog.V(1).Infof("[%v] Removing filtered song (%v): %q", conf.Playlist, reason, song)
+			status.Filtered++
+		} else {
+			ids[song.Id] = true
+			continue
+		}
+		toRemove = append(toRemove, i)
+	}
+
+	if len(toRemove) > 0 {
+		err = s.subsonic.RemoveFromPlaylist(ctx, conf.Playlist, toRemove)
+		if err != nil {
+			return nil, fmt.Errorf("error while removing %d songs: %q", len(toRemove), err)
+		}
+	}
+	return status, nil
+}
+
+func (s *subsonicSaver) Save(ctx context.Context, conf SaverJob, artistTitle string) (*Status, error) {
+	return saveToLibrary(ctx, conf, artistTitle, s, s.notFound)
+}
+
+func (s *subsonicSaver) playlistSongs(ctx context.Context, playlist string) ([]librarySong, error) {
+	songs, err := s.subsonic.Playlist(ctx, playlist)
+	if err != nil {
+		return nil, err
+	}
+	return subsonicLibrarySongs(songs), nil
+}
+
+func (s *subsonicSaver) searchSongs(ctx context.Context, artistTitle string) ([]librarySong, error) {
+	// Subsonic search matches words, so the separator is removed.
+	songs, err := s.subsonic.Search(ctx, strings.Replace(artistTitle, " - ", " ", 1))
+	if err != nil {
+		return nil, err
+	}
+	return subsonicLibrarySongs(songs), nil
+}
+
+func (s *subsonicSaver) addSong(ctx context.Context, playlist string, song librarySong) error {
+	return s.subsonic.AddToPlaylist(ctx, playlist, song.Item.(string))
+}
+
+// Library songs with the Subsonic song ids.
+func subsonicLibrarySongs(songs []subsonic.Song) []librarySong {
+	result := make([]librarySong, len(songs))
+	for i, song := range songs {
+		result[i] = librarySong{
+			Artist: song.Artist,
+			Title:  song.Title,
+			Genre:  song.Genre,
+			Item:   song.Id,
+		}
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package savers
+
+import (
+	"birnenlabs.com/go/lib/subsonic"
+	"birnenlabs.com/go/lib/subsonic/subsonictest"
+	"context"
+	"net/http"
+	"testing"
+)
+
+var subsonicLibrary = []subsonic.Song{
+	{Id: "1", Artist: "Artist", Title: "Song"},
+	{Id: "2", Artist: "Artist", Title: "Song (Karaoke)"},
+	{Id: "3", Artist: "Polka Band", Title: "Dance", Genre: "Polka"},
+	{Id: "4", Artist: "Singer", Title: "Last Christmas"},
+	{Id: "5", Artist: "Other", Title: "Tune"},
+}
+
+func newTestSubsonic(t *testing.T) (*subsonicSaver, *subsonictest.Server) {
+	server := subsonictest.NewServer(subsonicLibrary)
+	t.Cleanup(server.Close)
+	return &subsonicSaver{
+		subsonic: subsonic.NewWithConfig(&http.Client{}, server.Config()),
+		notFound: newCache(),
+	}, server
+}
+
+func TestSubsonicSave(t *testing.T) {
+	ctx := context.Background()
+	s, server := newTestSubsonic(t)
+	server.SetPlaylist("p", nil)
+	conf := SaverJob{Playlist: "p", DenyGenres: []string{"polka"}}
+
+	for _, test := range []struct {
+		artistTitle string
+		want        Status
+	}{
+		{"Artist - Song", Status{SongAdded: true, FoundTitle: "Artist - Song", MatchQuality: 100}},
+		{"ARTIST - song", Status{SongExists: true, FoundTitle: "Artist - Song", MatchQuality: 100}},
+		{"Polka Band - Dance", Status{SongFiltered: true, FoundTitle: "Polka Band - Dance", MatchQuality: 100}},
+		// Christmas songs are not allowed by default.
+		{"Singer - Last Christmas", Status{SongSeasonal: true, FoundTitle: "Singer - Last Christmas", MatchQuality: 100}},
+		{"Nobody - Nothing", Status{MatchQuality: -1}},
+	} {
+		got, err := s.Save(ctx, conf, test.artistTitle)
+		if err != nil || *got != test.want {
+			t.Errorf("Save(%q) got: %+v %v, want: %+v", test.artistTitle, got, err, test.want)
+		}
+	}
+
+	playlist := server.Playlist("p")
+	if len(playlist) != 1 || playlist[0].Id != "1" {
+		t.Errorf("Playlist got: %v, want only song 1", playlist)
+	}
+
+	// Not found songs are cached.
+	searches := server.Requests("search3")
+	s.Save(ctx, conf, "Nobody - Nothing")
+	if got := server.Requests("search3"); got != searches {
+		t.Errorf("Search requests after cached song got: %d, want: %d", got, searches)
+	}
+
+	// Seasonal songs are not cached, they can be added in the season.
+	s.Save(ctx, conf, "Singer - Last Christmas")
+	if got := server.Requests("search3"); got != searches+1 {
+		t.Errorf("Search requests after seasonal song got: %d, want: %d", got, searches+1)
+	}
+	conf.SeasonalRules = []SeasonalRule{{Start: "01-01", End: "12-31"}}
+	got, err := s.Save(ctx, conf, "Singer - Last Christmas")
+	if err != nil || !got.SongAdded {
+		t.Errorf("Save() in season got: %+v %v, want added", got, err)
+	}
+}
+
+func TestSubsonicClean(t *testing.T) {
+	ctx := context.Background()
+	s, server := newTestSubsonic(t)
+	l := subsonicLibrary
+	server.SetPlaylist("p", []subsonic.Song{l[0], l[1], l[0], l[2], l[3], l[4]})
+
+	got, err := s.Clean(ctx, SaverJob{Playlist: "p", DenyArtists: []string{"polka band"}})
+	if err != nil {
+		t.Fatalf("Clean() error: %v", err)
+	}
+	if got.Duplicates != 1 || got.Terrible != 1 || got.Seasonal != 1 || got.Filtered != 1 {
+		t.Errorf("Clean() got: %+v, want one of each removed", got)
+	}
+
+	playlist := server.Playlist("p")
+	if len(playlist) != 2 || playlist[0].Id != "1" || playlist[1].Id != "5" {
+		t.Errorf("Playlist after Clean() got: %v, want songs 1 and 5", playlist)
+	}
+}
@@ -0,0 +1,72 @@
+package sources
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// Name of the gob file in the config directory with the checkpoints of the history sources.
+var checkpointStoreName = "streaming-playlist-maker-checkpoints"
+
+// Guards reading and writing the checkpoint file, jobs are saving their checkpoints concurrently.
+var checkpointLock sync.Mutex
+
+// Number of the runs trying to fetch the chart, it is skipped after that.
+const maxChartAttempts = 3
+
+// Progress of the history source: charts from Start to Last of the UrlBase were processed, except
+// of the Failed ones which are fetched again by the next runs (and are never after Last).
+type Checkpoint struct {
+	UrlBase string
+	Start   time.Time
+	Last    time.Time
+	Failed  []FailedChart
+}
+
+type FailedChart struct {
+	Date     time.Time
+	Attempts int
+}
+
+func loadCheckpoints() (map[string]Checkpoint, error) {
+	checkpoints := make(map[string]Checkpoint)
+	err := conf.LoadConfigFromFile(checkpointStoreName, &checkpoints)
+	if errors.Is(err, fs.ErrNotExist) {
+		glog.V(1).Infof("Checkpoints not found, starting with empty ones.")
+		return make(map[string]Checkpoint), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not load checkpoints: %v", err)
+	}
+	return checkpoints, nil
+}
+
+// Returns the checkpoint saved by the job.
+func getCheckpoint(name string) (Checkpoint, bool, error) {
+	checkpointLock.Lock()
+	defer checkpointLock.Unlock()
+
+	checkpoints, err := loadCheckpoints()
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	cp, ok := checkpoints[name]
+	return cp, ok, nil
+}
+
+// Saves the checkpoint of the job, checkpoints of the other jobs are preserved.
+func setCheckpoint(name string, cp Checkpoint) error {
+	checkpointLock.Lock()
+	defer checkpointLock.Unlock()
+
+	checkpoints, err := loadCheckpoints()
+	if err != nil {
+		return err
+	}
+	checkpoints[name] = cp
+	return conf.SaveConfigToFileAtomic(checkpointStoreName, checkpoints)
+}
@@ -3,38 +3,147 @@ package sources
 import (
 	"birnenlabs.com/go/lib/icy"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/golang/glog"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Icy source, SourceUrl can contain alternative stream urls separated by "|", they are used
+// in turns when the stream is reconnected.
 type icySource struct {
 }
 
+// Sent as a Song.Error when the stream was reconnected after the first title was found. Also sent when
+// the job ends while the stream is down or when the stream never produced a title.
+type ReconnectError struct {
+	// Error that stopped the stream.
+	Err error
+	// True if the stream was alive but no title was found for a long time, false if the stream was dead.
+	TitleTimeout bool
+	// Time without titles: since the stream died (or since the last title in case of title timeout)
+	// until the first title after reconnecting or until the end of the job.
+	Downtime time.Duration
+	// Number of connection attempts needed.
+	Attempts int
+	// Stream url used after reconnecting.
+	Url string
+}
+
 func newIcy() SongSource {
 	return &icySource{}
 }
 
 const timeout = time.Hour * 6
 
+var (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 5 * time.Minute
+)
+
 func (s *icySource) Start(ctx context.Context, conf SourceJob, song chan<- Song) error {
 	// channel accepted by the icy listener
 	title := make(chan string, 10)
 
 	// Thread that is listening to icy stream and pushing data into title channel
-	go s.startStreaming(title, song, conf)
+	go s.startStreaming(ctx, title, song, conf)
 	// Thread that is parsing title channel and putting it into songs channel.
 	go s.monitorTitleChannel(ctx, title, song, conf)
 	return nil
 }
 
-func (s *icySource) startStreaming(title chan string, song chan<- Song, conf SourceJob) {
+// Listens to the stream until the job timeout, reconnecting with backoff and rotating through the stream urls.
+func (s *icySource) startStreaming(ctx context.Context, title chan string, song chan<- Song, conf SourceJob) {
 	defer close(title)
-	err := icy.OpenWithTimeout(conf.SourceUrl, title, timeout)
-	glog.V(1).Infof("%v", err)
 
-	song <- Song{
-		Error: err,
+	urls := strings.Split(conf.SourceUrl, "|")
+	startTime := time.Now()
+	backoff := minReconnectBackoff
+
+	// Set when the stream stopped after at least one title was found, nil otherwise.
+	var reconnect *ReconnectError
+	var stoppedTime time.Time
+	everFound := false
+	for attempt := 0; ; attempt++ {
+		url := urls[attempt%len(urls)]
+		remaining := timeout - time.Since(startTime)
+		if reconnect != nil {
+			reconnect.Attempts++
+			reconnect.Url = url
+		}
+
+		var lastTitleTime time.Time
+		var lastTitleLock sync.Mutex
+		listenerTitle := make(chan string, 10)
+		forwarded := make(chan bool)
+		go func(r *ReconnectError) {
+			for t := range listenerTitle {
+				lastTitleLock.Lock()
+				lastTitleTime = time.Now()
+				lastTitleLock.Unlock()
+				if r != nil {
+					// First title after reconnecting.
+					r.Downtime = time.Since(stoppedTime)
+					glog.V(1).Infof("%v", r)
+					song <- Song{
+						Error: r,
+					}
+					r = nil
+				}
+				title <- t
+			}
+			forwarded <- true
+		}(reconnect)
+
+		method, err := icy.Listen(url, listenerTitle, remaining)
+		close(listenerTitle)
+		<-forwarded
+		glog.V(1).Infof("%v (%v)", err, method)
+
+		lastTitleLock.Lock()
+		titleFound := !lastTitleTime.IsZero()
+		lastTitleLock.Unlock()
+		everFound = everFound || titleFound
+
+		if errors.Is(err, icy.ErrJobTimeout) || time.Since(startTime) >= timeout || ctx.Err() != nil {
+			if !titleFound {
+				s.reportUnrecovered(song, reconnect, everFound, err, url, attempt, stoppedTime, startTime)
+			}
+			song <- Song{
+				Error: err,
+			}
+			return
+		}
+
+		if titleFound {
+			// Stream was working, start reconnecting from the beginning.
+			backoff = minReconnectBackoff
+			reconnect = &ReconnectError{
+				Err:          err,
+				TitleTimeout: errors.Is(err, icy.ErrTitleTimeout),
+			}
+			stoppedTime = time.Now()
+			if reconnect.TitleTimeout {
+				stoppedTime = lastTitleTime
+			}
+		}
+
+		glog.V(1).Infof("Stream %q stopped (%v), reconnecting in %v.", url, err, backoff)
+		select {
+		case <-ctx.Done():
+			s.reportUnrecovered(song, reconnect, everFound, err, url, attempt, stoppedTime, startTime)
+			song <- Song{
+				Error: ctx.Err(),
+			}
+			return
+		case <-time.After(backoff):
+		}
+		backoff = backoff * 2
+		if backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
 	}
 }
 
@@ -45,9 +154,6 @@ func (s *icySource) monitorTitleChannel(ctx context.Context, title <-chan string
 	for ok {
 		t, ok = <-title
 		if len(t) > 5 {
-			for substr, replacement := range conf.SubstrMap {
-				t = strings.Replace(t, substr, replacement, -1)
-			}
 			glog.V(2).Infof("Song found: %q", t)
 			song <- Song{
 				ArtistTitle: t,
@@ -56,3 +162,37 @@ func (s *icySource) monitorTitleChannel(ctx context.Context, title <-chan string
 		}
 	}
 }
+
+func (r *ReconnectError) Error() string {
+	reason := "stream dead"
+	if r.TitleTimeout {
+		reason = "title timeout"
+	}
+	return fmt.Sprintf("reconnected to %v after %v (%v, %d attempts): %v", r.Url, r.Downtime.Round(time.Second), reason, r.Attempts, r.Err)
+}
+
+func (r *ReconnectError) Unwrap() error {
+	return r.Err
+}
+
+// Sends the reconnect error when the job ends while the stream is down, downtime lasts until now. Stream
+// which never produced a title is down since the start of the job.
+func (s *icySource) reportUnrecovered(song chan<- Song, reconnect *ReconnectError, everFound bool, err error, url string, attempt int, stoppedTime time.Time, startTime time.Time) {
+	if reconnect == nil {
+		if everFound {
+			return
+		}
+		reconnect = &ReconnectError{
+			Err:          err,
+			TitleTimeout: errors.Is(err, icy.ErrTitleTimeout),
+			Attempts:     attempt,
+			Url:          url,
+		}
+		stoppedTime = startTime
+	}
+	reconnect.Downtime = time.Since(stoppedTime)
+	glog.V(1).Infof("Stream not recovered: %v", reconnect)
+	song <- Song{
+		Error: reconnect,
+	}
+}
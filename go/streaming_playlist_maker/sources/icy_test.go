@@ -0,0 +1,92 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Sets the backoff for the test, it is restored after the test.
+func setReconnectBackoff(t *testing.T, backoff time.Duration) {
+	old := minReconnectBackoff
+	minReconnectBackoff = backoff
+	t.Cleanup(func() { minReconnectBackoff = old })
+}
+
+func TestIcyReconnect(t *testing.T) {
+	setReconnectBackoff(t, time.Millisecond)
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stream with one title that ends immediately.
+		w.Header().Set("icy-metaint", "4")
+		w.Write([]byte("abcd\x02StreamTitle='Artist - Title';\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"))
+	}))
+	defer good.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan Song, 10)
+	s := newIcy()
+	err := s.Start(ctx, SourceJob{SourceUrl: dead.URL + "|" + good.URL}, ch)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	got := <-ch
+	if got.ArtistTitle != "Artist - Title" {
+		t.Errorf("got: %+v, want: Artist - Title", got)
+	}
+
+	got = <-ch
+	var reconnect *ReconnectError
+	if !errors.As(got.Error, &reconnect) {
+		t.Fatalf("got: %+v, want: ReconnectError", got)
+	}
+	if reconnect.TitleTimeout || reconnect.Attempts != 2 || reconnect.Url != good.URL || reconnect.Downtime <= 0 {
+		t.Errorf("got: %+v, want: stream dead, 2 attempts to %v", reconnect, good.URL)
+	}
+
+	got = <-ch
+	if got.ArtistTitle != "Artist - Title" {
+		t.Errorf("got: %+v, want: Artist - Title", got)
+	}
+
+	cancel()
+	for got = range ch {
+		if got.Error == nil && got.ArtistTitle != "Artist - Title" {
+			t.Errorf("got: %+v after cancel", got)
+		}
+	}
+}
+
+func TestIcyNeverRecovered(t *testing.T) {
+	setReconnectBackoff(t, time.Millisecond)
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan Song, 10)
+	s := newIcy()
+	err := s.Start(ctx, SourceJob{SourceUrl: dead.URL}, ch)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	var reconnect *ReconnectError
+	for got := range ch {
+		if got.Error == nil {
+			t.Errorf("got: %+v, want: only errors", got)
+		}
+		errors.As(got.Error, &reconnect)
+	}
+	if reconnect == nil || reconnect.Downtime < 50*time.Millisecond || reconnect.Url != dead.URL {
+		t.Errorf("got: %+v, want: ReconnectError with the downtime since the start", reconnect)
+	}
+}
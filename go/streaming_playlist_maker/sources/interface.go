@@ -9,11 +9,35 @@ import (
 type SourceJob struct {
 	SourceUrl  string
 	SourceType string
-	SubstrMap  map[string]string
+	// Substrings replaced in the songs of all the sources, applied as the first stage of the job pipeline.
+	SubstrMap map[string]string
+
+	// Used by the nowplaying source: paths of the artist and title in the JSON (e.g. "data.0.artist")
+	// or XML (e.g. "/playlist/track/artist") response. When ArtistPath is empty, TitlePath should point
+	// to the "Artist - Title" string.
+	ArtistPath string
+	TitlePath  string
+	// Used by the nowplaying source: interval between requests, 30 seconds by default.
+	PollIntervalSec int
+
+	// Used by the chart history sources ("url|start|end"): name of the checkpoint with the newest processed
+	// chart, the app sets it to the job name. Charts older than the checkpoint are skipped unless FullRescan or
+	// Offline is true.
+	CheckpointName string
+	FullRescan     bool
+	// Used by the web sources: pages are read only from the http cache, useful to replay sources for testing.
+	Offline bool
 }
 
 type Song struct {
+	// Song in the "Artist - Title" format, always set.
 	ArtistTitle string
+	// Artist and title, set only by sources that know the structure of the song.
+	Artist string
+	Title  string
+	// MusicBrainz recording id and ISRC, set by sources or enrichment that know them.
+	RecordingId string
+	Isrc        string
 	Error       error
 }
 
@@ -43,7 +67,21 @@ func Create(ctx context.Context, sourceType string) (SongSource, error) {
 		return newSpotifyLiked(), nil
 	case "spotify-merge":
 		return newSpotifyMerge(), nil
+	case "lastfm":
+		return newLastfm()
+	case "listenbrainz":
+		return newListenbrainz(), nil
+	case "nowplaying":
+		return newNowPlaying(), nil
 	default:
 		return nil, fmt.Errorf("Invalid source type definition (%v).", sourceType)
 	}
 }
+
+func newSong(artist string, title string) Song {
+	return Song{
+		ArtistTitle: artist + " - " + title,
+		Artist:      artist,
+		Title:       title,
+	}
+}
@@ -0,0 +1,262 @@
+package sources
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/lib/ratelimit"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lastfmApiUrl = "https://ws.audioscrobbler.com/2.0/"
+	// Maximum page size accepted by the user.getrecenttracks method.
+	lastfmPageSize = 200
+)
+
+// Source that reads scrobbles of the Last.fm user.
+//
+// Source url syntax: "mode:user" or "mode:user|2006-01-02|2006-01-02", where mode is:
+// - recent: songs in the order they were scrobbled (most recent first),
+// - top: songs sorted by the number of scrobbles in the date range.
+// When the date range is not set, last 30 days are used.
+type lastfmSource struct {
+	httpClient ratelimit.AnyClient
+	apiUrl     string
+	apiKey     string
+}
+
+type lastfmConfig struct {
+	ApiKey string
+}
+
+type lastfmRecentTracksResponse struct {
+	RecentTracks lastfmRecentTracks `json:"recenttracks"`
+}
+
+type lastfmRecentTracks struct {
+	// Single track object or array of tracks
+	Track json.RawMessage `json:"track"`
+	Attr  lastfmPaging    `json:"@attr"`
+}
+
+type lastfmTrack struct {
+	Name   string          `json:"name"`
+	Artist lastfmArtist    `json:"artist"`
+	Attr   lastfmTrackAttr `json:"@attr"`
+}
+
+type lastfmArtist struct {
+	Text string `json:"#text"`
+}
+
+type lastfmTrackAttr struct {
+	NowPlaying string `json:"nowplaying"`
+}
+
+type lastfmPaging struct {
+	Page       string `json:"page"`
+	TotalPages string `json:"totalPages"`
+}
+
+type lastfmCount struct {
+	song  Song
+	count int
+}
+
+// Api key is loaded here as the source is shared by the concurrently running jobs.
+func newLastfm() (SongSource, error) {
+	apiKey, err := loadLastfmApiKey()
+	if err != nil {
+		return nil, err
+	}
+	return &lastfmSource{
+		httpClient: ratelimit.New(&http.Client{}, time.Second),
+		apiUrl:     lastfmApiUrl,
+		apiKey:     apiKey,
+	}, nil
+}
+
+func (l *lastfmSource) Start(ctx context.Context, conf SourceJob, song chan<- Song) error {
+	mode, user, start, end, err := parseLastfmUrl(conf.SourceUrl, time.Now())
+	if err != nil {
+		return err
+	}
+
+	go l.listSongs(ctx, mode, user, start, end, song)
+	return nil
+}
+
+func (l *lastfmSource) listSongs(ctx context.Context, mode string, user string, start time.Time, end time.Time, song chan<- Song) {
+	defer close(song)
+
+	glog.V(1).Infof("Starting lastfm %v source for %v: %v-%v", mode, user, start, end)
+
+	var songs []Song
+	totalPages := 1
+	for page := 1; page <= totalPages; page++ {
+		r, err := l.getRecentTracks(user, start, end, page)
+		if err != nil {
+			song <- Song{
+				Error: err,
+			}
+			break
+		}
+		totalPages, _ = strconv.Atoi(r.RecentTracks.Attr.TotalPages)
+		tracks, err := r.RecentTracks.tracks()
+		if err != nil {
+			song <- Song{
+				Error: err,
+			}
+			break
+		}
+		for _, t := range tracks {
+			// Track that is currently playing is returned on every page and has no date.
+			if t.Attr.NowPlaying == "true" || len(t.Artist.Text) == 0 || len(t.Name) == 0 {
+				continue
+			}
+			songs = append(songs, newSong(t.Artist.Text, t.Name))
+		}
+	}
+	glog.V(2).Infof("Lastfm returned %v scrobbles for %v", len(songs), user)
+
+	if mode == "top" {
+		songs = sortByCount(songs)
+	} else {
+		songs = removeDuplicates(songs)
+	}
+
+	for _, s := range songs {
+		song <- s
+	}
+}
+
+func (l *lastfmSource) getRecentTracks(user string, start time.Time, end time.Time, page int) (*lastfmRecentTracksResponse, error) {
+	params := url.Values{}
+	params.Set("method", "user.getrecenttracks")
+	params.Set("user", user)
+	params.Set("from", strconv.FormatInt(start.Unix(), 10))
+	params.Set("to", strconv.FormatInt(end.Unix(), 10))
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(lastfmPageSize))
+	params.Set("api_key", l.apiKey)
+	params.Set("format", "json")
+
+	glog.V(2).Infof("Lastfm %v page %d.", user, page)
+	resp, err := l.httpClient.Get(l.apiUrl + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r = new(lastfmRecentTracksResponse)
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Returns the tracks of the page, Last.fm returns a single track as an object instead of an array.
+func (r *lastfmRecentTracks) tracks() ([]lastfmTrack, error) {
+	if len(r.Track) == 0 {
+		return nil, nil
+	}
+	var tracks []lastfmTrack
+	err := json.Unmarshal(r.Track, &tracks)
+	if err != nil {
+		var track lastfmTrack
+		err = json.Unmarshal(r.Track, &track)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse lastfm tracks: %v", err)
+		}
+		tracks = []lastfmTrack{track}
+	}
+	return tracks, nil
+}
+
+// Parses lastfm source url, returns mode, user and the date range. End date is inclusive.
+func parseLastfmUrl(sourceUrl string, now time.Time) (string, string, time.Time, time.Time, error) {
+	modeUser, start, end, err := parseHistoryUrl(sourceUrl)
+	if err != nil {
+		return "", "", time.Time{}, time.Time{}, err
+	}
+
+	parts := strings.SplitN(modeUser, ":", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return "", "", time.Time{}, time.Time{}, fmt.Errorf("Invalid lastfm source url: %q, expected mode:user.", sourceUrl)
+	}
+	if parts[0] != "recent" && parts[0] != "top" {
+		return "", "", time.Time{}, time.Time{}, fmt.Errorf("Invalid lastfm mode: %q, expected recent or top.", parts[0])
+	}
+
+	if start.IsZero() {
+		return parts[0], parts[1], now.Add(-defaultUserHistoryRange), now, nil
+	}
+	return parts[0], parts[1], start, end.AddDate(0, 0, 1), nil
+}
+
+func loadLastfmApiKey() (string, error) {
+	var config lastfmConfig
+	err := conf.LoadConfigFromJson("lastfm", &config)
+	if err != nil {
+		return "", err
+	}
+	if len(config.ApiKey) == 0 {
+		return "", fmt.Errorf("ApiKey not set in the lastfm config")
+	}
+	return config.ApiKey, nil
+}
+
+// Returns songs without duplicates, keeping the order of the first occurrence.
+func removeDuplicates(songs []Song) []Song {
+	seen := make(map[string]bool)
+	result := make([]Song, 0)
+	for _, s := range songs {
+		if !seen[s.ArtistTitle] {
+			seen[s.ArtistTitle] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Returns songs without duplicates, sorted by the number of occurrences.
+func sortByCount(songs []Song) []Song {
+	counts := make(map[string]*lastfmCount)
+	ordered := make([]*lastfmCount, 0)
+	for _, s := range songs {
+		c, ok := counts[s.ArtistTitle]
+		if !ok {
+			c = &lastfmCount{song: s}
+			counts[s.ArtistTitle] = c
+			ordered = append(ordered, c)
+		}
+		c.count++
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].count > ordered[j].count })
+
+	result := make([]Song, len(ordered))
+	for i, c := range ordered {
+		result[i] = c.song
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Fake lastfm API returning three pages of scrobbles.
+var lastfmPages = map[string]string{
+	"1": `{"recenttracks":{"track":[
+		{"artist":{"#text":"Now"},"name":"Playing","@attr":{"nowplaying":"true"}},
+		{"artist":{"#text":"Artist 1"},"name":"Title 1"},
+		{"artist":{"#text":"Artist 2"},"name":"Title 2"}
+	],"@attr":{"page":"1","totalPages":"2"}}}`,
+	"2": `{"recenttracks":{"track":[
+		{"artist":{"#text":"Artist 2"},"name":"Title 2"},
+		{"artist":{"#text":"Artist 3"},"name":"Title 3"}
+	],"@attr":{"page":"2","totalPages":"3"}}}`,
+	// Single track is returned as an object.
+	"3": `{"recenttracks":{"track":
+		{"artist":{"#text":"Artist 4"},"name":"Title 4"}
+	,"@attr":{"page":"3","totalPages":"3"}}}`,
+}
+
+func newFakeLastfm() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("method") != "user.getrecenttracks" || q.Get("user") != "me" || q.Get("api_key") != "key" {
+			w.WriteHeader(400)
+			return
+		}
+		page, ok := lastfmPages[q.Get("page")]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+}
+
+func readSongs(song <-chan Song) []Song {
+	result := make([]Song, 0)
+	for s := range song {
+		result = append(result, s)
+	}
+	return result
+}
+
+func TestLastfm(t *testing.T) {
+	server := newFakeLastfm()
+	defer server.Close()
+
+	for _, test := range []struct {
+		url  string
+		want []string
+	}{
+		{
+			url:  "recent:me|2020-01-01|2020-01-31",
+			want: []string{"Artist 1 - Title 1", "Artist 2 - Title 2", "Artist 3 - Title 3", "Artist 4 - Title 4"},
+		},
+		{
+			url:  "top:me",
+			want: []string{"Artist 2 - Title 2", "Artist 1 - Title 1", "Artist 3 - Title 3", "Artist 4 - Title 4"},
+		},
+	} {
+		l := &lastfmSource{
+			httpClient: &http.Client{},
+			apiUrl:     server.URL,
+			apiKey:     "key",
+		}
+		ch := make(chan Song, 10)
+		err := l.Start(context.Background(), SourceJob{SourceUrl: test.url}, ch)
+		if err != nil {
+			t.Fatalf("Start(%q): %v", test.url, err)
+		}
+		got := readSongs(ch)
+		if len(got) != len(test.want) {
+			t.Fatalf("%q got: %v, want: %v", test.url, got, test.want)
+		}
+		for i := range got {
+			if got[i].Error != nil || got[i].ArtistTitle != test.want[i] {
+				t.Errorf("%q got: %v, want: %v", test.url, got[i], test.want[i])
+			}
+		}
+		if got[0].Artist == "" || got[0].Title == "" {
+			t.Errorf("%q artist and title should be set: %+v", test.url, got[0])
+		}
+	}
+}
+
+func TestLastfmError(t *testing.T) {
+	server := newFakeLastfm()
+	defer server.Close()
+
+	l := &lastfmSource{
+		httpClient: &http.Client{},
+		apiUrl:     server.URL,
+		apiKey:     "wrong",
+	}
+	ch := make(chan Song, 10)
+	err := l.Start(context.Background(), SourceJob{SourceUrl: "recent:other"}, ch)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	got := readSongs(ch)
+	if len(got) != 1 || got[0].Error == nil {
+		t.Errorf("got: %v, want: one error", got)
+	}
+}
+
+func TestParseLastfmUrl(t *testing.T) {
+	now := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	mode, user, start, end, err := parseLastfmUrl("top:someone|2020-01-01|2020-01-31", now)
+	if err != nil || mode != "top" || user != "someone" || !start.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(now) {
+		t.Errorf("got: %v %v %v %v %v", mode, user, start, end, err)
+	}
+
+	_, _, start, end, err = parseLastfmUrl("recent:someone", now)
+	if err != nil || !end.Equal(now) || !start.Equal(now.Add(-defaultUserHistoryRange)) {
+		t.Errorf("got: %v %v %v", start, end, err)
+	}
+
+	for _, url := range []string{"someone", "other:someone", "top:", "top:someone|2020-01-01", "top:someone|x|y"} {
+		_, _, _, _, err = parseLastfmUrl(url, now)
+		if err == nil {
+			t.Errorf("parseLastfmUrl(%q) got: nil, want: error", url)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package sources
+
+import (
+	"birnenlabs.com/go/lib/ratelimit"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	listenbrainzApiUrl   = "https://api.listenbrainz.org/1"
+	listenbrainzPageSize = 100
+	recordingUrlPrefix   = "https://musicbrainz.org/recording/"
+)
+
+// Source that reads ListenBrainz listens or playlists.
+//
+// Source url syntax:
+// - "listens:user" or "listens:user|2006-01-02|2006-01-02" - listens of the user (most recent first), last 30 days by default,
+// - "playlist:mbid" - tracks of the playlist.
+type listenbrainzSource struct {
+	httpClient ratelimit.AnyClient
+	apiUrl     string
+}
+
+type listenbrainzListensResponse struct {
+	Payload listenbrainzPayload `json:"payload"`
+}
+
+type listenbrainzPayload struct {
+	Count   int                  `json:"count"`
+	Listens []listenbrainzListen `json:"listens"`
+}
+
+type listenbrainzListen struct {
+	ListenedAt    int64                     `json:"listened_at"`
+	TrackMetadata listenbrainzTrackMetadata `json:"track_metadata"`
+}
+
+type listenbrainzTrackMetadata struct {
+	ArtistName     string                     `json:"artist_name"`
+	TrackName      string                     `json:"track_name"`
+	AdditionalInfo listenbrainzAdditionalInfo `json:"additional_info"`
+	MbidMapping    listenbrainzAdditionalInfo `json:"mbid_mapping"`
+}
+
+type listenbrainzAdditionalInfo struct {
+	RecordingMbid string `json:"recording_mbid"`
+	Isrc          string `json:"isrc"`
+}
+
+type listenbrainzPlaylistResponse struct {
+	Playlist listenbrainzPlaylist `json:"playlist"`
+}
+
+type listenbrainzPlaylist struct {
+	Title string                      `json:"title"`
+	Track []listenbrainzPlaylistTrack `json:"track"`
+}
+
+type listenbrainzPlaylistTrack struct {
+	Creator    string   `json:"creator"`
+	Title      string   `json:"title"`
+	Identifier []string `json:"identifier"`
+}
+
+func newListenbrainz() SongSource {
+	return &listenbrainzSource{
+		httpClient: ratelimit.New(&http.Client{}, time.Second),
+		apiUrl:     listenbrainzApiUrl,
+	}
+}
+
+func (l *listenbrainzSource) Start(ctx context.Context, conf SourceJob, song chan<- Song) error {
+	modeId, start, end, err := parseHistoryUrl(conf.SourceUrl)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.SplitN(modeId, ":", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return fmt.Errorf("Invalid listenbrainz source url: %q, expected listens:user or playlist:mbid.", conf.SourceUrl)
+	}
+
+	switch parts[0] {
+	case "listens":
+		if start.IsZero() {
+			end = time.Now()
+			start = end.Add(-defaultUserHistoryRange)
+		} else {
+			end = end.AddDate(0, 0, 1)
+		}
+		go l.listListens(parts[1], start, end, song)
+	case "playlist":
+		go l.listPlaylist(parts[1], song)
+	default:
+		return fmt.Errorf("Invalid listenbrainz mode: %q, expected listens or playlist.", parts[0])
+	}
+	return nil
+}
+
+func (l *listenbrainzSource) listListens(user string, start time.Time, end time.Time, song chan<- Song) {
+	defer close(song)
+
+	glog.V(1).Infof("Starting listenbrainz source for %v: %v-%v", user, start, end)
+
+	// API does not accept min_ts together with max_ts, so the listens are read backwards until start.
+	songs := make([]Song, 0)
+	maxTs := end.Unix()
+	for done := false; !done; {
+		var r listenbrainzListensResponse
+		err := l.get(fmt.Sprintf("%s/user/%s/listens?count=%d&max_ts=%d", l.apiUrl, url.PathEscape(user), listenbrainzPageSize, maxTs), &r)
+		if err != nil {
+			song <- Song{
+				Error: err,
+			}
+			break
+		}
+		previousMaxTs := maxTs
+		for _, listen := range r.Payload.Listens {
+			if listen.ListenedAt < start.Unix() {
+				done = true
+				break
+			}
+			if listen.ListenedAt < maxTs {
+				maxTs = listen.ListenedAt
+			}
+			if s, ok := listen.TrackMetadata.song(); ok {
+				songs = append(songs, s)
+			}
+		}
+		done = done || len(r.Payload.Listens) < listenbrainzPageSize || maxTs == previousMaxTs
+	}
+	glog.V(2).Infof("Listenbrainz returned %v listens for %v", len(songs), user)
+
+	for _, s := range removeDuplicates(songs) {
+		song <- s
+	}
+}
+
+func (l *listenbrainzSource) listPlaylist(mbid string, song chan<- Song) {
+	defer close(song)
+
+	var r listenbrainzPlaylistResponse
+	err := l.get(fmt.Sprintf("%s/playlist/%s", l.apiUrl, url.PathEscape(mbid)), &r)
+	if err != nil {
+		song <- Song{
+			Error: err,
+		}
+		return
+	}
+
+	glog.V(2).Infof("Listenbrainz playlist %q returned %v tracks", r.Playlist.Title, len(r.Playlist.Track))
+	for _, t := range r.Playlist.Track {
+		if len(t.Creator) == 0 || len(t.Title) == 0 {
+			continue
+		}
+		s := newSong(t.Creator, t.Title)
+		for _, id := range t.Identifier {
+			if strings.HasPrefix(id, recordingUrlPrefix) {
+				s.RecordingId = strings.TrimPrefix(id, recordingUrlPrefix)
+			}
+		}
+		song <- s
+	}
+}
+
+func (l *listenbrainzSource) get(uri string, object interface{}) error {
+	glog.V(2).Infof("Listenbrainz url: %q.", uri)
+	resp, err := l.httpClient.Get(uri)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, object)
+}
+
+func (t *listenbrainzTrackMetadata) song() (Song, bool) {
+	if len(t.ArtistName) == 0 || len(t.TrackName) == 0 {
+		return Song{}, false
+	}
+
+	s := newSong(t.ArtistName, t.TrackName)
+	s.RecordingId = t.MbidMapping.RecordingMbid
+	if len(s.RecordingId) == 0 {
+		s.RecordingId = t.AdditionalInfo.RecordingMbid
+	}
+	s.Isrc = t.AdditionalInfo.Isrc
+	return s, true
+}
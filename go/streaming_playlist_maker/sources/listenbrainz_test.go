@@ -0,0 +1,165 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const listenbrainzPlaylistJson = `{"playlist":{"title":"Test","track":[
+	{"creator":"Artist 1","title":"Title 1","identifier":["https://musicbrainz.org/recording/mbid-1"]},
+	{"creator":"","title":"No artist"},
+	{"creator":"Artist 2","title":"Title 2"}
+]}}`
+
+const listenbrainzListensJson = `{"payload":{"count":3,"listens":[
+	{"listened_at":300,"track_metadata":{"artist_name":"Artist 1","track_name":"Title 1","mbid_mapping":{"recording_mbid":"mbid-1"}}},
+	{"listened_at":200,"track_metadata":{"artist_name":"Artist 1","track_name":"Title 1"}},
+	{"listened_at":100,"track_metadata":{"artist_name":"Artist 2","track_name":"Title 2","additional_info":{"isrc":"ISRC2"}}}
+]}}`
+
+func newFakeListenbrainz() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/playlist/test-mbid":
+			fmt.Fprint(w, listenbrainzPlaylistJson)
+		case r.URL.Path == "/user/me/listens":
+			fmt.Fprint(w, listenbrainzListensJson)
+		case r.URL.Path == "/user/recent/listens":
+			// Same listens during the last hour, within the default range.
+			listens := listenbrainzListensJson
+			for _, ts := range []int64{300, 200, 100} {
+				listens = strings.Replace(listens, fmt.Sprintf(`"listened_at":%d`, ts), fmt.Sprintf(`"listened_at":%d`, time.Now().Unix()-3600+ts), 1)
+			}
+			fmt.Fprint(w, listens)
+		case strings.HasPrefix(r.URL.Path, "/user/"):
+			fmt.Fprint(w, `{"payload":{"count":0,"listens":[]}}`)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+}
+
+func TestListenbrainz(t *testing.T) {
+	server := newFakeListenbrainz()
+	defer server.Close()
+
+	for _, test := range []struct {
+		url           string
+		want          []string
+		wantRecording string
+	}{
+		{
+			url:           "playlist:test-mbid",
+			want:          []string{"Artist 1 - Title 1", "Artist 2 - Title 2"},
+			wantRecording: "mbid-1",
+		},
+		{
+			url:           "listens:me|1970-01-01|1970-01-01",
+			want:          []string{"Artist 1 - Title 1", "Artist 2 - Title 2"},
+			wantRecording: "mbid-1",
+		},
+		{
+			url:           "listens:recent",
+			want:          []string{"Artist 1 - Title 1", "Artist 2 - Title 2"},
+			wantRecording: "mbid-1",
+		},
+		{
+			// Listens older than the default range are skipped.
+			url:  "listens:me",
+			want: []string{},
+		},
+		{
+			url:  "listens:other|2020-01-01|2020-01-31",
+			want: []string{},
+		},
+	} {
+		l := &listenbrainzSource{
+			httpClient: &http.Client{},
+			apiUrl:     server.URL,
+		}
+		ch := make(chan Song, 10)
+		err := l.Start(context.Background(), SourceJob{SourceUrl: test.url}, ch)
+		if err != nil {
+			t.Fatalf("Start(%q): %v", test.url, err)
+		}
+		got := readSongs(ch)
+		if len(got) != len(test.want) {
+			t.Fatalf("%q got: %v, want: %v", test.url, got, test.want)
+		}
+		for i := range got {
+			if got[i].Error != nil || got[i].ArtistTitle != test.want[i] {
+				t.Errorf("%q got: %v, want: %v", test.url, got[i], test.want[i])
+			}
+		}
+		if len(got) > 0 && got[0].RecordingId != test.wantRecording {
+			t.Errorf("%q recording got: %q, want: %q", test.url, got[0].RecordingId, test.wantRecording)
+		}
+	}
+}
+
+func TestListenbrainzInvalidUrl(t *testing.T) {
+	for _, url := range []string{"me", "other:me", "playlist:", "listens:me|2020-01-01"} {
+		l := &listenbrainzSource{}
+		err := l.Start(context.Background(), SourceJob{SourceUrl: url}, make(chan Song))
+		if err == nil {
+			t.Errorf("Start(%q) got: nil, want: error", url)
+		}
+	}
+}
+
+func TestListenbrainzPaging(t *testing.T) {
+	// Listen every 2 hours between 2019-12-18 and 2020-01-12, newest first.
+	listens := make([]string, 0)
+	for ts := time.Date(2020, 1, 12, 0, 0, 0, 0, time.UTC); ts.After(time.Date(2019, 12, 18, 0, 0, 0, 0, time.UTC)); ts = ts.Add(-2 * time.Hour) {
+		listens = append(listens, fmt.Sprintf(`{"listened_at":%d,"track_metadata":{"artist_name":"Artist","track_name":"Title %d"}}`, ts.Unix(), ts.Unix()))
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		q := r.URL.Query()
+		if q.Get("min_ts") != "" && q.Get("max_ts") != "" {
+			w.WriteHeader(400)
+			return
+		}
+		maxTs, _ := strconv.ParseInt(q.Get("max_ts"), 10, 64)
+		count, _ := strconv.Atoi(q.Get("count"))
+		page := make([]string, 0)
+		for _, l := range listens {
+			var ts int64
+			fmt.Sscanf(l, `{"listened_at":%d`, &ts)
+			if ts < maxTs && len(page) < count {
+				page = append(page, l)
+			}
+		}
+		fmt.Fprintf(w, `{"payload":{"count":%d,"listens":[%s]}}`, len(page), strings.Join(page, ","))
+	}))
+	defer server.Close()
+
+	l := &listenbrainzSource{
+		httpClient: &http.Client{},
+		apiUrl:     server.URL,
+	}
+	ch := make(chan Song, 1000)
+	err := l.Start(context.Background(), SourceJob{SourceUrl: "listens:me|2020-01-01|2020-01-10"}, ch)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	got := readSongs(ch)
+	// 10 days with 12 listens per day, end date is included.
+	if len(got) != 120 || got[0].Error != nil {
+		t.Fatalf("Listens got: %d %v, want: 120", len(got), got[0])
+	}
+	if want := fmt.Sprintf("Artist - Title %d", time.Date(2020, 1, 10, 22, 0, 0, 0, time.UTC).Unix()); got[0].ArtistTitle != want {
+		t.Errorf("First listen got: %q, want: %q", got[0].ArtistTitle, want)
+	}
+	// Second page reaches the start.
+	if requests != 2 {
+		t.Errorf("Requests got: %d, want: 2", requests)
+	}
+}
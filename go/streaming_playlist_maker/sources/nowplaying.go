@@ -0,0 +1,314 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/golang/glog"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPollInterval = 30 * time.Second
+	// Source is stopped after that many consecutive errors.
+	maxPollErrors = 10
+)
+
+// Source that polls now-playing JSON or XML endpoint published by the radio station.
+// SourceUrl is the endpoint, ArtistPath and TitlePath define where to find the song in the response.
+type nowPlayingSource struct {
+	httpClient *http.Client
+}
+
+// Minimal XML tree used to evaluate simple XPath expressions.
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	text     string
+	children []*xmlNode
+}
+
+func newNowPlaying() SongSource {
+	return &nowPlayingSource{
+		httpClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (s *nowPlayingSource) Start(ctx context.Context, conf SourceJob, song chan<- Song) error {
+	if len(conf.TitlePath) == 0 {
+		return fmt.Errorf("TitlePath not set")
+	}
+
+	interval := defaultPollInterval
+	if conf.PollIntervalSec > 0 {
+		interval = time.Duration(conf.PollIntervalSec) * time.Second
+	}
+
+	go s.poll(ctx, conf, interval, song)
+	return nil
+}
+
+func (s *nowPlayingSource) poll(ctx context.Context, conf SourceJob, interval time.Duration, song chan<- Song) {
+	defer close(song)
+
+	glog.V(1).Infof("Starting now playing source %q every %v", conf.SourceUrl, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastTitle := ""
+	errors := 0
+	startTime := time.Now()
+	for {
+		found, err := s.fetchSong(conf)
+		if err != nil {
+			errors++
+			glog.V(1).Infof("Now playing error %d: %v", errors, err)
+			song <- Song{
+				Error: err,
+			}
+			if errors >= maxPollErrors {
+				return
+			}
+		} else {
+			errors = 0
+			if len(found.ArtistTitle) > 0 && found.ArtistTitle != lastTitle {
+				glog.V(2).Infof("Song found: %q", found.ArtistTitle)
+				song <- found
+				lastTitle = found.ArtistTitle
+			}
+		}
+
+		if startTime.Add(timeout).Before(time.Now()) {
+			song <- Song{
+				Error: fmt.Errorf("job timeout, last title: %v", lastTitle),
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *nowPlayingSource) fetchSong(conf SourceJob) (Song, error) {
+	resp, err := s.httpClient.Get(conf.SourceUrl)
+	if err != nil {
+		return Song{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return Song{}, fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Song{}, err
+	}
+
+	title, err := extractValue(body, conf.TitlePath)
+	if err != nil {
+		return Song{}, err
+	}
+	title = strings.TrimSpace(title)
+
+	if len(conf.ArtistPath) == 0 {
+		return Song{ArtistTitle: title}, nil
+	}
+
+	artist, err := extractValue(body, conf.ArtistPath)
+	if err != nil {
+		return Song{}, err
+	}
+	artist = strings.TrimSpace(artist)
+
+	if len(artist) == 0 || len(title) == 0 {
+		// Nothing is playing (e.g. news or ads).
+		return Song{}, nil
+	}
+	return newSong(artist, title), nil
+}
+
+// Returns the value from the JSON or XML document. Paths starting with "/" are treated as XPath.
+func extractValue(body []byte, path string) (string, error) {
+	if strings.HasPrefix(path, "/") {
+		return extractXmlValue(body, path)
+	}
+	return extractJsonValue(body, path)
+}
+
+// Returns the value from the JSON document. Path elements are separated by dots,
+// numbers are used as array indexes, e.g. "data.0.artist".
+func extractJsonValue(body []byte, path string) (string, error) {
+	var value interface{}
+	err := json.Unmarshal(body, &value)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[p]
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", fmt.Errorf("invalid array index %q in %q", p, path)
+			}
+			value = v[i]
+		default:
+			return "", fmt.Errorf("%q not found in %q", p, path)
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}:
+		// Array of artists
+		result := make([]string, 0)
+		for _, a := range v {
+			result = append(result, fmt.Sprint(a))
+		}
+		return strings.Join(result, ", "), nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("%q is not a value", path)
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// Returns the value from the XML document. Supported XPath subset: absolute paths with
+// optional 1-based indexes and the attribute as a last element, e.g. "/playlist/track[1]/@artist".
+// Path starting with "//" matches the first element anywhere in the document.
+func extractXmlValue(body []byte, path string) (string, error) {
+	root, err := parseXml(body)
+	if err != nil {
+		return "", err
+	}
+
+	node := root
+	steps := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if strings.HasPrefix(path, "//") {
+		steps = steps[1:]
+		if len(steps) == 0 {
+			return "", fmt.Errorf("invalid path %q", path)
+		}
+		name, _ := parseXmlStep(steps[0])
+		node = root.findDescendant(name)
+		if node == nil {
+			return "", fmt.Errorf("%q not found in %q", steps[0], path)
+		}
+		node = &xmlNode{children: []*xmlNode{node}}
+	}
+
+	for i, step := range steps {
+		if strings.HasPrefix(step, "@") {
+			if i != len(steps)-1 {
+				return "", fmt.Errorf("attribute %q should be the last element of %q", step, path)
+			}
+			return node.attr(step[1:]), nil
+		}
+		if step == "text()" {
+			break
+		}
+
+		name, index := parseXmlStep(step)
+		node = node.child(name, index)
+		if node == nil {
+			return "", fmt.Errorf("%q not found in %q", step, path)
+		}
+	}
+	return node.text, nil
+}
+
+// Parses "name[index]" XPath step, index is 1-based.
+func parseXmlStep(step string) (string, int) {
+	idx := strings.Index(step, "[")
+	if idx == -1 || !strings.HasSuffix(step, "]") {
+		return step, 1
+	}
+	index, err := strconv.Atoi(step[idx+1 : len(step)-1])
+	if err != nil {
+		return step, 1
+	}
+	return step[:idx], index
+}
+
+func parseXml(body []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	// Radio stations are using various encodings, let's treat everything as utf-8.
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		current := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: t.Attr}
+			current.children = append(current.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			current.text += string(t)
+		}
+	}
+	return root, nil
+}
+
+func (n *xmlNode) child(name string, index int) *xmlNode {
+	for _, c := range n.children {
+		if c.name == name || name == "*" {
+			index--
+			if index == 0 {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func (n *xmlNode) findDescendant(name string) *xmlNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+		if d := c.findDescendant(name); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
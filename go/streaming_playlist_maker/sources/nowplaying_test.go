@@ -0,0 +1,105 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const nowPlayingJson = `{"data":[{"song":{"artist":"Artist","title":"Title","artists":["A1","A2"],"year":2020}}]}`
+
+const nowPlayingXml = `<?xml version="1.0" encoding="ISO-8859-2"?>
+<playlist>
+	<track id="1" artist="Old artist"><artist>Old</artist><title>Old title</title></track>
+	<track id="2" artist="New artist"><artist>New</artist><title>New title</title></track>
+</playlist>`
+
+type extractTest struct {
+	body string
+	path string
+	want string
+}
+
+func TestExtractValue(t *testing.T) {
+	for _, test := range []extractTest{
+		{body: nowPlayingJson, path: "data.0.song.artist", want: "Artist"},
+		{body: nowPlayingJson, path: "data.0.song.artists", want: "A1, A2"},
+		{body: nowPlayingJson, path: "data.0.song.year", want: "2020"},
+		{body: nowPlayingJson, path: "data.0.song.missing", want: ""},
+		{body: nowPlayingXml, path: "/playlist/track/artist", want: "Old"},
+		{body: nowPlayingXml, path: "/playlist/track[2]/title", want: "New title"},
+		{body: nowPlayingXml, path: "/playlist/track[2]/@artist", want: "New artist"},
+		{body: nowPlayingXml, path: "/playlist/track[2]/title/text()", want: "New title"},
+		{body: nowPlayingXml, path: "//title", want: "Old title"},
+	} {
+		got, err := extractValue([]byte(test.body), test.path)
+		if err != nil || got != test.want {
+			t.Errorf("extractValue(%q) got: %q, %v, want: %q", test.path, got, err, test.want)
+		}
+	}
+}
+
+func TestExtractValue_errors(t *testing.T) {
+	for _, test := range []extractTest{
+		{body: nowPlayingJson, path: "data.1.song"},
+		{body: nowPlayingJson, path: "data.x.song"},
+		{body: nowPlayingJson, path: "data.0.song"},
+		{body: nowPlayingJson, path: "data.0.song.artist.name"},
+		{body: "not json", path: "data"},
+		{body: nowPlayingXml, path: "/playlist/track[3]/title"},
+		{body: nowPlayingXml, path: "/playlist/@id/title"},
+		{body: nowPlayingXml, path: "//missing"},
+	} {
+		got, err := extractValue([]byte(test.body), test.path)
+		if err == nil {
+			t.Errorf("extractValue(%q) got: %q, want: error", test.path, got)
+		}
+	}
+}
+
+func TestNowPlaying(t *testing.T) {
+	// Server returns every title twice, duplicates should be ignored.
+	titles := []string{"A - 1", "A - 1", "B - 2", "B - 2", "A - 1"}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests < len(titles) {
+			fmt.Fprintf(w, `{"now":%q}`, titles[requests])
+		} else {
+			w.WriteHeader(500)
+		}
+		requests++
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &nowPlayingSource{httpClient: &http.Client{}}
+	ch := make(chan Song, 10)
+	go s.poll(ctx, SourceJob{
+		SourceUrl: server.URL,
+		TitlePath: "now",
+	}, time.Millisecond, ch)
+
+	want := []string{"A - 1", "B - 2", "A - 1"}
+	for _, w := range want {
+		got := <-ch
+		if got.ArtistTitle != w {
+			t.Errorf("got: %+v, want: %q", got, w)
+		}
+	}
+
+	// Then errors are returned until maxPollErrors is reached and channel is closed.
+	errors := 0
+	for s := range ch {
+		if s.Error == nil {
+			t.Errorf("got: %+v, want: error", s)
+		}
+		errors++
+	}
+	if errors != maxPollErrors {
+		t.Errorf("errors got: %v, want: %v", errors, maxPollErrors)
+	}
+}
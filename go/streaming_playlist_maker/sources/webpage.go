@@ -1,6 +1,7 @@
 package sources
 
 import (
+	"birnenlabs.com/go/lib/httpcache"
 	"birnenlabs.com/go/lib/ratelimit"
 	"context"
 	"fmt"
@@ -11,6 +12,12 @@ import (
 	"time"
 )
 
+// Date range used by the user history sources when the source url does not specify it.
+const defaultUserHistoryRange = 30 * 24 * time.Hour
+
+// Charts older than that are not revised anymore, their cached pages are used without contacting the server.
+const immutableChartAge = 14 * 24 * time.Hour
+
 type webSource struct {
 	httpClient      ratelimit.AnyClient
 	findSongsInHtml func(line string) []string
@@ -23,10 +30,19 @@ type webSource struct {
 }
 
 func newWebSource(findSongsInHtml func(html string) []string, generateHistoryUrl func(urlBase string, t time.Time) (string, time.Time)) *webSource {
+	// Rate limiter is behind the cache, so cached pages are returned immediately.
+	var httpClient ratelimit.AnyClient = ratelimit.New(&http.Client{}, time.Second*3)
+	cache, err := httpcache.NewInCacheDir(httpClient, "streaming-playlist-maker")
+	if err != nil {
+		glog.Warningf("Could not create http cache: %v", err)
+	} else {
+		httpClient = cache
+	}
+
 	return &webSource{
 		findSongsInHtml:    findSongsInHtml,
 		generateHistoryUrl: generateHistoryUrl,
-		httpClient:         ratelimit.New(&http.Client{}, time.Second*3),
+		httpClient:         httpClient,
 		Delimiter:          "\n",
 		SongLimit:          20000000,
 	}
@@ -41,32 +57,47 @@ func (w *webSource) Start(ctx context.Context, conf SourceJob, song chan<- Song)
 		return fmt.Errorf("generateHistoryUrl not set")
 	}
 
-	urlParts := strings.Split(conf.SourceUrl, "|")
+	url, start, end, err := parseHistoryUrl(conf.SourceUrl)
+	if err != nil {
+		return err
+	}
+
+	if start.IsZero() {
+		go w.doStart(song, conf, url)
+	} else {
+		go w.doStartHistory(song, conf, url, start, end)
+	}
+	return nil
+}
+
+// Parses the "url|start|end" source url, where start and end are dates in the 2006-01-02 format.
+// When the source url does not contain the date range, zero start and end times are returned.
+func parseHistoryUrl(sourceUrl string) (string, time.Time, time.Time, error) {
+	urlParts := strings.Split(sourceUrl, "|")
 
 	if len(urlParts) == 1 {
-		go w.doStart(song, conf.SourceUrl)
+		return sourceUrl, time.Time{}, time.Time{}, nil
 	} else if len(urlParts) == 3 {
 		start, err := time.Parse("2006-01-02", urlParts[1])
 		if err != nil {
-			return err
+			return "", time.Time{}, time.Time{}, err
 		}
 		end, err := time.Parse("2006-01-02", urlParts[2])
 		if err != nil {
-			return err
+			return "", time.Time{}, time.Time{}, err
 		}
-		go w.doStartHistory(song, urlParts[0], start, end)
+		return urlParts[0], start, end, nil
 	} else {
-		return fmt.Errorf("Too many url parts.")
+		return "", time.Time{}, time.Time{}, fmt.Errorf("Too many url parts.")
 	}
-	return nil
 }
 
-func (w *webSource) doStart(song chan<- Song, url string) {
+func (w *webSource) doStart(song chan<- Song, conf SourceJob, url string) {
 	defer close(song)
 
 	glog.V(3).Infof("Starting web source with url: %v", url)
 
-	songs, err := w.findSongsInPage(url)
+	songs, err := w.findSongsInPage(conf, url, false)
 	if err != nil {
 		song <- Song{
 			Error: err,
@@ -80,39 +111,121 @@ func (w *webSource) doStart(song chan<- Song, url string) {
 	}
 }
 
-func (w *webSource) doStartHistory(song chan<- Song, urlBase string, start time.Time, end time.Time) {
+// Walks the history from end to start. When conf.CheckpointName is set the newest fetched chart date is saved
+// and the next run stops at it, so only new charts are fetched (unless conf.FullRescan is set). Failed charts are
+// saved with the checkpoint and fetched again by the next runs. Checkpoint is not used in the offline mode, all the
+// cached charts are replayed.
+func (w *webSource) doStartHistory(song chan<- Song, conf SourceJob, urlBase string, start time.Time, end time.Time) {
 	defer close(song)
 
 	glog.V(1).Infof("Starting historical web source %v-%v with url: %v", start, end, urlBase)
 
-	t := end
-	for !t.Before(start) {
-		url, nextTs := w.generateHistoryUrl(urlBase, t)
-		songs, err := w.findSongsInPage(url)
+	// Charts up to the checkpoint (inclusive) were already processed.
+	var checkpoint time.Time
+	checkpointStart := start
+	var retry []FailedChart
+	if len(conf.CheckpointName) > 0 && !conf.FullRescan && !conf.Offline {
+		cp, ok, err := getCheckpoint(conf.CheckpointName)
 		if err != nil {
 			song <- Song{
 				Error: err,
 			}
+			return
 		}
-		glog.V(2).Infof("%v returned %v songs", url, len(songs))
-		for _, s := range songs {
-			song <- Song{
-				ArtistTitle: s,
-				Error:       nil,
+		if ok && cp.UrlBase == urlBase && !start.Before(cp.Start) {
+			glog.V(1).Infof("Resuming %v from checkpoint %v, failed charts: %v", conf.CheckpointName, cp.Last, len(cp.Failed))
+			checkpoint = cp.Last
+			checkpointStart = cp.Start
+			retry = cp.Failed
+		}
+	}
+
+	now := time.Now()
+	var newest time.Time
+	failed := make([]FailedChart, 0)
+	t := end
+	for !t.Before(start) && (checkpoint.IsZero() || t.After(checkpoint)) {
+		url, nextTs := w.generateHistoryUrl(urlBase, t)
+		if t.After(now) {
+			// Chart not published yet, it will be fetched by the next run.
+			glog.V(2).Infof("Skipping future chart %v", url)
+		} else {
+			// Failed charts are retried from the failed list, the checkpoint moves past them.
+			if newest.IsZero() {
+				newest = t
+			}
+			if !w.sendChart(song, conf, url, now.Sub(t) > immutableChartAge) {
+				failed = append(failed, FailedChart{Date: t, Attempts: 1})
 			}
 		}
 		if !nextTs.Before(t) {
 			song <- Song{
 				Error: fmt.Errorf("Timestamp returned by generateHistoryUrl (%v) not before current (%v)", nextTs, t),
 			}
-			break
+			return
 		}
 		t = nextTs
 	}
+
+	// Charts that failed in the previous runs and are still in the range, charts after the checkpoint
+	// were already fetched by the walk.
+	for _, f := range retry {
+		if f.Date.Before(start) || f.Date.After(end) || f.Date.After(checkpoint) {
+			continue
+		}
+		url, _ := w.generateHistoryUrl(urlBase, f.Date)
+		if w.sendChart(song, conf, url, true) {
+			continue
+		}
+		f.Attempts++
+		if f.Attempts >= maxChartAttempts {
+			song <- Song{
+				Error: fmt.Errorf("Chart %v failed %d times, skipping it", url, f.Attempts),
+			}
+			continue
+		}
+		failed = append(failed, f)
+	}
+
+	if newest.IsZero() {
+		newest = checkpoint
+	}
+	if len(conf.CheckpointName) > 0 && !conf.Offline && !newest.IsZero() {
+		err := setCheckpoint(conf.CheckpointName, Checkpoint{
+			UrlBase: urlBase,
+			Start:   checkpointStart,
+			Last:    newest,
+			Failed:  failed,
+		})
+		if err != nil {
+			song <- Song{
+				Error: fmt.Errorf("Could not save checkpoint: %v", err),
+			}
+		}
+	}
 }
 
-func (w *webSource) findSongsInPage(url string) ([]string, error) {
-	resp, err := w.httpClient.Get(url)
+// Sends the songs of the chart, returns false if the chart could not be fetched.
+func (w *webSource) sendChart(song chan<- Song, conf SourceJob, url string, immutable bool) bool {
+	songs, err := w.findSongsInPage(conf, url, immutable)
+	if err != nil {
+		song <- Song{
+			Error: err,
+		}
+		return false
+	}
+	glog.V(2).Infof("%v returned %v songs", url, len(songs))
+	for _, s := range songs {
+		song <- Song{
+			ArtistTitle: s,
+			Error:       nil,
+		}
+	}
+	return true
+}
+
+func (w *webSource) findSongsInPage(conf SourceJob, url string, immutable bool) ([]string, error) {
+	resp, err := w.get(conf, url, immutable)
 	if err != nil {
 		return nil, err
 	}
@@ -139,3 +252,19 @@ func (w *webSource) findSongsInPage(url string) ([]string, error) {
 	}
 	return result, nil
 }
+
+// Downloads the page, when the http cache is used immutable pages are not revalidated
+// and in the offline mode only the cached pages are returned.
+func (w *webSource) get(conf SourceJob, url string, immutable bool) (*http.Response, error) {
+	cache, ok := w.httpClient.(*httpcache.Cache)
+	switch {
+	case ok && conf.Offline:
+		return cache.Cached(url)
+	case ok && immutable:
+		return cache.GetImmutable(url)
+	case conf.Offline:
+		return nil, fmt.Errorf("Offline mode requires http cache")
+	default:
+		return w.httpClient.Get(url)
+	}
+}
@@ -0,0 +1,293 @@
+package sources
+
+import (
+	"birnenlabs.com/go/lib/conf"
+	"birnenlabs.com/go/lib/httpcache"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Chart server returning one song per week and recording the requested dates.
+type fakeChart struct {
+	*httptest.Server
+	requested []string
+	lock      sync.Mutex
+}
+
+func newFakeChart() (*webSource, *fakeChart) {
+	chart := &fakeChart{}
+	chart.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chart.lock.Lock()
+		chart.requested = append(chart.requested, r.URL.Path[1:])
+		chart.lock.Unlock()
+		if r.URL.Path == "/2020-01-15" {
+			w.WriteHeader(404)
+			return
+		}
+		fmt.Fprintf(w, "Artist - Song %v", r.URL.Path[1:])
+	}))
+	w := &webSource{
+		httpClient: &http.Client{},
+		findSongsInHtml: func(s string) []string {
+			if len(s) == 0 {
+				return nil
+			}
+			return []string{s}
+		},
+		generateHistoryUrl: func(urlBase string, t time.Time) (string, time.Time) {
+			return urlBase + "/" + t.Format("2006-01-02"), t.AddDate(0, 0, -7)
+		},
+		Delimiter: "\n",
+		SongLimit: 100,
+	}
+	return w, chart
+}
+
+// Runs the source and returns the requested dates.
+func (c *fakeChart) run(w *webSource, conf SourceJob) ([]string, []Song) {
+	c.lock.Lock()
+	c.requested = nil
+	c.lock.Unlock()
+
+	ch := make(chan Song, 10)
+	w.Start(context.Background(), conf, ch)
+	songs := readSongs(ch)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.requested, songs
+}
+
+func TestHistoryCheckpoint(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	w, chart := newFakeChart()
+	defer chart.Close()
+	server := chart.URL
+
+	for _, test := range []struct {
+		url        string
+		fullRescan bool
+		want       []string
+	}{
+		{
+			url:  server + "|2020-01-01|2020-01-08",
+			want: []string{"2020-01-08", "2020-01-01"},
+		},
+		{
+			// Only new weeks are fetched.
+			url:  server + "|2020-01-01|2020-01-29",
+			want: []string{"2020-01-29", "2020-01-22", "2020-01-15"},
+		},
+		{
+			// Only the failed 2020-01-15 is fetched again.
+			url:  server + "|2020-01-01|2020-01-29",
+			want: []string{"2020-01-15"},
+		},
+		{
+			// Start moved before the checkpoint range, everything is fetched.
+			url:  server + "|2019-12-25|2020-01-08",
+			want: []string{"2020-01-08", "2020-01-01", "2019-12-25"},
+		},
+		{
+			url:        server + "|2020-01-01|2020-01-08",
+			fullRescan: true,
+			want:       []string{"2020-01-08", "2020-01-01"},
+		},
+		{
+			url:  server + "|2020-01-01|2020-01-08",
+			want: []string{},
+		},
+	} {
+		requested, _ := chart.run(w, SourceJob{SourceUrl: test.url, CheckpointName: "job", FullRescan: test.fullRescan})
+		if strings.Join(requested, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%q got: %v, want: %v", test.url, requested, test.want)
+		}
+	}
+}
+
+func TestHistoryFailedChart(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	w, chart := newFakeChart()
+	defer chart.Close()
+	url := chart.URL + "|2020-01-08|2020-01-22"
+
+	for i, want := range [][]string{
+		{"2020-01-22", "2020-01-15", "2020-01-08"},
+		{"2020-01-15"},
+		// Last attempt, the chart is skipped after that.
+		{"2020-01-15"},
+		{},
+	} {
+		requested, _ := chart.run(w, SourceJob{SourceUrl: url, CheckpointName: "job"})
+		if strings.Join(requested, ",") != strings.Join(want, ",") {
+			t.Errorf("run %d got: %v, want: %v", i, requested, want)
+		}
+	}
+}
+
+func TestHistoryNewestChartFailed(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	w, chart := newFakeChart()
+	defer chart.Close()
+	url := chart.URL + "|2020-01-01|2020-01-15"
+
+	// The failed chart is fetched once per run and skipped after the last attempt.
+	for i, want := range [][]string{
+		{"2020-01-15", "2020-01-08", "2020-01-01"},
+		{"2020-01-15"},
+		{"2020-01-15"},
+		{},
+	} {
+		requested, _ := chart.run(w, SourceJob{SourceUrl: url, CheckpointName: "job"})
+		if strings.Join(requested, ",") != strings.Join(want, ",") {
+			t.Errorf("run %d got: %v, want: %v", i, requested, want)
+		}
+	}
+}
+
+func TestHistoryCorruptedCheckpoint(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+	err := os.WriteFile(conf.ConfigFilePath(checkpointStoreName), []byte("corrupted"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	w, chart := newFakeChart()
+	defer chart.Close()
+	requested, songs := chart.run(w, SourceJob{SourceUrl: chart.URL + "|2020-01-01|2020-01-08", CheckpointName: "job"})
+	if len(requested) != 0 || len(songs) != 1 || songs[0].Error == nil {
+		t.Errorf("run got: %v %v, want: only error", requested, songs)
+	}
+	if err := setCheckpoint("other", Checkpoint{}); err == nil {
+		t.Errorf("setCheckpoint() overwrote corrupted checkpoints")
+	}
+}
+
+func TestHistoryOfflineCheckpoint(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	w, chart := newFakeChart()
+	defer chart.Close()
+	cache, err := httpcache.New(&http.Client{}, t.TempDir())
+	if err != nil {
+		t.Fatalf("httpcache.New: %v", err)
+	}
+	w.httpClient = cache
+	url := chart.URL + "|2020-01-01|2020-01-08"
+
+	chart.run(w, SourceJob{SourceUrl: url})
+	_, songs := chart.run(w, SourceJob{SourceUrl: url, CheckpointName: "job", Offline: true})
+	if len(songs) != 2 {
+		t.Errorf("offline songs got: %v, want: 2 songs", songs)
+	}
+	if cp, ok, _ := getCheckpoint("job"); ok {
+		t.Errorf("offline run saved checkpoint: %+v", cp)
+	}
+}
+
+func TestHistoryOfflineReplay(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	w, chart := newFakeChart()
+	defer chart.Close()
+	cache, err := httpcache.New(&http.Client{}, t.TempDir())
+	if err != nil {
+		t.Fatalf("httpcache.New: %v", err)
+	}
+	w.httpClient = cache
+	url := chart.URL + "|2020-01-01|2020-01-08"
+
+	// Cached charts are replayed after the live run moved the checkpoint.
+	chart.run(w, SourceJob{SourceUrl: url, CheckpointName: "job"})
+	requested, songs := chart.run(w, SourceJob{SourceUrl: url, CheckpointName: "job", Offline: true})
+	if len(requested) != 0 || len(songs) != 2 {
+		t.Errorf("offline replay got: %v %v, want: 2 cached songs", requested, songs)
+	}
+}
+
+func TestHistoryWithoutCheckpoint(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	w, chart := newFakeChart()
+	defer chart.Close()
+
+	for i := 0; i < 2; i++ {
+		requested, songs := chart.run(w, SourceJob{SourceUrl: chart.URL + "|2020-01-01|2020-01-08"})
+		if len(requested) != 2 || len(songs) != 2 {
+			t.Errorf("run %d got: %v (%v), want: 2 requests", i, requested, songs)
+		}
+	}
+
+	// Charts from the future are not requested.
+	now := time.Now()
+	future := now.AddDate(0, 0, 14).Format("2006-01-02")
+	requested, _ := chart.run(w, SourceJob{SourceUrl: chart.URL + "|" + now.AddDate(0, 0, -14).Format("2006-01-02") + "|" + future})
+	if len(requested) != 2 && len(requested) != 3 {
+		t.Errorf("requested got: %v, want: 2 or 3 past charts", requested)
+	}
+	for _, r := range requested {
+		if r > now.Format("2006-01-02") {
+			t.Errorf("future chart requested: %v", r)
+		}
+	}
+}
+
+func TestHistoryHttpCache(t *testing.T) {
+	w, chart := newFakeChart()
+	defer chart.Close()
+	cache, err := httpcache.New(&http.Client{}, t.TempDir())
+	if err != nil {
+		t.Fatalf("httpcache.New: %v", err)
+	}
+	w.httpClient = cache
+
+	for _, test := range []struct {
+		url          string
+		offline      bool
+		wantRequests int
+		wantSongs    int
+		wantErrors   int
+	}{
+		{url: chart.URL + "|2020-01-01|2020-01-08", wantRequests: 2, wantSongs: 2},
+		// Past charts are immutable.
+		{url: chart.URL + "|2020-01-01|2020-01-08", wantRequests: 0, wantSongs: 2},
+		{url: chart.URL + "|2020-01-01|2020-01-15", offline: true, wantRequests: 0, wantSongs: 2, wantErrors: 1},
+		{url: chart.URL + "/2020-01-01", offline: true, wantRequests: 0, wantSongs: 1},
+		{url: chart.URL + "/2020-01-22", offline: true, wantRequests: 0, wantErrors: 1},
+	} {
+		requested, songs := chart.run(w, SourceJob{SourceUrl: test.url, Offline: test.offline})
+		errors := 0
+		for _, s := range songs {
+			if s.Error != nil {
+				errors++
+			}
+		}
+		if len(requested) != test.wantRequests || len(songs)-errors != test.wantSongs || errors != test.wantErrors {
+			t.Errorf("%q got: %v requests, %v, want: %v requests, %v songs, %v errors", test.url, requested, songs, test.wantRequests, test.wantSongs, test.wantErrors)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	glog "birnenlabs.com/go/lib/alog"
+	"birnenlabs.com/go/lib/conf"
+	"net/url"
+	"time"
+)
+
+const stateFilePrefix = "streaming-playlist-maker-state-"
+
+// Number of the days kept in the trend.
+const trendDays = 14
+
+const dayFormat = "2006-01-02"
+
+// State of the job kept between the runs.
+type JobState struct {
+	LastRun time.Time
+	// Last song seen by any of the job savers and when it was seen.
+	LastSong     string
+	LastSongTime time.Time
+	// State by the saver statistics name.
+	Savers map[string]*SaverState
+}
+
+type SaverState struct {
+	// First and last run which updated the state.
+	Since   time.Time
+	Updated time.Time
+	// Last run which added at least one song, zero if none.
+	LastAdded time.Time
+	Runs      int64
+	// Counters summed over all the runs.
+	Total DayCounters
+	// Counters of the recent days with runs, oldest first.
+	Days []DayCounters
+}
+
+type DayCounters struct {
+	// Date in the "2006-01-02" format, empty for the total.
+	Day      string
+	Added    int64
+	NotFound int64
+	Exists   int64
+	Errors   int64
+}
+
+// Returns the config name of the job state, the job name is escaped so different names never share
+// the file.
+func stateFileName(jobName string) string {
+	return stateFilePrefix + url.QueryEscape(jobName)
+}
+
+func loadJobState(jobName string) *JobState {
+	state := &JobState{}
+	err := conf.LoadConfigFromFile(stateFileName(jobName), state)
+	if err != nil {
+		glog.V(1).Infof("[%15.15s] Could not load state (%v), starting with empty one.", jobName, err)
+	}
+	if state.Savers == nil {
+		state.Savers = make(map[string]*SaverState)
+	}
+	return state
+}
+
+// Adds the run statistics of the active jobs to their states and saves them. The states are kept in
+// the statistics so FindIssues can report the regressions.
+func saveJobStates(stats *statistics, jobs []Job, now time.Time) {
+	for _, job := range jobs {
+		if !job.Active {
+			continue
+		}
+		state := loadJobState(job.Name)
+		stats.updateState(state, job.statsNames(), now)
+		err := conf.SaveConfigToFile(stateFileName(job.Name), state)
+		if err != nil {
+			glog.Errorf("[%15.15s] Could not save state: %v", job.Name, err)
+		}
+	}
+}
+
+func (s *statistics) updateState(state *JobState, names []string, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state.LastRun = now
+	for _, name := range names {
+		v, ok := s.m[name]
+		if !ok {
+			continue
+		}
+		ss := state.Savers[name]
+		if ss == nil {
+			ss = &SaverState{Since: now}
+			state.Savers[name] = ss
+		}
+		if len(v.lastSong) > 0 && v.lastSongTime.After(state.LastSongTime) {
+			state.LastSong = v.lastSong
+			state.LastSongTime = v.lastSongTime
+		}
+
+		ss.Updated = now
+		ss.Runs++
+		if v.added > 0 {
+			ss.LastAdded = now
+		}
+		ss.Total.add(v)
+		day := now.Format(dayFormat)
+		if len(ss.Days) == 0 || ss.Days[len(ss.Days)-1].Day != day {
+			ss.Days = append(ss.Days, DayCounters{Day: day})
+		}
+		ss.Days[len(ss.Days)-1].add(v)
+		if len(ss.Days) > trendDays {
+			ss.Days = ss.Days[len(ss.Days)-trendDays:]
+		}
+		v.state = ss
+	}
+}
+
+func (d *DayCounters) add(v *aggregatedStatus) {
+	d.Added += v.added
+	d.NotFound += v.notFound
+	d.Exists += v.exists
+	d.Errors += v.errors
+}
+
+// Returns the number of full days without added songs, measured from the first run if nothing was
+// ever added.
+func (ss *SaverState) daysWithoutAdded() int {
+	since := ss.LastAdded
+	if since.IsZero() {
+		since = ss.Since
+	}
+	return int(ss.Updated.Sub(since) / (24 * time.Hour))
+}
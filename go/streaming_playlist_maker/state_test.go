@@ -0,0 +1,114 @@
+package main
+
+import (
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveJobStates(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(home+"/.config", 0700)
+	t.Setenv("HOME", home)
+
+	job := Job{Name: "radio/1", Active: true}
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	run := func(now time.Time, added int) *statistics {
+		stats := &statistics{}
+		initStats(stats, job)
+		for i := 0; i < added; i++ {
+			stats.Added(job.Name, "Artist - Song")
+		}
+		stats.NotFound(job.Name, "Nobody - Nothing")
+		saveJobStates(stats, []Job{job, {Name: "inactive"}}, now)
+		return stats
+	}
+
+	run(start.Add(-time.Hour), 2)
+	run(start, 1)
+	for day := 1; day <= 3; day++ {
+		stats := run(start.Add(time.Duration(day)*24*time.Hour), 0)
+		issues := stats.FindIssues()
+		if got, want := strings.Contains(issues, "no songs added for 3 days"), day == 3; got != want {
+			t.Errorf("FindIssues after %d days got: %q, want issue: %v", day, issues, want)
+		}
+	}
+
+	state := loadJobState(job.Name)
+	ss := state.Savers[job.Name]
+	if ss == nil {
+		t.Fatalf("State got: %+v, want saver %q", state, job.Name)
+	}
+	if !state.LastRun.Equal(start.Add(72*time.Hour)) || state.LastSong != "Nobody - Nothing" {
+		t.Errorf("State got: %v %q, want last run %v and last song", state.LastRun, state.LastSong, start.Add(72*time.Hour))
+	}
+	if ss.Runs != 5 || ss.Total.Added != 3 || ss.Total.NotFound != 5 || !ss.LastAdded.Equal(start) {
+		t.Errorf("Saver state got: %+v, want 5 runs, 3 added, 5 not found", ss)
+	}
+	if len(ss.Days) != 4 || ss.Days[0].Day != "2026-10-01" || ss.Days[0].Added != 3 || ss.Days[3].Added != 0 {
+		t.Errorf("Days got: %+v, want 4 days starting with 3 added", ss.Days)
+	}
+	if _, err := os.Stat(home + "/.config/" + stateFilePrefix + "inactive.gob"); err == nil {
+		t.Errorf("State of the inactive job was saved")
+	}
+}
+
+func TestTrendDays(t *testing.T) {
+	stats := &statistics{}
+	stats.Init("job")
+	state := &JobState{Savers: make(map[string]*SaverState)}
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for day := 0; day < trendDays+5; day++ {
+		stats.updateState(state, []string{"job", "missing"}, start.Add(time.Duration(day)*24*time.Hour))
+	}
+	days := state.Savers["job"].Days
+	if len(days) != trendDays || days[0].Day != "2026-10-06" {
+		t.Errorf("Days got: %d starting %v, want: %d starting 2026-10-06", len(days), days[0].Day, trendDays)
+	}
+	if _, ok := state.Savers["missing"]; ok {
+		t.Errorf("State of the untracked saver was created")
+	}
+}
+
+func TestLastSong(t *testing.T) {
+	stats := &statistics{}
+	job := Job{Name: "job", Savers: []savers.SaverJob{{SaverType: "spotify"}, {SaverType: "stdout"}}}
+	initStats(stats, job)
+	state := &JobState{Savers: make(map[string]*SaverState)}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// The last song is the latest one seen by any saver, not the one of the last saver.
+	stats.NotFound("job/stdout", "Artist - Song 1")
+	stats.Added("job/spotify", "Artist - Song 2")
+	stats.m["job/stdout"].lastSongTime = stats.m["job/spotify"].lastSongTime.Add(-time.Second)
+	stats.updateState(state, job.statsNames(), now)
+	if state.LastSong != "Artist - Song 2" || !state.LastSongTime.Equal(stats.m["job/spotify"].lastSongTime) {
+		t.Errorf("Last song got: %q %v, want: %q", state.LastSong, state.LastSongTime, "Artist - Song 2")
+	}
+
+	// Songs of the previous runs are kept when nothing was seen.
+	stats = &statistics{}
+	initStats(stats, job)
+	stats.updateState(state, job.statsNames(), now.Add(time.Hour))
+	if state.LastSong != "Artist - Song 2" {
+		t.Errorf("Last song after empty run got: %q, want: %q", state.LastSong, "Artist - Song 2")
+	}
+}
+
+func TestStateFileName(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want string
+	}{
+		{"Radio 1/spotify", stateFilePrefix + "Radio+1%2Fspotify"},
+		{"a b", stateFilePrefix + "a+b"},
+		{"a_b", stateFilePrefix + "a_b"},
+		{"a+b", stateFilePrefix + "a%2Bb"},
+	} {
+		if got := stateFileName(test.name); got != test.want {
+			t.Errorf("stateFileName(%q) got: %q, want: %q", test.name, got, test.want)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"birnenlabs.com/go/streaming_playlist_maker/savers"
 	"bytes"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 type aggregatedStatus struct {
@@ -11,6 +15,31 @@ type aggregatedStatus struct {
 	notFound int64
 	exists   int64
 	errors   int64
+	// Stream reconnections, titleTimeouts is the number of reconnections caused by title timeout.
+	reconnects    int64
+	titleTimeouts int64
+	downtime      time.Duration
+	// Number of titles dropped by the filter rule.
+	filtered map[string]int64
+	// Result of the cleaning, nil if the saver was not cleaned.
+	clean *savers.CleanStatus
+	// Songs added to the playlist, used in the run report.
+	tracks []addedTrack
+	// Counts of the not found and erroring songs by the artist title.
+	notFoundTitles map[string]int64
+	errorTitles    map[string]int64
+	thresholds     IssueThresholds
+	// Last song seen by the saver and when it was seen.
+	lastSong     string
+	lastSongTime time.Time
+	// State kept between the runs, nil until the state is updated at the end of the run.
+	state *SaverState
+}
+
+type addedTrack struct {
+	ArtistTitle string
+	FoundTitle  string
+	Url         string
 }
 
 type statistics struct {
@@ -30,16 +59,43 @@ func (s *statistics) Init(jobName string) error {
 		return fmt.Errorf("%v already initialized", jobName)
 	}
 
-	s.m[jobName] = &aggregatedStatus{}
+	s.m[jobName] = &aggregatedStatus{thresholds: IssueThresholds{}.withDefaults()}
 	return nil
 }
 
+// Sets the thresholds used by FindIssues, zero values are replaced by the defaults.
+func (s *statistics) SetThresholds(jobName string, t IssueThresholds) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.m[jobName].thresholds = t.withDefaults()
+}
+
 // Song was added to the playlist
 func (s *statistics) Added(jobName string, artistTitle string) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	s.m[jobName].added++
+	s.m[jobName].seen(artistTitle)
+}
+
+// Song found by the saver was added, kept for the run report.
+func (s *statistics) AddedTrack(jobName string, artistTitle string, status *savers.Status) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.m[jobName].tracks = append(s.m[jobName].tracks, addedTrack{artistTitle, status.FoundTitle, status.FoundUrl})
+}
+
+// Saver was cleaned, savers of the jobs which are not tracked are ignored.
+func (s *statistics) Cleaned(jobName string, status *savers.CleanStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if v, ok := s.m[jobName]; ok {
+		v.clean = status
+	}
 }
 
 // Song was not found by saver.
@@ -48,6 +104,8 @@ func (s *statistics) NotFound(jobName string, artistTitle string) {
 	defer s.lock.Unlock()
 
 	s.m[jobName].notFound++
+	s.m[jobName].seen(artistTitle)
+	s.m[jobName].notFoundTitles = countTitle(s.m[jobName].notFoundTitles, artistTitle)
 }
 
 // Song was found by the saver but it already exists
@@ -56,6 +114,7 @@ func (s *statistics) Exists(jobName string, artistTitle string) {
 	defer s.lock.Unlock()
 
 	s.m[jobName].exists++
+	s.m[jobName].seen(artistTitle)
 }
 
 func (s *statistics) Error(jobName string, artistTitle string, err error) {
@@ -63,6 +122,32 @@ func (s *statistics) Error(jobName string, artistTitle string, err error) {
 	defer s.lock.Unlock()
 
 	s.m[jobName].errors++
+	s.m[jobName].seen(artistTitle)
+	s.m[jobName].errorTitles = countTitle(s.m[jobName].errorTitles, artistTitle)
+}
+
+// Source stream was reconnected after the downtime.
+func (s *statistics) Reconnected(jobName string, titleTimeout bool, downtime time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.m[jobName].reconnects++
+	if titleTimeout {
+		s.m[jobName].titleTimeouts++
+	}
+	s.m[jobName].downtime += downtime
+}
+
+// Song was dropped by the filter rule.
+func (s *statistics) Filtered(jobName string, artistTitle string, rule string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.m[jobName].filtered == nil {
+		s.m[jobName].filtered = make(map[string]int64)
+	}
+	s.m[jobName].filtered[rule]++
+	s.m[jobName].seen(artistTitle)
 }
 
 func (s *statistics) String() string {
@@ -73,7 +158,14 @@ func (s *statistics) String() string {
 	for k, v := range s.m {
 		total := v.added + v.notFound + v.exists + v.errors
 
-		buf.WriteString(fmt.Sprintf("[%15.15s] A %4d, N %5d, E %5d, Err %3d, total: %5d.\n", k, v.added, v.notFound, v.exists, v.errors, total))
+		buf.WriteString(fmt.Sprintf("[%15.15s] A %4d, N %5d, E %5d, Err %3d, total: %5d.", k, v.added, v.notFound, v.exists, v.errors, total))
+		if v.reconnects > 0 {
+			buf.WriteString(fmt.Sprintf(" Reconnects %d (title timeouts %d), downtime: %v.", v.reconnects, v.titleTimeouts, v.downtime.Round(time.Second)))
+		}
+		for rule, count := range v.filtered {
+			buf.WriteString(fmt.Sprintf(" Filtered by %q: %d.", rule, count))
+		}
+		buf.WriteString("\n")
 	}
 	return buf.String()
 }
@@ -87,17 +179,94 @@ func (s *statistics) FindIssues() string {
 		buf.WriteString("Zero sources were tracked.")
 	}
 
-	for k, v := range s.m {
+	names := make([]string, 0, len(s.m))
+	for k := range s.m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		v := s.m[k]
+		t := v.thresholds
+		seen := v.added + v.notFound + v.exists + v.errors
 		if v.added+v.exists == 0 {
-			buf.WriteString(k)
-			buf.WriteString(": no songs were added or existed before.\n")
-		} else if v.errors*100/(v.added+v.exists) > 30 {
-			buf.WriteString(k)
-			buf.WriteString(": more than 30% of errors.\n")
+			buf.WriteString(fmt.Sprintf("%v: no songs were added or existed before.%v%v\n", k, topTitles(" Not found:", v.notFoundTitles, t.TopTitles), topTitles(" Errors:", v.errorTitles, t.TopTitles)))
+		}
+		if seen < t.MinSeen {
+			buf.WriteString(fmt.Sprintf("%v: %d songs seen, expected at least %d.\n", k, seen, t.MinSeen))
+		}
+		if v.added < t.MinAdded {
+			buf.WriteString(fmt.Sprintf("%v: %d songs added, expected at least %d.\n", k, v.added, t.MinAdded))
+		}
+		if v.state != nil && t.MaxDaysWithoutAdded > 0 {
+			if days := v.state.daysWithoutAdded(); days >= t.MaxDaysWithoutAdded {
+				buf.WriteString(fmt.Sprintf("%v: no songs added for %d days, added by day: %v.\n", k, days, addedTrend(v.state.Days)))
+			}
+		}
+		if max := *t.MaxErrorRatio; max >= 0 && v.added+v.exists > 0 {
+			if ratio := float64(v.errors) / float64(v.added+v.exists); ratio > max {
+				buf.WriteString(fmt.Sprintf("%v: %.0f%% of errors, expected at most %.0f%%.%v\n", k, ratio*100, max*100, topTitles(" Errors:", v.errorTitles, t.TopTitles)))
+			}
+		}
+		if seen == 0 {
+			continue
+		}
+		if ratio := float64(v.notFound) / float64(seen); t.MaxNotFoundRatio > 0 && ratio > t.MaxNotFoundRatio {
+			buf.WriteString(fmt.Sprintf("%v: %.0f%% of songs not found, expected at most %.0f%%.%v\n", k, ratio*100, t.MaxNotFoundRatio*100, topTitles(" Not found:", v.notFoundTitles, t.TopTitles)))
 		}
 	}
 	return buf.String()
+}
+
+func (v *aggregatedStatus) seen(artistTitle string) {
+	if len(artistTitle) > 0 {
+		v.lastSong = artistTitle
+		v.lastSongTime = time.Now()
+	}
+}
+
+func countTitle(titles map[string]int64, artistTitle string) map[string]int64 {
+	if len(artistTitle) == 0 {
+		return titles
+	}
+	if titles == nil {
+		titles = make(map[string]int64)
+	}
+	titles[artistTitle]++
+	return titles
+}
+
+func addedTrend(days []DayCounters) string {
+	result := make([]string, len(days))
+	for i, d := range days {
+		result[i] = fmt.Sprintf("%v %d", d.Day, d.Added)
+	}
+	return strings.Join(result, ", ")
+}
 
+// Returns the prefix followed by the n most frequent titles with their counts, empty if there are no titles.
+func topTitles(prefix string, titles map[string]int64, n int) string {
+	if len(titles) == 0 || n <= 0 {
+		return ""
+	}
+	sorted := make([]string, 0, len(titles))
+	for title := range titles {
+		sorted = append(sorted, title)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if titles[sorted[i]] != titles[sorted[j]] {
+			return titles[sorted[i]] > titles[sorted[j]]
+		}
+		return sorted[i] < sorted[j]
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	result := make([]string, len(sorted))
+	for i, title := range sorted {
+		result[i] = fmt.Sprintf("%q (%d)", title, titles[title])
+	}
+	return prefix + " " + strings.Join(result, ", ") + "."
 }
 
 func max(a int64, b int64) int64 {
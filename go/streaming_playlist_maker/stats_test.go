@@ -1,7 +1,9 @@
 package main
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestInit(t *testing.T) {
@@ -72,3 +74,133 @@ func TestError(t *testing.T) {
 		t.Errorf("TestErr: got: %v want: 2", s.m["name"].errors)
 	}
 }
+
+func TestReconnected(t *testing.T) {
+	s := &statistics{}
+	s.Init("name")
+	if strings.Contains(s.String(), "Reconnects") {
+		t.Errorf("TestRec: got: %q, want: no reconnects", s.String())
+	}
+
+	s.Reconnected("name", false, time.Minute)
+	s.Reconnected("name", true, time.Hour)
+	if s.m["name"].reconnects != 2 || s.m["name"].titleTimeouts != 1 || s.m["name"].downtime != time.Hour+time.Minute {
+		t.Errorf("TestRec: got: %+v want: 2 reconnects, 1 title timeout, 1h1m", s.m["name"])
+	}
+	if !strings.Contains(s.String(), "Reconnects 2 (title timeouts 1), downtime: 1h1m0s.") {
+		t.Errorf("TestRec: got: %q", s.String())
+	}
+}
+
+func TestFiltered(t *testing.T) {
+	s := &statistics{}
+	s.Init("name")
+	s.Filtered("name", "", "deny")
+	s.Filtered("name", "", "deny")
+	s.Filtered("name", "", "learned")
+	if s.m["name"].filtered["deny"] != 2 || s.m["name"].filtered["learned"] != 1 {
+		t.Errorf("TestFiltered: got: %v want: deny 2, learned 1", s.m["name"].filtered)
+	}
+	if !strings.Contains(s.String(), `Filtered by "deny": 2.`) {
+		t.Errorf("TestFiltered: got: %q", s.String())
+	}
+}
+
+func TestFindIssues(t *testing.T) {
+	ratio := func(r float64) *float64 { return &r }
+	for _, test := range []struct {
+		name       string
+		thresholds IssueThresholds
+		record     func(s *statistics)
+		want       []string
+	}{
+		{
+			name:   "nothing seen",
+			record: func(s *statistics) {},
+			want:   []string{"name: no songs were added or existed before.\n"},
+		},
+		{
+			name: "nothing found",
+			record: func(s *statistics) {
+				s.NotFound("name", "A - 1")
+				s.NotFound("name", "B - 2")
+				s.NotFound("name", "B - 2")
+			},
+			want: []string{`name: no songs were added or existed before. Not found: "B - 2" (2), "A - 1" (1).` + "\n"},
+		},
+		{
+			name: "all errors",
+			record: func(s *statistics) {
+				s.NotFound("name", "A - 1")
+				s.Error("name", "C - 3", nil)
+				s.Error("name", "C - 3", nil)
+			},
+			want: []string{`name: no songs were added or existed before. Not found: "A - 1" (1). Errors: "C - 3" (2).` + "\n"},
+		},
+		{
+			name: "default error ratio",
+			record: func(s *statistics) {
+				s.Added("name", "")
+				s.Exists("name", "")
+				s.NotFound("name", "A - 1")
+				s.Error("name", "C - 3", nil)
+			},
+			// Errors are relative to the added and existing songs.
+			want: []string{`name: 50% of errors, expected at most 30%. Errors: "C - 3" (1).` + "\n"},
+		},
+		{
+			name:       "no errors allowed",
+			thresholds: IssueThresholds{MaxErrorRatio: ratio(0)},
+			record: func(s *statistics) {
+				s.Added("name", "")
+				s.Added("name", "")
+				s.Added("name", "")
+				s.Added("name", "")
+				s.Error("name", "C - 3", nil)
+			},
+			want: []string{`name: 25% of errors, expected at most 0%. Errors: "C - 3" (1).` + "\n"},
+		},
+		{
+			name:       "error ratio disabled",
+			thresholds: IssueThresholds{MaxErrorRatio: ratio(-1)},
+			record: func(s *statistics) {
+				s.Added("name", "")
+				s.Error("name", "C - 3", nil)
+				s.Error("name", "C - 3", nil)
+			},
+		},
+		{
+			name:       "thresholds",
+			thresholds: IssueThresholds{MinAdded: 2, MinSeen: 10, MaxErrorRatio: ratio(0.5), MaxNotFoundRatio: 0.2, TopTitles: 1},
+			record: func(s *statistics) {
+				s.Added("name", "")
+				s.Error("name", "C - 3", nil)
+				s.NotFound("name", "A - 1")
+				s.NotFound("name", "B - 2")
+				s.NotFound("name", "B - 2")
+			},
+			want: []string{
+				"name: 5 songs seen, expected at least 10.\n",
+				"name: 1 songs added, expected at least 2.\n",
+				`name: 100% of errors, expected at most 50%. Errors: "C - 3" (1).` + "\n",
+				`name: 60% of songs not found, expected at most 20%. Not found: "B - 2" (2).` + "\n",
+			},
+		},
+		{
+			name: "no issues",
+			record: func(s *statistics) {
+				s.Added("name", "")
+				s.Exists("name", "")
+				s.NotFound("name", "A - 1")
+			},
+		},
+	} {
+		s := &statistics{}
+		s.Init("name")
+		s.SetThresholds("name", test.thresholds)
+		test.record(s)
+		if got, want := s.FindIssues(), strings.Join(test.want, ""); got != want {
+			t.Errorf("FindIssues(%v) got: %q, want: %q", test.name, got, want)
+		}
+	}
+}